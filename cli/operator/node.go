@@ -92,6 +92,7 @@ type config struct {
 	WithPing                   bool                             `yaml:"WithPing" env:"WITH_PING" env-description:"Whether to send websocket ping messages'"`
 	SSVAPIPort                 int                              `yaml:"SSVAPIPort" env:"SSV_API_PORT" env-description:"Port to listen on for the SSV API."`
 	LocalEventsPath            string                           `yaml:"LocalEventsPath" env:"EVENTS_PATH" env-description:"path to local events"`
+	SignatureAuditLog          bool                             `yaml:"SignatureAuditLog" env:"SIGNATURE_AUDIT_LOG" env-description:"Record every signature made by the node in an audit log in the database"`
 }
 
 var cfg config
@@ -182,6 +183,10 @@ var StartNodeCmd = &cobra.Command{
 		if err != nil {
 			logger.Fatal("could not create new eth-key-manager signer", zap.Error(err))
 		}
+		if cfg.SignatureAuditLog {
+			keyManager = ekm.NewAuditingKeyManager(keyManager, db, networkConfig.Beacon)
+			logger.Info("recording signatures in the audit log")
+		}
 
 		cfg.P2pNetworkConfig.Ctx = cmd.Context()
 
@@ -204,7 +209,7 @@ var StartNodeCmd = &cobra.Command{
 			ethcommon.HexToAddress(networkConfig.RegistryContractAddr),
 			executionclient.WithLogger(logger),
 			executionclient.WithMetrics(metricsReporter),
-			executionclient.WithFollowDistance(executionclient.DefaultFollowDistance),
+			executionclient.WithFollowDistance(cfg.ExecutionClient.FollowDistance),
 			executionclient.WithConnectionTimeout(cfg.ExecutionClient.ConnectionTimeout),
 			executionclient.WithReconnectionInitialInterval(executionclient.DefaultReconnectionInitialInterval),
 			executionclient.WithReconnectionMaxInterval(executionclient.DefaultReconnectionMaxInterval),
@@ -0,0 +1,132 @@
+package ekm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// AccountFormat is the serialization format of stored accounts.
+type AccountFormat byte
+
+const (
+	// AccountFormatJSON stores accounts as JSON. JSON accounts have no discriminator byte,
+	// so that accounts stored before formats were introduced keep decoding.
+	AccountFormatJSON AccountFormat = iota
+	// AccountFormatGob stores HD accounts in a compact gob encoding, prefixed with a discriminator byte.
+	// Other accounts are stored as JSON.
+	AccountFormatGob
+)
+
+// hdAccountJSON mirrors the JSON encoding of wallets.HDAccount,
+// which is the only way to access its fields.
+type hdAccountJSON struct {
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	BasePath         string    `json:"baseAccountPath"`
+	WithdrawalPubKey string    `json:"withdrawalPubKey"`
+	ValidationKey    struct {
+		ID      uuid.UUID `json:"id"`
+		PrivKey string    `json:"privKey"`
+		Path    string    `json:"path"`
+	} `json:"validationKey"`
+}
+
+// gobHDAccount is the gob encoding of wallets.HDAccount, holding keys as raw bytes rather than hex.
+type gobHDAccount struct {
+	ID               uuid.UUID
+	Name             string
+	BasePath         string
+	WithdrawalPubKey []byte
+	KeyID            uuid.UUID
+	KeyPath          string
+	PrivKey          []byte
+}
+
+// encodeAccount serializes the given account in the storage's account format.
+func (s *storage) encodeAccount(account core.ValidatorAccount) ([]byte, error) {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := account.(*wallets.HDAccount); !ok || s.accountFormat != AccountFormatGob {
+		return data, nil
+	}
+	return encodeGobAccount(data)
+}
+
+func encodeGobAccount(jsonData []byte) ([]byte, error) {
+	var v hdAccountJSON
+	if err := json.Unmarshal(jsonData, &v); err != nil {
+		return nil, err
+	}
+	withdrawalPubKey, err := hex.DecodeString(v.WithdrawalPubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid withdrawal public key")
+	}
+	privKey, err := hex.DecodeString(v.ValidationKey.PrivKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid validation key")
+	}
+
+	buf := bytes.NewBuffer([]byte{byte(AccountFormatGob)})
+	err = gob.NewEncoder(buf).Encode(&gobHDAccount{
+		ID:               v.ID,
+		Name:             v.Name,
+		BasePath:         v.BasePath,
+		WithdrawalPubKey: withdrawalPubKey,
+		KeyID:            v.ValidationKey.ID,
+		KeyPath:          v.ValidationKey.Path,
+		PrivKey:          privKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeGobAccount decodes an HD account encoded by encodeGobAccount.
+// Since HDAccount's fields are unexported, it's rebuilt through its JSON encoding.
+func decodeGobAccount(byts []byte) (*wallets.HDAccount, error) {
+	if len(byts) == 0 || AccountFormat(byts[0]) != AccountFormatGob {
+		return nil, errors.New("not a gob encoded account")
+	}
+	var v gobHDAccount
+	if err := gob.NewDecoder(bytes.NewReader(byts[1:])).Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var j hdAccountJSON
+	j.ID = v.ID
+	j.Name = v.Name
+	j.BasePath = v.BasePath
+	j.WithdrawalPubKey = hex.EncodeToString(v.WithdrawalPubKey)
+	j.ValidationKey.ID = v.KeyID
+	j.ValidationKey.Path = v.KeyPath
+	j.ValidationKey.PrivKey = hex.EncodeToString(v.PrivKey)
+	data, err := json.Marshal(&j)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret *wallets.HDAccount
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// isPlainAccount returns true if the given bytes are an unencrypted account in any of the account formats.
+func isPlainAccount(byts []byte) bool {
+	if json.Valid(byts) {
+		return true
+	}
+	_, err := decodeGobAccount(byts)
+	return err == nil
+}
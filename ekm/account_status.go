@@ -0,0 +1,121 @@
+package ekm
+
+import (
+	"fmt"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// SetAccountEnabled enables or disables the given account. A disabled account keeps its key
+// and slashing protection data, but isn't used for signing.
+func (s *storage) SetAccountEnabled(accountID uuid.UUID, enabled bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := []byte(fmt.Sprintf(accountsPath, accountID.String()))
+	_, found, err := s.db.Get(s.objPrefix(accountsPrefix), key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account")
+	}
+	if !found {
+		return errors.New("account not found")
+	}
+
+	if enabled {
+		err = s.db.Delete(s.objPrefix(disabledAccountsPrefix), key)
+	} else {
+		err = s.db.Set(s.objPrefix(disabledAccountsPrefix), key, []byte{1})
+	}
+	// The cache is reloaded rather than updated, so that it can't diverge from storage if the write failed.
+	s.resetDisabled()
+	return err
+}
+
+// IsAccountEnabled returns false if the given account was disabled with SetAccountEnabled.
+func (s *storage) IsAccountEnabled(accountID uuid.UUID) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	key := []byte(fmt.Sprintf(accountsPath, accountID.String()))
+	_, found, err := s.db.Get(s.objPrefix(accountsPrefix), key)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get account")
+	}
+	if !found {
+		return false, errors.New("account not found")
+	}
+
+	disabled, err := s.isDisabled(key)
+	if err != nil {
+		return false, err
+	}
+	return !disabled, nil
+}
+
+// IsAccountDisabled returns true if the given account was disabled with SetAccountEnabled.
+// Unlike IsAccountEnabled, it doesn't check that the account exists, so it's served from memory
+// once the disabled accounts are loaded.
+func (s *storage) IsAccountDisabled(accountID uuid.UUID) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.isDisabled([]byte(fmt.Sprintf(accountsPath, accountID.String())))
+}
+
+// AccountStatus is a listed account together with its enabled flag.
+type AccountStatus struct {
+	Account core.ValidatorAccount
+	Enabled bool
+}
+
+// ListAccountsWithStatus returns the accounts, including disabled ones, with their enabled flags.
+func (s *storage) ListAccountsWithStatus() ([]AccountStatus, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	accounts, err := s.listAccounts(s.reader())
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]AccountStatus, 0, len(accounts))
+	for _, account := range accounts {
+		disabled, err := s.isDisabled([]byte(fmt.Sprintf(accountsPath, account.ID().String())))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, AccountStatus{Account: account, Enabled: !disabled})
+	}
+	return ret, nil
+}
+
+// isDisabled returns whether the account with the given key is disabled, loading the disabled accounts
+// from storage on first use.
+func (s *storage) isDisabled(key []byte) (bool, error) {
+	s.disabledLock.Lock()
+	defer s.disabledLock.Unlock()
+
+	if s.disabled == nil {
+		disabled := make(map[string]struct{})
+		err := s.db.GetAll(s.objPrefix(disabledAccountsPrefix), func(i int, obj basedb.Obj) error {
+			disabled[string(obj.Key)] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return false, errors.Wrap(err, "failed to load disabled accounts")
+		}
+		s.disabled = disabled
+	}
+	_, ok := s.disabled[string(key)]
+	return ok, nil
+}
+
+// resetDisabled drops the cached disabled accounts, which must be called whenever they're written.
+func (s *storage) resetDisabled() {
+	s.disabledLock.Lock()
+	s.disabled = nil
+	s.disabledLock.Unlock()
+}
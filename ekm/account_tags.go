@@ -0,0 +1,127 @@
+package ekm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// AccountTagStorage groups accounts by free-form tags, for use by tooling.
+type AccountTagStorage interface {
+	SetAccountTags(accountID uuid.UUID, tags []string) error
+	AccountTags(accountID uuid.UUID) ([]string, error)
+	ListAccountsByTag(tag string) ([]core.ValidatorAccount, error)
+}
+
+var _ AccountTagStorage = (*storage)(nil)
+
+// maxAccountTagLength is short enough for a tag not to hold a 32 byte key in hex or base64.
+const maxAccountTagLength = 32
+
+var accountTagPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// SetAccountTags replaces the tags of the given account, used to group accounts in tooling.
+// Tags are stored unencrypted next to the account, so they can be scanned without decrypting accounts
+// and are kept as is when the encryption key changes. Tags are at most 32 characters of letters, digits,
+// dots, dashes and underscores. An empty list removes the account's tags.
+func (s *storage) SetAccountTags(accountID uuid.UUID, tags []string) error {
+	for _, tag := range tags {
+		if len(tag) > maxAccountTagLength || !accountTagPattern.MatchString(tag) {
+			return errors.Errorf("invalid tag %q", tag)
+		}
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := []byte(fmt.Sprintf(accountsPath, accountID.String()))
+	_, found, err := s.db.Get(s.objPrefix(accountsPrefix), key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account")
+	}
+	if !found {
+		return errors.New("account not found")
+	}
+
+	if len(tags) == 0 {
+		return s.db.Delete(s.objPrefix(accountTagsPrefix), key)
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal account tags")
+	}
+	return s.db.Set(s.objPrefix(accountTagsPrefix), key, data)
+}
+
+// AccountTags returns the tags of the given account, in ascending order.
+func (s *storage) AccountTags(accountID uuid.UUID) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	obj, found, err := s.db.Get(s.objPrefix(accountTagsPrefix), []byte(fmt.Sprintf(accountsPath, accountID.String())))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get account tags")
+	}
+	if !found {
+		return nil, nil
+	}
+	var tags []string
+	if err := json.Unmarshal(obj.Value, &tags); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal account tags")
+	}
+	return tags, nil
+}
+
+// ListAccountsByTag returns the accounts which have the given tag.
+// It reads from the read replica if one is set.
+func (s *storage) ListAccountsByTag(tag string) ([]core.ValidatorAccount, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	txn := s.reader().BeginRead()
+	defer txn.Discard()
+
+	var keys [][]byte
+	err := txn.GetAll(s.objPrefix(accountTagsPrefix), func(i int, obj basedb.Obj) error {
+		var tags []string
+		if err := json.Unmarshal(obj.Value, &tags); err != nil {
+			return errors.Wrapf(err, "failed to unmarshal account tags of %s", obj.Key)
+		}
+		if slices.Contains(tags, tag) {
+			keys = append(keys, obj.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list account tags")
+	}
+
+	ret := make([]core.ValidatorAccount, 0, len(keys))
+	err = txn.GetMany(s.objPrefix(accountsPrefix), keys, func(obj basedb.Obj) error {
+		value, release, err := s.decryptData(obj.Value)
+		if err != nil {
+			return errors.Wrap(err, "failed to decrypt account")
+		}
+		acc, err := s.decodeAccount(value)
+		release()
+		if err != nil {
+			return errors.Wrap(err, "failed to decode account")
+		}
+		ret = append(ret, acc)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tagged accounts")
+	}
+	return ret, nil
+}
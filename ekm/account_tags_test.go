@@ -0,0 +1,83 @@
+package ekm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/google/uuid"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+
+	"github.com/ssvlabs/ssv/utils/threshold"
+)
+
+func TestAccountTags(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithEnvelopeEncryption())
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+	tagStorage := signerStorage.(AccountTagStorage)
+
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	newAccount := func(index int) core.ValidatorAccount {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+		require.NoError(t, err)
+		return acc
+	}
+	acc1 := newAccount(0)
+	acc2 := newAccount(1)
+
+	ids := func(accounts []core.ValidatorAccount) []uuid.UUID {
+		var ret []uuid.UUID
+		for _, acc := range accounts {
+			ret = append(ret, acc.ID())
+		}
+		return ret
+	}
+
+	require.NoError(t, tagStorage.SetAccountTags(acc1.ID(), []string{"hot", "cohort-A", "hot"}))
+	require.NoError(t, tagStorage.SetAccountTags(acc2.ID(), []string{"cohort-A"}))
+	tags, err := tagStorage.AccountTags(acc1.ID())
+	require.NoError(t, err)
+	require.Equal(t, []string{"cohort-A", "hot"}, tags)
+
+	accounts, err := tagStorage.ListAccountsByTag("hot")
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{acc1.ID()}, ids(accounts))
+	accounts, err = tagStorage.ListAccountsByTag("cohort-A")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{acc1.ID(), acc2.ID()}, ids(accounts))
+
+	// Tags survive key rotation.
+	require.NoError(t, signerStorage.RotateEncryptionKey("fedcba9876543210fedcba9876543210"))
+	accounts, err = tagStorage.ListAccountsByTag("hot")
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{acc1.ID()}, ids(accounts))
+
+	// Clearing and deleting remove tags.
+	require.NoError(t, tagStorage.SetAccountTags(acc1.ID(), nil))
+	accounts, err = tagStorage.ListAccountsByTag("hot")
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+	require.NoError(t, signerStorage.DeleteAccount(acc2.ID()))
+	tags, err = tagStorage.AccountTags(acc2.ID())
+	require.NoError(t, err)
+	require.Empty(t, tags)
+
+	require.ErrorContains(t, tagStorage.SetAccountTags(uuid.New(), []string{"hot"}), "account not found")
+	for _, invalid := range []string{"", "with space", strings.Repeat("ab", 32)} {
+		require.ErrorContains(t, tagStorage.SetAccountTags(acc1.ID(), []string{invalid}), "invalid tag")
+	}
+}
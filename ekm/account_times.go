@@ -0,0 +1,93 @@
+package ekm
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// AccountTimeStorage lists accounts by the time they were last saved.
+type AccountTimeStorage interface {
+	ListAccountsModifiedSince(t time.Time) ([]core.ValidatorAccount, error)
+}
+
+var _ AccountTimeStorage = (*storage)(nil)
+
+// accountTimes records when an account was created and last saved. It's stored unencrypted
+// next to the account, so that accounts can be scanned by time without decrypting them.
+type accountTimes struct {
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// touchAccount records that the account under the given key was saved now.
+func (s *storage) touchAccount(rw basedb.ReadWriter, key []byte) error {
+	now := s.now()
+	times := accountTimes{CreatedAt: now, UpdatedAt: now}
+
+	obj, found, err := rw.Get(s.objPrefix(accountTimesPrefix), key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account times")
+	}
+	if found {
+		var stored accountTimes
+		if err := json.Unmarshal(obj.Value, &stored); err == nil {
+			times.CreatedAt = stored.CreatedAt
+		}
+	}
+
+	data, err := json.Marshal(times)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal account times")
+	}
+	return rw.Set(s.objPrefix(accountTimesPrefix), key, data)
+}
+
+// ListAccountsModifiedSince returns the accounts which were created or saved after the given time.
+// Accounts saved before times were recorded are never returned.
+// It reads from the read replica if one is set.
+func (s *storage) ListAccountsModifiedSince(t time.Time) ([]core.ValidatorAccount, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	txn := s.reader().BeginRead()
+	defer txn.Discard()
+
+	var keys [][]byte
+	err := txn.GetAll(s.objPrefix(accountTimesPrefix), func(i int, obj basedb.Obj) error {
+		var times accountTimes
+		if err := json.Unmarshal(obj.Value, &times); err != nil {
+			return errors.Wrapf(err, "failed to unmarshal account times of %s", obj.Key)
+		}
+		if times.UpdatedAt.After(t) {
+			keys = append(keys, obj.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list account times")
+	}
+
+	ret := make([]core.ValidatorAccount, 0, len(keys))
+	err = txn.GetMany(s.objPrefix(accountsPrefix), keys, func(obj basedb.Obj) error {
+		value, release, err := s.decryptData(obj.Value)
+		if err != nil {
+			return errors.Wrap(err, "failed to decrypt account")
+		}
+		acc, err := s.decodeAccount(value)
+		release()
+		if err != nil {
+			return errors.Wrap(err, "failed to decode account")
+		}
+		ret = append(ret, acc)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list modified accounts")
+	}
+	return ret, nil
+}
@@ -0,0 +1,68 @@
+package ekm
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/utils/threshold"
+)
+
+func TestListAccountsModifiedSince(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+	s := signerStorage.(*storage)
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	newAccount := func() core.ValidatorAccount {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		index := 0
+		acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+		require.NoError(t, err)
+		return acc
+	}
+
+	acc1 := newAccount()
+	now = now.Add(time.Minute)
+	acc2 := newAccount()
+
+	accounts, err := signerStorage.(AccountTimeStorage).ListAccountsModifiedSince(now.Add(-time.Second))
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, acc2.ID(), accounts[0].ID())
+
+	// Saving an account again updates its modification time, but not its creation time.
+	now = now.Add(time.Minute)
+	require.NoError(t, signerStorage.SaveAccount(acc1))
+	accounts, err = signerStorage.(AccountTimeStorage).ListAccountsModifiedSince(now.Add(-time.Second))
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, acc1.ID(), accounts[0].ID())
+
+	obj, found, err := s.db.Get(s.objPrefix(accountTimesPrefix), []byte(fmt.Sprintf(accountsPath, acc1.ID().String())))
+	require.NoError(t, err)
+	require.True(t, found)
+	var times accountTimes
+	require.NoError(t, json.Unmarshal(obj.Value, &times))
+	require.True(t, times.CreatedAt.Before(times.UpdatedAt))
+
+	// Deleted accounts are no longer listed.
+	require.NoError(t, signerStorage.DeleteAccount(acc1.ID()))
+	accounts, err = signerStorage.(AccountTimeStorage).ListAccountsModifiedSince(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, acc2.ID(), accounts[0].ID())
+}
@@ -0,0 +1,112 @@
+package ekm
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// attestationHistoryEntrySize is the size of an attestation in the highest attestation history,
+// which holds the SSZ encoded attestations one after the other, oldest first.
+const attestationHistoryEntrySize = 128
+
+// RetrieveHighestAttestationAsOf returns the highest attestation which was in effect at the given epoch,
+// that is, the latest highest attestation whose target epoch isn't after it. It requires WithHighestAttestationHistory,
+// and only the retained updates are searched, so it returns false if they all target later epochs.
+func (s *storage) RetrieveHighestAttestationAsOf(pubKey []byte, epoch phase0.Epoch) (*phase0.AttestationData, bool, error) {
+	if s.attHistorySize == 0 {
+		return nil, false, errors.New("highest attestation history is not enabled")
+	}
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, false, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	obj, found, err := s.getHighest(s.objPrefix(highestAttHistoryPrefix), pubKey)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not get highest attestation history")
+	}
+	if !found {
+		return nil, false, nil
+	}
+	history, err := decodeAttestationHistory(obj.Value)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not unmarshal highest attestation history")
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Target.Epoch <= epoch {
+			return history[i], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// attestationHistoryWrite returns the write which appends the given highest attestation to the history
+// of the given public key, or nil if the history isn't enabled.
+func (s *storage) attestationHistoryWrite(pubKey []byte, att *phase0.AttestationData) (*highestWrite, error) {
+	if s.attHistorySize == 0 {
+		return nil, nil
+	}
+	historyPrefix := s.objPrefix(highestAttHistoryPrefix)
+	obj, _, err := s.getHighest(historyPrefix, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get highest attestation history")
+	}
+	history, err := s.appendAttestationHistory(obj.Value, att)
+	if err != nil {
+		return nil, err
+	}
+	return &highestWrite{prefix: historyPrefix, key: pubKey, value: history}, nil
+}
+
+// appendAttestationHistoryTxn appends the given highest attestation to the history of the given public key
+// within the given transaction, if the history is enabled.
+func (s *storage) appendAttestationHistoryTxn(txn basedb.Txn, pubKey []byte, att *phase0.AttestationData) error {
+	if s.attHistorySize == 0 {
+		return nil
+	}
+	obj, _, err := txn.Get(s.objPrefix(highestAttHistoryPrefix), pubKey)
+	if err != nil {
+		return errors.Wrapf(err, "could not get highest attestation history of %x", pubKey)
+	}
+	history, err := s.appendAttestationHistory(obj.Value, att)
+	if err != nil {
+		return err
+	}
+	return txn.Set(s.objPrefix(highestAttHistoryPrefix), pubKey, history)
+}
+
+// appendAttestationHistory returns the given encoded history with the given attestation appended,
+// dropping the oldest attestations beyond the history size.
+func (s *storage) appendAttestationHistory(history []byte, att *phase0.AttestationData) ([]byte, error) {
+	if len(history)%attestationHistoryEntrySize != 0 {
+		return nil, errors.Errorf("highest attestation history has invalid length %d", len(history))
+	}
+	if excess := len(history)/attestationHistoryEntrySize + 1 - s.attHistorySize; excess > 0 {
+		history = history[excess*attestationHistoryEntrySize:]
+	}
+	ret, err := att.MarshalSSZTo(append([]byte{}, history...))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal attestation")
+	}
+	return ret, nil
+}
+
+func decodeAttestationHistory(data []byte) ([]*phase0.AttestationData, error) {
+	if len(data)%attestationHistoryEntrySize != 0 {
+		return nil, errors.Errorf("invalid length %d", len(data))
+	}
+	history := make([]*phase0.AttestationData, 0, len(data)/attestationHistoryEntrySize)
+	for len(data) > 0 {
+		att := &phase0.AttestationData{}
+		if err := att.UnmarshalSSZ(data[:attestationHistoryEntrySize]); err != nil {
+			return nil, err
+		}
+		history = append(history, att)
+		data = data[attestationHistoryEntrySize:]
+	}
+	return history, nil
+}
@@ -0,0 +1,264 @@
+package ekm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	apiv1capella "github.com/attestantio/go-eth2-client/api/v1/capella"
+	apiv1deneb "github.com/attestantio/go-eth2-client/api/v1/deneb"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/pkg/errors"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+
+	"github.com/ssvlabs/ssv/protocol/v2/blockchain/beacon"
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+const auditLogPrefix = prefix + "audit_log-"
+
+// AuditRecord is a record of a signature produced by the signer.
+type AuditRecord struct {
+	PubKey     []byte            `json:"pubKey"`
+	DomainType phase0.DomainType `json:"domainType"`
+	Domain     phase0.Domain     `json:"domain"`
+	Root       []byte            `json:"root"`
+	// Slot is the slot of the signed object, if it has one.
+	Slot *phase0.Slot `json:"slot,omitempty"`
+	// Epoch is the epoch of the signed object, if it has an epoch rather than a slot.
+	Epoch *phase0.Epoch `json:"epoch,omitempty"`
+	Time  time.Time     `json:"time"`
+}
+
+// AuditLog queries the records of the signatures made by the signer.
+type AuditLog interface {
+	QueryAuditLog(pubKey []byte, from, to time.Time) ([]*AuditRecord, error)
+}
+
+var _ AuditLog = (*AuditingKeyManager)(nil)
+
+// AuditingKeyManager is a KeyManager which records every signature it produces in an append-only audit log.
+// A signature is only returned once its record is stored.
+type AuditingKeyManager struct {
+	KeyManager
+	db      basedb.Database
+	network beacon.BeaconNetwork
+	now     func() time.Time
+
+	lock sync.Mutex
+	seq  uint64
+}
+
+// NewAuditingKeyManager returns a KeyManager which signs with the given KeyManager,
+// and records its signatures in the given database.
+func NewAuditingKeyManager(km KeyManager, db basedb.Database, network beacon.BeaconNetwork) *AuditingKeyManager {
+	return &AuditingKeyManager{
+		KeyManager: km,
+		db:         db,
+		network:    network,
+		now:        time.Now,
+	}
+}
+
+func (a *AuditingKeyManager) SignBeaconObject(obj ssz.HashRoot, domain phase0.Domain, pk []byte, domainType phase0.DomainType) (spectypes.Signature, [32]byte, error) {
+	signature, root, err := a.KeyManager.SignBeaconObject(obj, domain, pk, domainType)
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+
+	record := &AuditRecord{
+		PubKey:     pk,
+		DomainType: domainType,
+		Domain:     domain,
+		Root:       root[:],
+	}
+	record.Slot, record.Epoch = objectSlotOrEpoch(obj, domainType)
+	if err := a.append(record); err != nil {
+		return nil, [32]byte{}, err
+	}
+	return signature, root, nil
+}
+
+func (a *AuditingKeyManager) SignRoot(data spectypes.Root, sigType spectypes.SignatureType, pk []byte) (spectypes.Signature, error) {
+	signature, err := a.KeyManager.SignRoot(data, sigType, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := data.GetRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get root")
+	}
+	if err := a.append(&AuditRecord{
+		PubKey:     pk,
+		DomainType: phase0.DomainType(sigType),
+		Root:       root[:],
+	}); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// The StorageProvider methods are forwarded to the wrapped KeyManager, so that wrapping it
+// doesn't hide its storage from callers which need it.
+
+func (a *AuditingKeyManager) ListAccounts() ([]core.ValidatorAccount, error) {
+	sp, err := a.storageProvider()
+	if err != nil {
+		return nil, err
+	}
+	return sp.ListAccounts()
+}
+
+func (a *AuditingKeyManager) ListAccountsWithStatus() ([]AccountStatus, error) {
+	sp, err := a.storageProvider()
+	if err != nil {
+		return nil, err
+	}
+	return sp.ListAccountsWithStatus()
+}
+
+func (a *AuditingKeyManager) RetrieveHighestAttestation(pubKey []byte) (*phase0.AttestationData, bool, error) {
+	sp, err := a.storageProvider()
+	if err != nil {
+		return nil, false, err
+	}
+	return sp.RetrieveHighestAttestation(pubKey)
+}
+
+func (a *AuditingKeyManager) RetrieveHighestProposal(pubKey []byte) (phase0.Slot, bool, error) {
+	sp, err := a.storageProvider()
+	if err != nil {
+		return 0, false, err
+	}
+	return sp.RetrieveHighestProposal(pubKey)
+}
+
+func (a *AuditingKeyManager) BumpSlashingProtection(pubKey []byte) error {
+	sp, err := a.storageProvider()
+	if err != nil {
+		return err
+	}
+	return sp.BumpSlashingProtection(pubKey)
+}
+
+func (a *AuditingKeyManager) storageProvider() (StorageProvider, error) {
+	sp, ok := a.KeyManager.(StorageProvider)
+	if !ok {
+		return nil, errors.New("key manager doesn't provide its storage")
+	}
+	return sp, nil
+}
+
+// QueryAuditLog returns the records of the signatures made with the given public key
+// between from (inclusive) and to (exclusive), in the order they were made.
+// Records are keyed by time, so only the records in the range are read.
+func (a *AuditingKeyManager) QueryAuditLog(pubKey []byte, from, to time.Time) ([]*AuditRecord, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+	pubKeyPrefix := append(a.objPrefix(), pubKey...)
+	var records []*AuditRecord
+	err := a.db.GetRange(pubKeyPrefix, auditTimeKey(from), auditTimeKey(to), func(i int, obj basedb.Obj) error {
+		record := &AuditRecord{}
+		if err := json.Unmarshal(obj.Value, record); err != nil {
+			return errors.Wrap(err, "could not unmarshal audit record")
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query audit log")
+	}
+	return records, nil
+}
+
+// auditTimeKey returns the key part of records made at the given time, which sorts by time.
+// Times outside of the range of UnixNano are clamped to it.
+func auditTimeKey(t time.Time) []byte {
+	var nanos uint64
+	switch {
+	case t.Before(time.Unix(0, 0)):
+		nanos = 0
+	case t.After(time.Unix(0, math.MaxInt64)):
+		nanos = math.MaxInt64
+	default:
+		nanos = uint64(t.UnixNano())
+	}
+	return binary.BigEndian.AppendUint64(nil, nanos)
+}
+
+// append stores the given record, keyed by its public key, time and a sequence number,
+// so that records are never overwritten and are iterated in order.
+func (a *AuditingKeyManager) append(record *AuditRecord) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	record.Time = a.now()
+	a.seq++
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal audit record")
+	}
+
+	key := make([]byte, 0, len(record.PubKey)+16)
+	key = append(key, record.PubKey...)
+	key = append(key, auditTimeKey(record.Time)...)
+	key = binary.BigEndian.AppendUint64(key, a.seq)
+
+	if err := a.db.Set(a.objPrefix(), key, data); err != nil {
+		return errors.Wrap(err, "could not append audit record")
+	}
+	return nil
+}
+
+func (a *AuditingKeyManager) objPrefix() []byte {
+	return []byte(string(a.network.GetBeaconNetwork()) + auditLogPrefix)
+}
+
+// objectSlotOrEpoch returns the slot or the epoch of the given signed object, if it has one.
+func objectSlotOrEpoch(obj ssz.HashRoot, domainType phase0.DomainType) (*phase0.Slot, *phase0.Epoch) {
+	slot := func(s phase0.Slot) (*phase0.Slot, *phase0.Epoch) { return &s, nil }
+	epoch := func(e phase0.Epoch) (*phase0.Slot, *phase0.Epoch) { return nil, &e }
+
+	switch v := obj.(type) {
+	case *phase0.AttestationData:
+		return slot(v.Slot)
+	case *capella.BeaconBlock:
+		return slot(v.Slot)
+	case *deneb.BeaconBlock:
+		return slot(v.Slot)
+	case *apiv1capella.BlindedBeaconBlock:
+		return slot(v.Slot)
+	case *apiv1deneb.BlindedBeaconBlock:
+		return slot(v.Slot)
+	case *phase0.VoluntaryExit:
+		return epoch(v.Epoch)
+	case *phase0.AggregateAndProof:
+		if v.Aggregate != nil && v.Aggregate.Data != nil {
+			return slot(v.Aggregate.Data.Slot)
+		}
+	case *altair.SyncAggregatorSelectionData:
+		return slot(v.Slot)
+	case *altair.ContributionAndProof:
+		if v.Contribution != nil {
+			return slot(v.Contribution.Slot)
+		}
+	case spectypes.SSZUint64:
+		switch domainType {
+		case spectypes.DomainSelectionProof:
+			return slot(phase0.Slot(v))
+		case spectypes.DomainRandao:
+			return epoch(phase0.Epoch(v))
+		}
+	}
+	return nil, nil
+}
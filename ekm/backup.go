@@ -0,0 +1,401 @@
+package ekm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// A backup is a header followed by a stream of chunks, each holding up to backupChunkSize bytes of records
+// sealed with AES-GCM:
+//
+//	header: magic (4) | version (1) | nonce prefix (7) | network length (1) | network
+//	chunk:  final flag (1) | ciphertext length (4, big-endian) | ciphertext
+//
+// The nonce of chunk i is the nonce prefix, followed by i as a 4 byte big-endian counter and the final flag,
+// and the header is authenticated as additional data of every chunk. Thus chunks can't be reordered, dropped,
+// moved between backups or truncated without failing authentication. The last chunk is flagged as final,
+// and may be empty.
+//
+// The decrypted chunks form a stream of records, each a stored key and value prefixed by their
+// big-endian uint32 lengths. Values are backed up as stored, so accounts stay encrypted with the storage's
+// encryption key, and the restored storage must be given the same key to open them.
+const (
+	backupMagic          = "SSVB"
+	backupVersion        = 1
+	backupNoncePrefixLen = 7
+	backupChunkSize      = 64 << 10
+	backupRestoreBatch   = 1000
+	maxBackupRecordSize  = 1 << 20
+)
+
+// BackupWallet writes all signer data of the storage's network to the given writer, encrypted
+// with the given hex-encoded AES key. Data is encrypted in chunks as it's read, so memory use is bounded
+// regardless of the number of accounts.
+func (s *storage) BackupWallet(w io.Writer, key string) error {
+	aead, err := newBackupCipher(key)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return errors.Wrap(err, "could not flush highest values")
+	}
+
+	header, err := newBackupHeader(string(s.network.GetBeaconNetwork()))
+	if err != nil {
+		return err
+	}
+	bw, err := newBackupWriter(w, aead, header, backupChunkSize)
+	if err != nil {
+		return err
+	}
+
+	var lengths [8]byte
+	err = s.db.GetAll(s.objPrefix(prefix), func(i int, obj basedb.Obj) error {
+		binary.BigEndian.PutUint32(lengths[:4], uint32(len(obj.Key)))
+		binary.BigEndian.PutUint32(lengths[4:], uint32(len(obj.Value)))
+		for _, b := range [][]byte{lengths[:], obj.Key, obj.Value} {
+			if _, err := bw.Write(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not write backup")
+	}
+	return bw.Close()
+}
+
+// RestoreWallet restores a backup written by BackupWallet with the given hex-encoded AES key.
+// The backup must be of the storage's network, and the storage must not have a wallet yet.
+// Every chunk is authenticated as it's read, and records are staged under a separate prefix until the final chunk
+// authenticates, so a tampered or truncated backup leaves the storage as it was. The wallet is written last,
+// so a restore interrupted while moving the staged records in can be retried.
+func (s *storage) RestoreWallet(r io.Reader, key string) error {
+	aead, err := newBackupCipher(key)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer s.resetDisabled()
+
+	_, found, err := s.db.Get(s.objPrefix(walletPrefix), []byte(walletPath))
+	if err != nil {
+		return errors.Wrap(err, "could not get wallet")
+	}
+	if found {
+		return errors.New("storage already has a wallet")
+	}
+
+	br, err := newBackupReader(r, aead, string(s.network.GetBeaconNetwork()))
+	if err != nil {
+		return err
+	}
+
+	// The prefixes have no spare capacity, so that keys appended to them don't share memory before they're written.
+	stagingPrefix := s.objPrefix(restorePrefix)
+	stagingPrefix = stagingPrefix[:len(stagingPrefix):len(stagingPrefix)]
+	dataPrefix := s.objPrefix(prefix)
+	dataPrefix = dataPrefix[:len(dataPrefix):len(dataPrefix)]
+
+	// Records staged by an earlier restore which failed are dropped.
+	if err := s.db.DropPrefix(stagingPrefix); err != nil {
+		return errors.Wrap(err, "could not drop staged records")
+	}
+	if err := s.stageBackup(br, stagingPrefix); err != nil {
+		if dropErr := s.db.DropPrefix(stagingPrefix); dropErr != nil {
+			s.logger.Warn("could not drop staged records", zap.Error(dropErr))
+		}
+		return err
+	}
+	if err := s.commitStaged(stagingPrefix, dataPrefix); err != nil {
+		return err
+	}
+	if err := s.db.DropPrefix(stagingPrefix); err != nil {
+		return errors.Wrap(err, "could not drop staged records")
+	}
+	return nil
+}
+
+// stageBackup writes the records of the given backup under the given staging prefix, in batches as they're read.
+func (s *storage) stageBackup(br *backupReader, stagingPrefix []byte) error {
+	batch := make([]basedb.Obj, 0, backupRestoreBatch)
+	writeBatch := func() error {
+		err := s.db.SetMany(stagingPrefix, len(batch), func(i int) (basedb.Obj, error) {
+			return batch[i], nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	var lengths [8]byte
+	for {
+		if _, err := io.ReadFull(br, lengths[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.Wrap(err, "could not read record")
+		}
+		keyLen, valueLen := binary.BigEndian.Uint32(lengths[:4]), binary.BigEndian.Uint32(lengths[4:])
+		if keyLen > maxBackupRecordSize || valueLen > maxBackupRecordSize {
+			return errors.New("record is too large")
+		}
+		obj := basedb.Obj{
+			Key:   make([]byte, keyLen),
+			Value: make([]byte, valueLen),
+		}
+		if _, err := io.ReadFull(br, obj.Key); err != nil {
+			return errors.Wrap(noEOF(err), "could not read record key")
+		}
+		if _, err := io.ReadFull(br, obj.Value); err != nil {
+			return errors.Wrap(noEOF(err), "could not read record value")
+		}
+		batch = append(batch, obj)
+		if len(batch) == backupRestoreBatch {
+			if err := writeBatch(); err != nil {
+				return errors.Wrap(err, "could not stage records")
+			}
+		}
+	}
+	if err := writeBatch(); err != nil {
+		return errors.Wrap(err, "could not stage records")
+	}
+	return nil
+}
+
+// commitStaged copies the staged records to the given data prefix in batches, writing the wallet last.
+func (s *storage) commitStaged(stagingPrefix, dataPrefix []byte) error {
+	walletKey := []byte(walletPrefix[len(prefix):] + walletPath)
+
+	var wallet *basedb.Obj
+	batch := make([]basedb.Obj, 0, backupRestoreBatch)
+	writeBatch := func() error {
+		err := s.db.SetMany(dataPrefix, len(batch), func(i int) (basedb.Obj, error) {
+			return batch[i], nil
+		})
+		batch = batch[:0]
+		return err
+	}
+	err := s.db.GetAll(stagingPrefix, func(i int, obj basedb.Obj) error {
+		if bytes.Equal(obj.Key, walletKey) {
+			wallet = &obj
+			return nil
+		}
+		batch = append(batch, obj)
+		if len(batch) == backupRestoreBatch {
+			return writeBatch()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeBatch()
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not write records")
+	}
+	if wallet != nil {
+		if err := s.db.Set(dataPrefix, wallet.Key, wallet.Value); err != nil {
+			return errors.Wrap(err, "could not write wallet")
+		}
+	}
+	return nil
+}
+
+func newBackupCipher(key string) (cipher.AEAD, error) {
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("the key must be a valid hexadecimal string")
+	}
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid backup key")
+	}
+	return cipher.NewGCM(block)
+}
+
+func newBackupHeader(network string) ([]byte, error) {
+	if len(network) > 255 {
+		return nil, errors.Errorf("network name is too long: %d bytes", len(network))
+	}
+	header := append([]byte(backupMagic), backupVersion)
+	noncePrefix := make([]byte, backupNoncePrefixLen)
+	if _, err := io.ReadFull(rand.Reader, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "could not generate nonce prefix")
+	}
+	header = append(header, noncePrefix...)
+	header = append(header, byte(len(network)))
+	return append(header, network...), nil
+}
+
+// backupNonce returns the nonce of the given chunk.
+func backupNonce(header []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, gcmNonceSize)
+	nonce = append(nonce, header[len(backupMagic)+1:][:backupNoncePrefixLen]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// backupWriter seals the data written to it in chunks.
+type backupWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	header    []byte
+	buf       []byte
+	chunkSize int
+	counter   uint32
+}
+
+func newBackupWriter(w io.Writer, aead cipher.AEAD, header []byte, chunkSize int) (*backupWriter, error) {
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "could not write backup header")
+	}
+	return &backupWriter{
+		w:         w,
+		aead:      aead,
+		header:    header,
+		buf:       make([]byte, 0, chunkSize),
+		chunkSize: chunkSize,
+	}, nil
+}
+
+func (bw *backupWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), bw.chunkSize-len(bw.buf))
+		bw.buf = append(bw.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(bw.buf) == bw.chunkSize {
+			if err := bw.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the final chunk. It doesn't close the underlying writer.
+func (bw *backupWriter) Close() error {
+	return bw.writeChunk(true)
+}
+
+func (bw *backupWriter) writeChunk(final bool) error {
+	if bw.counter == ^uint32(0) {
+		return errors.New("backup is too large")
+	}
+	sealed := bw.aead.Seal(nil, backupNonce(bw.header, bw.counter, final), bw.buf, bw.header)
+	bw.counter++
+	bw.buf = bw.buf[:0]
+
+	frame := make([]byte, 5, 5+len(sealed))
+	if final {
+		frame[0] = 1
+	}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(sealed)))
+	if _, err := bw.w.Write(append(frame, sealed...)); err != nil {
+		return errors.Wrap(err, "could not write backup chunk")
+	}
+	return nil
+}
+
+// backupReader opens the chunks of a backup as they're read, and returns io.EOF after the final chunk.
+type backupReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint32
+	final   bool
+}
+
+func newBackupReader(r io.Reader, aead cipher.AEAD, network string) (*backupReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(backupMagic)+1+backupNoncePrefixLen+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, errors.Wrap(noEOF(err), "could not read backup header")
+	}
+	if !bytes.Equal(header[:len(backupMagic)], []byte(backupMagic)) {
+		return nil, errors.New("not a signer storage backup")
+	}
+	if version := header[len(backupMagic)]; version != backupVersion {
+		return nil, errors.Errorf("unsupported backup version %d", version)
+	}
+	backupNetwork := make([]byte, header[len(header)-1])
+	if _, err := io.ReadFull(br, backupNetwork); err != nil {
+		return nil, errors.Wrap(noEOF(err), "could not read backup header")
+	}
+	if string(backupNetwork) != network {
+		return nil, errors.Errorf("backup network %q does not match storage network %q", backupNetwork, network)
+	}
+	return &backupReader{
+		r:      br,
+		aead:   aead,
+		header: append(header, backupNetwork...),
+	}, nil
+}
+
+func (br *backupReader) Read(p []byte) (int, error) {
+	for len(br.buf) == 0 {
+		if br.final {
+			return 0, io.EOF
+		}
+		if err := br.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, br.buf)
+	br.buf = br.buf[n:]
+	return n, nil
+}
+
+func (br *backupReader) readChunk() error {
+	var frame [5]byte
+	if _, err := io.ReadFull(br.r, frame[:]); err != nil {
+		return errors.Wrap(noEOF(err), "could not read backup chunk")
+	}
+	final := frame[0] == 1
+	size := binary.BigEndian.Uint32(frame[1:])
+	if size > backupChunkSize+uint32(br.aead.Overhead()) {
+		return errors.New("backup chunk is too large")
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(br.r, sealed); err != nil {
+		return errors.Wrap(noEOF(err), "could not read backup chunk")
+	}
+	opened, err := br.aead.Open(sealed[:0], backupNonce(br.header, br.counter, final), sealed, br.header)
+	if err != nil {
+		return errors.Errorf("could not authenticate backup chunk %d", br.counter)
+	}
+	br.counter++
+	br.buf = opened
+	br.final = final
+	return nil
+}
+
+// noEOF reports io.EOF as io.ErrUnexpectedEOF, for reads which must not end the stream.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
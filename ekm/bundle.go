@@ -0,0 +1,131 @@
+package ekm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// BundleStorage exports the stored state of a single validator.
+type BundleStorage interface {
+	ExportValidatorBundle(pubKey []byte, includeKey bool) ([]byte, error)
+}
+
+var _ BundleStorage = (*storage)(nil)
+
+// validatorBundleVersion is the schema version of bundles written by ExportValidatorBundle.
+const validatorBundleVersion = 1
+
+// validatorBundle holds the full stored state of a single validator.
+type validatorBundle struct {
+	Version                  int                     `json:"version"`
+	Network                  string                  `json:"network"`
+	PubKey                   string                  `json:"pubKey"`
+	Account                  json.RawMessage         `json:"account"`
+	Enabled                  bool                    `json:"enabled"`
+	HighestAttestation       *phase0.AttestationData `json:"highestAttestation,omitempty"`
+	HighestProposal          *phase0.Slot            `json:"highestProposal,omitempty"`
+	HighestRandaoEpoch       *phase0.Epoch           `json:"highestRandaoEpoch,omitempty"`
+	HighestSyncCommitteeSlot *phase0.Slot            `json:"highestSyncCommitteeSlot,omitempty"`
+}
+
+// redactedAccount is the account of a bundle exported without its key.
+type redactedAccount struct {
+	ID                  uuid.UUID `json:"id"`
+	Name                string    `json:"name"`
+	BasePath            string    `json:"basePath"`
+	ValidatorPublicKey  string    `json:"validatorPublicKey"`
+	WithdrawalPublicKey string    `json:"withdrawalPublicKey"`
+}
+
+// ExportValidatorBundle returns the account and highest values of the given public key as a
+// single JSON document, for moving a validator between operators or inspecting its state.
+// The account's secret key is only included if includeKey is true.
+func (s *storage) ExportValidatorBundle(pubKey []byte, includeKey bool) ([]byte, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return nil, errors.Wrap(err, "could not flush highest values")
+	}
+
+	accounts, err := s.listAccounts(s.db)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list accounts")
+	}
+	bundle := validatorBundle{
+		Version: validatorBundleVersion,
+		Network: string(s.network.GetBeaconNetwork()),
+		PubKey:  hex.EncodeToString(pubKey),
+	}
+	for _, account := range accounts {
+		if !bytes.Equal(account.ValidatorPublicKey(), pubKey) {
+			continue
+		}
+		if includeKey {
+			bundle.Account, err = json.Marshal(account)
+		} else {
+			bundle.Account, err = json.Marshal(redactedAccount{
+				ID:                  account.ID(),
+				Name:                account.Name(),
+				BasePath:            account.BasePath(),
+				ValidatorPublicKey:  hex.EncodeToString(account.ValidatorPublicKey()),
+				WithdrawalPublicKey: hex.EncodeToString(account.WithdrawalPublicKey()),
+			})
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal account")
+		}
+
+		key := []byte(fmt.Sprintf(accountsPath, account.ID().String()))
+		disabled, err := s.isDisabled(key)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Enabled = !disabled
+		break
+	}
+	if bundle.Account == nil {
+		return nil, errors.Errorf("no account found for public key %x", pubKey)
+	}
+
+	att, found, err := s.retrieveHighestAttestation(pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve highest attestation")
+	}
+	if found {
+		bundle.HighestAttestation = att
+	}
+	proposal, found, err := s.retrieveHighestProposal(pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve highest proposal")
+	}
+	if found {
+		bundle.HighestProposal = &proposal
+	}
+	epoch, _, found, err := s.retrieveHighestRandaoEpoch(pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve highest randao epoch")
+	}
+	if found {
+		bundle.HighestRandaoEpoch = &epoch
+	}
+	syncSlot, found, err := s.retrieveHighestSyncCommitteeSlot(pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve highest sync committee slot")
+	}
+	if found {
+		bundle.HighestSyncCommitteeSlot = &syncSlot
+	}
+
+	return json.Marshal(bundle)
+}
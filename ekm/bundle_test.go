@@ -0,0 +1,52 @@
+package ekm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportValidatorBundle(t *testing.T) {
+	wallet, signerStorage, done := testWallet(t)
+	defer done()
+
+	accounts := wallet.Accounts()
+	require.Len(t, accounts, 1)
+	acc := accounts[0]
+	pubKey := acc.ValidatorPublicKey()
+
+	_, err := signerStorage.(BundleStorage).ExportValidatorBundle(make([]byte, 48), false)
+	require.ErrorContains(t, err, "no account found")
+
+	att := &phase0.AttestationData{
+		Slot:   10,
+		Source: &phase0.Checkpoint{Epoch: 1, Root: [32]byte{}},
+		Target: &phase0.Checkpoint{Epoch: 2, Root: [32]byte{}},
+	}
+	require.NoError(t, signerStorage.SaveHighestAttestation(pubKey, att))
+	require.NoError(t, signerStorage.SaveHighestProposal(pubKey, 20))
+	require.NoError(t, signerStorage.SetAccountEnabled(acc.ID(), false))
+
+	data, err := signerStorage.(BundleStorage).ExportValidatorBundle(pubKey, false)
+	require.NoError(t, err)
+	var bundle validatorBundle
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	require.Equal(t, validatorBundleVersion, bundle.Version)
+	require.Equal(t, string(signerStorage.BeaconNetwork().GetBeaconNetwork()), bundle.Network)
+	require.Equal(t, hex.EncodeToString(pubKey), bundle.PubKey)
+	require.False(t, bundle.Enabled)
+	require.Equal(t, att, bundle.HighestAttestation)
+	require.NotNil(t, bundle.HighestProposal)
+	require.EqualValues(t, 20, *bundle.HighestProposal)
+	require.Nil(t, bundle.HighestRandaoEpoch)
+	require.Nil(t, bundle.HighestSyncCommitteeSlot)
+	require.NotContains(t, string(bundle.Account), "privKey")
+
+	data, err = signerStorage.(BundleStorage).ExportValidatorBundle(pubKey, true)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	require.Contains(t, string(bundle.Account), "privKey")
+}
@@ -0,0 +1,117 @@
+package ekm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// canaryPlaintext is the fixed plaintext of the canary, which is stored encrypted with the encryption key
+// so that a wrong key can be detected up front rather than when accounts are decrypted.
+var canaryPlaintext = []byte("ssv signer storage canary")
+
+// ErrWrongEncryptionKey is returned by CheckEncryptionKey when the encryption key doesn't decrypt the stored data.
+var ErrWrongEncryptionKey = errors.New("encryption key does not match the stored data")
+
+// CheckEncryptionKey returns ErrWrongEncryptionKey if the canary doesn't decrypt with the encryption key,
+// or, in stores without a canary, if the first encrypted account doesn't. It's meant to be called right after
+// SetEncryptionKey on startup, to fail fast rather than when accounts are decrypted.
+func (s *storage) CheckEncryptionKey() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.encryptionKey) == 0 {
+		return nil
+	}
+
+	obj, found, err := s.db.Get(s.objPrefix(canaryPrefix), []byte(canaryPath))
+	if err != nil {
+		return errors.Wrap(err, "could not get canary")
+	}
+	if found {
+		if plaintext, err := decryptWithKey(s.encryptionKey, obj.Value); err != nil || !bytes.Equal(plaintext, canaryPlaintext) {
+			return ErrWrongEncryptionKey
+		}
+		return nil
+	}
+
+	account, err := s.firstEncryptedAccount()
+	if err != nil {
+		return err
+	}
+	if account != nil {
+		if _, err := s.decrypt(account); err != nil {
+			return ErrWrongEncryptionKey
+		}
+	}
+	return nil
+}
+
+// refreshCanary stores a canary encrypted with the given key, if the store has none. A canary which only
+// decrypts with one of the given secondary keys is re-encrypted with the given key. A canary is not stored
+// if it would contradict the stored data, that is, if it doesn't decrypt with any of the keys,
+// or if the store has no canary and its first encrypted account doesn't decrypt with any of the keys.
+func (s *storage) refreshCanary(key []byte, secondaryKeys [][]byte) error {
+	obj, found, err := s.db.Get(s.objPrefix(canaryPrefix), []byte(canaryPath))
+	if err != nil {
+		return errors.Wrap(err, "could not get canary")
+	}
+	if found {
+		if plaintext, err := decryptWithKey(key, obj.Value); err == nil && bytes.Equal(plaintext, canaryPlaintext) {
+			return nil
+		}
+		for _, k := range secondaryKeys {
+			if plaintext, err := decryptWithKey(k, obj.Value); err == nil && bytes.Equal(plaintext, canaryPlaintext) {
+				return s.saveCanary(key)
+			}
+		}
+		return nil
+	}
+
+	account, err := s.firstEncryptedAccount()
+	if err != nil {
+		return err
+	}
+	if account != nil && !decryptsWithAny(append([][]byte{key}, secondaryKeys...), account) {
+		return nil
+	}
+	return s.saveCanary(key)
+}
+
+func (s *storage) saveCanary(key []byte) error {
+	encrypted, err := s.encryptWithKey(key, canaryPlaintext)
+	if err != nil {
+		return errors.Wrap(err, "could not encrypt canary")
+	}
+	if err := s.db.Set(s.objPrefix(canaryPrefix), []byte(canaryPath), encrypted); err != nil {
+		return errors.Wrap(err, "could not save canary")
+	}
+	return nil
+}
+
+// firstEncryptedAccount returns the stored value of the first encrypted account, or nil if there's none.
+func (s *storage) firstEncryptedAccount() ([]byte, error) {
+	var encrypted []byte
+	err := s.db.GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+		if isPlainAccount(obj.Value) {
+			return nil
+		}
+		encrypted = obj.Value
+		return errEncryptedFound
+	})
+	if err != nil && !errors.Is(err, errEncryptedFound) {
+		return nil, errors.Wrap(err, "could not list accounts")
+	}
+	return encrypted, nil
+}
+
+func decryptsWithAny(keys [][]byte, data []byte) bool {
+	for _, k := range keys {
+		if _, err := decryptWithKey(k, data); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Package ekmtest provides helpers for tests which need a populated ekm storage.
+package ekmtest
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/ekm"
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+	"github.com/ssvlabs/ssv/storage/basedb"
+	"github.com/ssvlabs/ssv/storage/kv"
+	"github.com/ssvlabs/ssv/utils/threshold"
+)
+
+// FixtureOptions configures NewFixture.
+type FixtureOptions struct {
+	// Accounts is the number of accounts to create, each with a random key.
+	Accounts int
+	// EncryptionKey is the hex encoded encryption key of the storage, if any.
+	EncryptionKey string
+	// HighestAttestation is the highest attestation to seed for every account, if any.
+	HighestAttestation *phase0.AttestationData
+	// HighestProposal is the highest proposal slot to seed for every account, if not zero.
+	HighestProposal phase0.Slot
+	// StorageOptions are passed to ekm.NewSignerStorage.
+	StorageOptions []ekm.StorageOption
+}
+
+// Fixture is an in-memory ekm storage with a wallet and accounts.
+type Fixture struct {
+	ekm.Storage
+
+	DB         basedb.Database
+	Wallet     core.Wallet
+	Accounts   []core.ValidatorAccount
+	SecretKeys []*bls.SecretKey
+}
+
+// NewFixture returns a Fixture populated according to the given options.
+// The underlying database is closed when the test finishes.
+func NewFixture(t *testing.T, opts FixtureOptions) *Fixture {
+	t.Helper()
+	threshold.Init()
+
+	logger := logging.TestLogger(t)
+	db, err := kv.NewInMemory(logger, basedb.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	signerStorage, err := ekm.NewSignerStorage(db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, opts.StorageOptions...)
+	require.NoError(t, err)
+	if opts.EncryptionKey != "" {
+		require.NoError(t, signerStorage.SetEncryptionKey(opts.EncryptionKey))
+	}
+
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	f := &Fixture{
+		Storage: signerStorage,
+		DB:      db,
+		Wallet:  wallet,
+	}
+	for i := 0; i < opts.Accounts; i++ {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+
+		index := i
+		acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+		require.NoError(t, err)
+
+		if opts.HighestAttestation != nil {
+			require.NoError(t, signerStorage.SaveHighestAttestation(acc.ValidatorPublicKey(), opts.HighestAttestation))
+		}
+		if opts.HighestProposal != 0 {
+			require.NoError(t, signerStorage.SaveHighestProposal(acc.ValidatorPublicKey(), opts.HighestProposal))
+		}
+
+		f.Accounts = append(f.Accounts, acc)
+		f.SecretKeys = append(f.SecretKeys, sk)
+	}
+	return f
+}
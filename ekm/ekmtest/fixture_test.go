@@ -0,0 +1,40 @@
+package ekmtest
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFixture(t *testing.T) {
+	attestation := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+	f := NewFixture(t, FixtureOptions{
+		Accounts:           3,
+		EncryptionKey:      "0123456789abcdef0123456789abcdef",
+		HighestAttestation: attestation,
+		HighestProposal:    10,
+	})
+
+	accounts, err := f.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+	require.Len(t, f.SecretKeys, 3)
+
+	for i, acc := range f.Accounts {
+		require.Equal(t, f.SecretKeys[i].GetPublicKey().Serialize(), acc.ValidatorPublicKey())
+
+		highest, found, err := f.RetrieveHighestAttestation(acc.ValidatorPublicKey())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, attestation, highest)
+
+		slot, found, err := f.RetrieveHighestProposal(acc.ValidatorPublicKey())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, phase0.Slot(10), slot)
+	}
+}
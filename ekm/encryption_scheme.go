@@ -0,0 +1,384 @@
+package ekm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// schemeUpgradeBatchSize is the number of accounts rewritten in each transaction of UpgradeEncryptionScheme.
+const schemeUpgradeBatchSize = 500
+
+// EncryptionScheme describes how accounts are encrypted with the key given to SetEncryptionKey.
+type EncryptionScheme struct {
+	// SaltedKey mixes the store's salt into the key, as with WithSaltedKeyDerivation.
+	SaltedKey bool `json:"salted_key"`
+	// Envelope encrypts accounts with a data key wrapped by the key, as with WithEnvelopeEncryption.
+	Envelope bool `json:"envelope"`
+}
+
+// schemeUpgrade is a pending UpgradeEncryptionScheme. It's stored before any account is rewritten,
+// so that an interrupted upgrade is resumed with the same salt and data key.
+type schemeUpgrade struct {
+	Scheme         EncryptionScheme `json:"scheme"`
+	Salt           []byte           `json:"salt,omitempty"`
+	WrappedDataKey []byte           `json:"wrapped_data_key,omitempty"`
+}
+
+// UpgradeEncryptionScheme re-encrypts the accounts, which are encrypted with the given hex encoded key under
+// the store's current scheme, under the given scheme with the same key, and then uses the key as if it was
+// given to SetEncryptionKey. The storage must be configured with the options of the given scheme.
+//
+// Accounts are rewritten in batches, each in its own transaction, and each rewritten account is decrypted
+// and compared to the original before its batch is committed. The store switches to the new scheme only once
+// all accounts are rewritten, so an interrupted upgrade is resumed by calling UpgradeEncryptionScheme again
+// with the same key and scheme, and calling it on a store which is already on the scheme does nothing.
+// Until the upgrade completes, SetEncryptionKey fails.
+func (s *storage) UpgradeEncryptionScheme(oldKey string, scheme EncryptionScheme) error {
+	if scheme.SaltedKey != s.saltedKeys || scheme.Envelope != s.envelope {
+		return errors.New("storage options do not match the encryption scheme")
+	}
+	key, err := hex.DecodeString(oldKey)
+	if err != nil || len(key) == 0 {
+		return errors.New("the key must be a valid hexadecimal string")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	current, err := s.encryptionScheme()
+	if err != nil {
+		return err
+	}
+	upgrade, err := s.pendingSchemeUpgrade()
+	if err != nil {
+		return err
+	}
+	if upgrade != nil && upgrade.Scheme != scheme {
+		return errors.Errorf("an upgrade to encryption scheme %+v is in progress", upgrade.Scheme)
+	}
+
+	salt, err := s.storedValue(saltPrefix, saltPath)
+	if err != nil {
+		return err
+	}
+	wrappedDataKey, err := s.storedValue(dataKeyPrefix, dataKeyPath)
+	if err != nil {
+		return err
+	}
+	oldMasterKey, oldAccountKey, err := s.schemeKeys(current, salt, wrappedDataKey, key)
+	if err != nil {
+		return err
+	}
+
+	resumed := upgrade != nil
+	if !resumed {
+		// The key is verified before a pending upgrade is stored with a data key wrapped by it.
+		if ok, err := s.matchesStoredData(oldAccountKey); err != nil {
+			return err
+		} else if !ok {
+			return ErrWrongEncryptionKey
+		}
+		if current == scheme {
+			return s.setSchemeKeys(scheme, oldMasterKey, oldAccountKey)
+		}
+		if upgrade, err = s.newSchemeUpgrade(scheme, key); err != nil {
+			return err
+		}
+	}
+	masterKey, accountKey, err := s.schemeKeys(scheme, upgrade.Salt, upgrade.WrappedDataKey, key)
+	if err != nil {
+		return err
+	}
+	if resumed {
+		// The canary is only rewritten once the upgrade completes, but the first account may have been
+		// rewritten before the upgrade was interrupted.
+		if ok, err := s.matchesStoredData(oldAccountKey, accountKey); err != nil {
+			return err
+		} else if !ok {
+			return ErrWrongEncryptionKey
+		}
+	}
+
+	rewritten, err := s.rewriteAccounts(oldAccountKey, accountKey)
+	if err != nil {
+		return err
+	}
+
+	encryptedCanary, err := s.encryptWithKey(accountKey, canaryPlaintext)
+	if err != nil {
+		return errors.Wrap(err, "could not encrypt canary")
+	}
+	schemeData, err := json.Marshal(scheme)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal encryption scheme")
+	}
+	err = s.db.Update(func(txn basedb.Txn) error {
+		if scheme.SaltedKey {
+			err = txn.Set(s.objPrefix(saltPrefix), []byte(saltPath), upgrade.Salt)
+		} else {
+			err = txn.Delete(s.objPrefix(saltPrefix), []byte(saltPath))
+		}
+		if err != nil {
+			return err
+		}
+		if scheme.Envelope {
+			err = txn.Set(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath), upgrade.WrappedDataKey)
+		} else {
+			err = txn.Delete(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath))
+		}
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(s.objPrefix(canaryPrefix), []byte(canaryPath), encryptedCanary); err != nil {
+			return err
+		}
+		if err := txn.Set(s.objPrefix(encryptionSchemePrefix), []byte(encryptionSchemePath), schemeData); err != nil {
+			return err
+		}
+		return txn.Delete(s.objPrefix(schemeUpgradePrefix), []byte(schemeUpgradePath))
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not complete encryption scheme upgrade")
+	}
+	s.sensitiveLogger().Info("upgraded encryption scheme", zap.Int("accounts", rewritten))
+
+	return s.setSchemeKeys(scheme, masterKey, accountKey)
+}
+
+// checkEncryptionScheme returns an error if an encryption scheme upgrade is in progress,
+// or if the store was upgraded to a scheme which the storage options don't match.
+func (s *storage) checkEncryptionScheme() error {
+	upgrade, err := s.pendingSchemeUpgrade()
+	if err != nil {
+		return err
+	}
+	if upgrade != nil {
+		return errors.New("an encryption scheme upgrade is in progress, it must be completed with UpgradeEncryptionScheme")
+	}
+
+	data, err := s.storedValue(encryptionSchemePrefix, encryptionSchemePath)
+	if err != nil || data == nil {
+		return err
+	}
+	var scheme EncryptionScheme
+	if err := json.Unmarshal(data, &scheme); err != nil {
+		return errors.Wrap(err, "could not unmarshal encryption scheme")
+	}
+	if scheme.SaltedKey != s.saltedKeys || scheme.Envelope != s.envelope {
+		return errors.Errorf("storage options do not match the stored encryption scheme %+v", scheme)
+	}
+	return nil
+}
+
+// encryptionScheme returns the store's encryption scheme. Stores which were never upgraded don't store it,
+// so it's inferred from the stored salt and data key.
+func (s *storage) encryptionScheme() (EncryptionScheme, error) {
+	data, err := s.storedValue(encryptionSchemePrefix, encryptionSchemePath)
+	if err != nil {
+		return EncryptionScheme{}, err
+	}
+	if data != nil {
+		var scheme EncryptionScheme
+		if err := json.Unmarshal(data, &scheme); err != nil {
+			return EncryptionScheme{}, errors.Wrap(err, "could not unmarshal encryption scheme")
+		}
+		return scheme, nil
+	}
+
+	salt, err := s.storedValue(saltPrefix, saltPath)
+	if err != nil {
+		return EncryptionScheme{}, err
+	}
+	dataKey, err := s.storedValue(dataKeyPrefix, dataKeyPath)
+	if err != nil {
+		return EncryptionScheme{}, err
+	}
+	return EncryptionScheme{SaltedKey: salt != nil, Envelope: dataKey != nil}, nil
+}
+
+// pendingSchemeUpgrade returns the pending encryption scheme upgrade, or nil if there's none.
+func (s *storage) pendingSchemeUpgrade() (*schemeUpgrade, error) {
+	data, err := s.storedValue(schemeUpgradePrefix, schemeUpgradePath)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	upgrade := &schemeUpgrade{}
+	if err := json.Unmarshal(data, upgrade); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal encryption scheme upgrade")
+	}
+	return upgrade, nil
+}
+
+// newSchemeUpgrade generates the salt and data key of the given scheme and stores them as a pending upgrade.
+func (s *storage) newSchemeUpgrade(scheme EncryptionScheme, key []byte) (*schemeUpgrade, error) {
+	upgrade := &schemeUpgrade{Scheme: scheme}
+	masterKey := key
+	if scheme.SaltedKey {
+		upgrade.Salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, upgrade.Salt); err != nil {
+			return nil, errors.Wrap(err, "could not generate salt")
+		}
+		masterKey = saltedKey(upgrade.Salt, key)
+	}
+	if scheme.Envelope {
+		dataKey := make([]byte, dataKeySize)
+		if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+			return nil, errors.Wrap(err, "could not generate data key")
+		}
+		wrappedKey, err := s.encryptWithKey(masterKey, dataKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not wrap data key")
+		}
+		upgrade.WrappedDataKey = wrappedKey
+	}
+
+	data, err := json.Marshal(upgrade)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal encryption scheme upgrade")
+	}
+	if err := s.db.Set(s.objPrefix(schemeUpgradePrefix), []byte(schemeUpgradePath), data); err != nil {
+		return nil, errors.Wrap(err, "could not save encryption scheme upgrade")
+	}
+	return upgrade, nil
+}
+
+// rewriteAccounts re-encrypts the accounts which don't decrypt with newKey yet, and returns how many it rewrote.
+func (s *storage) rewriteAccounts(oldKey, newKey []byte) (int, error) {
+	var keys [][]byte
+	err := s.db.GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "could not list accounts")
+	}
+
+	rewritten := 0
+	for start := 0; start < len(keys); start += schemeUpgradeBatchSize {
+		batch := keys[start:min(start+schemeUpgradeBatchSize, len(keys))]
+		err := s.db.Update(func(txn basedb.Txn) error {
+			var accounts []basedb.Obj
+			err := txn.GetMany(s.objPrefix(accountsPrefix), batch, func(obj basedb.Obj) error {
+				if _, err := decryptWithKey(newKey, obj.Value); err == nil {
+					// Rewritten before the upgrade was interrupted.
+					return nil
+				}
+				data := obj.Value
+				if !isPlainAccount(data) {
+					decrypted, err := decryptWithKey(oldKey, data)
+					if err != nil {
+						return s.cantDecrypt(err)
+					}
+					data = decrypted
+				}
+				encrypted, err := s.encryptWithKey(newKey, data)
+				if err != nil {
+					return err
+				}
+				if decrypted, err := decryptWithKey(newKey, encrypted); err != nil || !bytes.Equal(decrypted, data) {
+					return errors.Errorf("rewritten account %s does not decrypt to the original", obj.Key)
+				}
+				accounts = append(accounts, basedb.Obj{Key: obj.Key, Value: encrypted})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, acc := range accounts {
+				if err := txn.Set(s.objPrefix(accountsPrefix), acc.Key, acc.Value); err != nil {
+					return err
+				}
+			}
+			rewritten += len(accounts)
+			return nil
+		})
+		if err != nil {
+			return rewritten, errors.Wrap(err, "could not rewrite accounts")
+		}
+	}
+	return rewritten, nil
+}
+
+// schemeKeys returns the master key and the key which encrypts accounts under the given scheme,
+// given its salt and wrapped data key. The master key is the one which wraps the data key.
+func (s *storage) schemeKeys(scheme EncryptionScheme, salt, wrappedDataKey, key []byte) (masterKey, accountKey []byte, err error) {
+	masterKey = key
+	if scheme.SaltedKey {
+		if salt == nil {
+			return nil, nil, errors.New("salt not found")
+		}
+		masterKey = saltedKey(salt, key)
+	}
+	if !scheme.Envelope {
+		return masterKey, masterKey, nil
+	}
+	if wrappedDataKey == nil {
+		return nil, nil, errors.New("data key not found")
+	}
+	accountKey, err = decryptWithKey(masterKey, wrappedDataKey)
+	if err != nil {
+		return nil, nil, s.cantDecrypt(err)
+	}
+	return masterKey, accountKey, nil
+}
+
+// setSchemeKeys uses the given keys, returned by schemeKeys, like SetEncryptionKey does.
+func (s *storage) setSchemeKeys(scheme EncryptionScheme, masterKey, accountKey []byte) error {
+	if s.nonces != nil {
+		s.nonces.reset()
+	}
+	if scheme.Envelope {
+		s.masterKey = masterKey
+	} else {
+		secondaryKeys, err := s.deriveSecondaryKeys(accountKey)
+		if err != nil {
+			return err
+		}
+		s.secondaryKeys = secondaryKeys
+		s.masterKey = nil
+	}
+	s.encryptionKey = accountKey
+	return nil
+}
+
+// matchesStoredData reports whether the canary decrypts with any of the given keys, or, in stores without
+// a canary, whether the first encrypted account does. Stores without encrypted data match any key.
+func (s *storage) matchesStoredData(keys ...[]byte) (bool, error) {
+	canary, err := s.storedValue(canaryPrefix, canaryPath)
+	if err != nil {
+		return false, err
+	}
+	if canary != nil {
+		for _, key := range keys {
+			if plaintext, err := decryptWithKey(key, canary); err == nil && bytes.Equal(plaintext, canaryPlaintext) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	account, err := s.firstEncryptedAccount()
+	if err != nil {
+		return false, err
+	}
+	return account == nil || decryptsWithAny(keys, account), nil
+}
+
+// storedValue returns the value stored under the given prefix and key, or nil if there's none.
+func (s *storage) storedValue(keyPrefix, key string) ([]byte, error) {
+	obj, found, err := s.db.Get(s.objPrefix(keyPrefix), []byte(key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get %s", key)
+	}
+	if !found {
+		return nil, nil
+	}
+	return obj.Value, nil
+}
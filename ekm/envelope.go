@@ -0,0 +1,134 @@
+package ekm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// dataKeySize is the size of the AES-256 data key used with envelope encryption.
+const dataKeySize = 32
+
+// setMasterKey unwraps the stored data key with the given master key, or else with each of the secondary keys,
+// and uses it to encrypt accounts. If no data key is stored yet, a new one is generated and stored, and the existing
+// accounts (which are encrypted with either key, or not at all) are re-encrypted with it.
+func (s *storage) setMasterKey(masterKey []byte, secondaryKeys [][]byte) error {
+	obj, found, err := s.db.Get(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath))
+	if err != nil {
+		return errors.Wrap(err, "could not get data key")
+	}
+	if found {
+		dataKey, err := decryptWithAny(masterKey, secondaryKeys, obj.Value)
+		if err != nil {
+			return s.cantDecrypt(err)
+		}
+		s.masterKey = masterKey
+		s.encryptionKey = dataKey
+		return nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return errors.Wrap(err, "could not generate data key")
+	}
+	wrappedKey, err := s.encryptWithKey(masterKey, dataKey)
+	if err != nil {
+		return errors.Wrap(err, "could not wrap data key")
+	}
+
+	var migrated int
+	err = s.db.Update(func(txn basedb.Txn) error {
+		var accounts []basedb.Obj
+		err := txn.GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+			data := obj.Value
+			if !isPlainAccount(data) {
+				decrypted, err := decryptWithAny(masterKey, secondaryKeys, data)
+				if err != nil {
+					return s.cantDecrypt(err)
+				}
+				data = decrypted
+			}
+			encrypted, err := s.encryptWithKey(dataKey, data)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, basedb.Obj{Key: obj.Key, Value: encrypted})
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not re-encrypt accounts")
+		}
+		for _, acc := range accounts {
+			if err := txn.Set(s.objPrefix(accountsPrefix), acc.Key, acc.Value); err != nil {
+				return err
+			}
+		}
+		migrated = len(accounts)
+		return txn.Set(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath), wrappedKey)
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not save data key")
+	}
+	if migrated > 0 {
+		s.sensitiveLogger().Info("re-encrypted accounts with a new data key", zap.Int("accounts", migrated))
+	}
+
+	s.masterKey = masterKey
+	s.encryptionKey = dataKey
+	return nil
+}
+
+// decryptWithAny decrypts the given data with the given key, or else with each of the secondary keys in order.
+// The error of the given key is returned if none of them succeed.
+func decryptWithAny(key []byte, secondaryKeys [][]byte, data []byte) ([]byte, error) {
+	decrypted, err := decryptWithKey(key, data)
+	if err == nil {
+		return decrypted, nil
+	}
+	for _, secondaryKey := range secondaryKeys {
+		if decrypted, keyErr := decryptWithKey(secondaryKey, data); keyErr == nil {
+			return decrypted, nil
+		}
+	}
+	return nil, err
+}
+
+// RotateEncryptionKey re-wraps the data key with the given key, without re-encrypting the accounts.
+// It requires envelope encryption and a previously set encryption key.
+func (s *storage) RotateEncryptionKey(newKey string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.envelope {
+		return errors.New("key rotation requires envelope encryption")
+	}
+	if len(s.masterKey) == 0 {
+		return errors.New("encryption key is not set")
+	}
+
+	keyBytes, err := hex.DecodeString(newKey)
+	if err != nil || len(keyBytes) == 0 {
+		return errors.New("the key must be a valid hexadecimal string")
+	}
+	if s.saltedKeys {
+		if keyBytes, err = s.deriveKey(keyBytes); err != nil {
+			return err
+		}
+	}
+
+	wrappedKey, err := s.encryptWithKey(keyBytes, s.encryptionKey)
+	if err != nil {
+		return errors.Wrap(err, "could not wrap data key")
+	}
+	if err := s.db.Set(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath), wrappedKey); err != nil {
+		return errors.Wrap(err, "could not save data key")
+	}
+
+	s.masterKey = keyBytes
+	return nil
+}
@@ -2,6 +2,7 @@ package ekm
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"sync"
 
@@ -20,6 +21,7 @@ import (
 	slashingprotection "github.com/bloxapp/eth2-key-manager/slashing_protection"
 	"github.com/bloxapp/eth2-key-manager/wallets"
 	ssz "github.com/ferranbt/fastssz"
+	"github.com/google/uuid"
 	"github.com/herumi/bls-eth-go-binary/bls"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -28,6 +30,7 @@ import (
 
 	"github.com/ssvlabs/ssv/networkconfig"
 	"github.com/ssvlabs/ssv/storage/basedb"
+	"github.com/ssvlabs/ssv/utils/hashmap"
 )
 
 const (
@@ -44,15 +47,19 @@ const (
 type ethKeyManagerSigner struct {
 	wallet            core.Wallet
 	walletLock        *sync.RWMutex
+	randaoLock        sync.Mutex
+	syncCommitteeLock sync.Mutex
 	signer            signer.ValidatorSigner
 	storage           Storage
 	domain            spectypes.DomainType
 	slashingProtector core.SlashingProtector
+	accountIDs        *hashmap.Map[string, uuid.UUID] // account IDs by hex-encoded public key, filled when signing
 }
 
 // StorageProvider provides the underlying KeyManager storage.
 type StorageProvider interface {
 	ListAccounts() ([]core.ValidatorAccount, error)
+	ListAccountsWithStatus() ([]AccountStatus, error)
 	RetrieveHighestAttestation(pubKey []byte) (*phase0.AttestationData, bool, error)
 	RetrieveHighestProposal(pubKey []byte) (phase0.Slot, bool, error)
 	BumpSlashingProtection(pubKey []byte) error
@@ -62,6 +69,8 @@ type KeyManager interface {
 	spectypes.BeaconSigner
 	// AddShare saves a share key
 	AddShare(shareKey *bls.SecretKey) error
+	// AddDelegatedShare saves a share whose key is held by the SigningBackend registered for the given key type
+	AddDelegatedShare(pubKey []byte, keyType string) error
 	// RemoveShare removes a share key
 	RemoveShare(pubKey string) error
 
@@ -71,12 +80,18 @@ type KeyManager interface {
 
 // NewETHKeyManagerSigner returns a new instance of ethKeyManagerSigner
 func NewETHKeyManagerSigner(logger *zap.Logger, db basedb.Database, network networkconfig.NetworkConfig, encryptionKey string) (KeyManager, error) {
-	signerStore := NewSignerStorage(db, network.Beacon, logger)
+	signerStore, err := NewSignerStorage(db, network.Beacon, logger)
+	if err != nil {
+		return nil, err
+	}
 	if encryptionKey != "" {
 		err := signerStore.SetEncryptionKey(encryptionKey)
 		if err != nil {
 			return nil, err
 		}
+		if err := signerStore.CheckEncryptionKey(); err != nil {
+			return nil, err
+		}
 	}
 	options := &eth2keymanager.KeyVaultOptions{}
 	options.SetStorage(signerStore)
@@ -107,6 +122,7 @@ func NewETHKeyManagerSigner(logger *zap.Logger, db basedb.Database, network netw
 		storage:           signerStore,
 		domain:            network.DomainType(),
 		slashingProtector: slashingProtector,
+		accountIDs:        hashmap.New[string, uuid.UUID](),
 	}, nil
 }
 
@@ -114,6 +130,10 @@ func (km *ethKeyManagerSigner) ListAccounts() ([]core.ValidatorAccount, error) {
 	return km.storage.ListAccounts()
 }
 
+func (km *ethKeyManagerSigner) ListAccountsWithStatus() ([]AccountStatus, error) {
+	return km.storage.ListAccountsWithStatus()
+}
+
 func (km *ethKeyManagerSigner) RetrieveHighestAttestation(pubKey []byte) (*phase0.AttestationData, bool, error) {
 	return km.storage.RetrieveHighestAttestation(pubKey)
 }
@@ -136,6 +156,10 @@ func (km *ethKeyManagerSigner) signBeaconObject(obj ssz.HashRoot, domain phase0.
 	km.walletLock.RLock()
 	defer km.walletLock.RUnlock()
 
+	if err := km.checkAccountEnabled(pk); err != nil {
+		return nil, nil, err
+	}
+
 	switch domainType {
 	case spectypes.DomainAttester:
 		data, ok := obj.(*phase0.AttestationData)
@@ -198,7 +222,7 @@ func (km *ethKeyManagerSigner) signBeaconObject(obj ssz.HashRoot, domain phase0.
 			return nil, nil, errors.New("could not cast obj to SSZUint64")
 		}
 
-		return km.signer.SignEpoch(phase0.Epoch(data), domain, pk)
+		return km.signRandao(phase0.Epoch(data), domain, pk)
 	case spectypes.DomainSyncCommittee:
 		data, ok := obj.(spectypes.SSZBytes)
 		if !ok {
@@ -210,13 +234,20 @@ func (km *ethKeyManagerSigner) signBeaconObject(obj ssz.HashRoot, domain phase0.
 		if !ok {
 			return nil, nil, errors.New("could not cast obj to SyncAggregatorSelectionData")
 		}
-		return km.signer.SignSyncCommitteeSelectionData(data, domain, pk)
+		return km.signSyncCommittee(data.Slot, pk, func() (spectypes.Signature, []byte, error) {
+			return km.signer.SignSyncCommitteeSelectionData(data, domain, pk)
+		})
 	case spectypes.DomainContributionAndProof:
 		data, ok := obj.(*altair.ContributionAndProof)
 		if !ok {
 			return nil, nil, errors.New("could not cast obj to ContributionAndProof")
 		}
-		return km.signer.SignSyncCommitteeContributionAndProof(data, domain, pk)
+		if data.Contribution == nil {
+			return nil, nil, errors.New("contribution is nil")
+		}
+		return km.signSyncCommittee(data.Contribution.Slot, pk, func() (spectypes.Signature, []byte, error) {
+			return km.signer.SignSyncCommitteeContributionAndProof(data, domain, pk)
+		})
 	case spectypes.DomainApplicationBuilder:
 		var data *api.VersionedValidatorRegistration
 		switch v := obj.(type) {
@@ -256,6 +287,33 @@ func (km *ethKeyManagerSigner) IsBeaconBlockSlashable(pk []byte, slot phase0.Slo
 	return nil
 }
 
+// ErrAccountDisabled is returned when signing with an account which was disabled.
+var ErrAccountDisabled = errors.New("account is disabled")
+
+// checkAccountEnabled returns ErrAccountDisabled if the account of the given public key is disabled.
+// Unknown accounts are left for the signer to reject. The account ID and the flag are served from memory
+// after the first check, so signing doesn't read them from storage.
+func (km *ethKeyManagerSigner) checkAccountEnabled(pk []byte) error {
+	pkHex := hex.EncodeToString(pk)
+	accountID, ok := km.accountIDs.Get(pkHex)
+	if !ok {
+		account, err := km.wallet.AccountByPublicKey(pkHex)
+		if err != nil {
+			return nil
+		}
+		accountID = account.ID()
+		km.accountIDs.Set(pkHex, accountID)
+	}
+	disabled, err := km.storage.IsAccountDisabled(accountID)
+	if err != nil {
+		return errors.Wrap(err, "could not check if account is enabled")
+	}
+	if disabled {
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
 func (km *ethKeyManagerSigner) SignRoot(data spectypes.Root, sigType spectypes.SignatureType, pk []byte) (spectypes.Signature, error) {
 	km.walletLock.RLock()
 	defer km.walletLock.RUnlock()
@@ -264,6 +322,9 @@ func (km *ethKeyManagerSigner) SignRoot(data spectypes.Root, sigType spectypes.S
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get signing account")
 	}
+	if err := km.checkAccountEnabled(pk); err != nil {
+		return nil, err
+	}
 
 	root, err := spectypes.ComputeSigningRoot(data, spectypes.ComputeSignatureDomain(km.domain, sigType))
 	if err != nil {
@@ -298,6 +359,95 @@ func (km *ethKeyManagerSigner) AddShare(shareKey *bls.SecretKey) error {
 	return nil
 }
 
+func (km *ethKeyManagerSigner) AddDelegatedShare(pubKey []byte, keyType string) error {
+	km.walletLock.Lock()
+	defer km.walletLock.Unlock()
+
+	if _, err := signingBackend(keyType); err != nil {
+		return err
+	}
+
+	acc, err := km.wallet.AccountByPublicKey(hex.EncodeToString(pubKey))
+	if err != nil && err.Error() != "account not found" {
+		return errors.Wrap(err, "could not check share existence")
+	}
+	if acc == nil {
+		if err := km.BumpSlashingProtection(pubKey); err != nil {
+			return errors.Wrap(err, "could not bump slashing protection")
+		}
+		if err := km.wallet.AddValidatorAccount(newDelegatedAccount(pubKey, keyType)); err != nil {
+			return errors.Wrap(err, "could not save delegated share")
+		}
+	}
+
+	return nil
+}
+
+// signRandao signs a randao reveal for the given epoch. If the epoch was already signed,
+// the stored signature is returned instead of re-signing.
+func (km *ethKeyManagerSigner) signRandao(epoch phase0.Epoch, domain phase0.Domain, pk []byte) (spectypes.Signature, []byte, error) {
+	km.randaoLock.Lock()
+	defer km.randaoLock.Unlock()
+
+	highest, signature, found, err := km.storage.RetrieveHighestRandaoEpoch(pk)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not retrieve highest randao epoch")
+	}
+	if found && epoch < highest {
+		return nil, nil, errors.Errorf("randao epoch %d is lower than the highest signed randao epoch %d", epoch, highest)
+	}
+	if found && epoch == highest && len(signature) != 0 {
+		root, err := spectypes.ComputeETHSigningRoot(spectypes.SSZUint64(epoch), domain)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not compute randao signing root")
+		}
+		return signature, root[:], nil
+	}
+
+	signature, root, err := km.signer.SignEpoch(epoch, domain, pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found || epoch > highest {
+		if err := km.storage.SaveHighestRandaoEpoch(pk, epoch, signature); err != nil {
+			return nil, nil, errors.Wrap(err, "could not save highest randao epoch")
+		}
+	}
+	return signature, root, nil
+}
+
+// signSyncCommittee signs a sync committee selection proof or contribution for the given slot,
+// refusing slots lower than the highest signed one. The same slot may be signed more than once,
+// since a validator may aggregate for several subcommittees in a slot.
+// Sync committee messages aren't guarded, since they don't carry their slot.
+func (km *ethKeyManagerSigner) signSyncCommittee(
+	slot phase0.Slot,
+	pk []byte,
+	sign func() (spectypes.Signature, []byte, error),
+) (spectypes.Signature, []byte, error) {
+	km.syncCommitteeLock.Lock()
+	defer km.syncCommitteeLock.Unlock()
+
+	highest, found, err := km.storage.RetrieveHighestSyncCommitteeSlot(pk)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not retrieve highest sync committee slot")
+	}
+	if found && slot < highest {
+		return nil, nil, errors.Errorf("sync committee slot %d is lower than the highest signed sync committee slot %d", slot, highest)
+	}
+
+	signature, root, err := sign()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found || slot > highest {
+		if err := km.storage.SaveHighestSyncCommitteeSlot(pk, slot); err != nil {
+			return nil, nil, errors.Wrap(err, "could not save highest sync committee slot")
+		}
+	}
+	return signature, root, nil
+}
+
 func (km *ethKeyManagerSigner) RemoveShare(pubKey string) error {
 	km.walletLock.Lock()
 	defer km.walletLock.Unlock()
@@ -311,19 +461,36 @@ func (km *ethKeyManagerSigner) RemoveShare(pubKey string) error {
 		if err != nil {
 			return errors.Wrap(err, "could not hex decode share public key")
 		}
-		if err := km.storage.RemoveHighestAttestation(pkDecoded); err != nil {
-			return errors.Wrap(err, "could not remove highest attestation")
+		// The account, its wallet index entry and its slashing protection data are removed in one transaction,
+		// and the wallet in memory is then reloaded from storage.
+		if err := km.storage.RemoveValidator(acc.ID(), pkDecoded); err != nil {
+			return errors.Wrap(err, "could not remove share data")
 		}
-		if err := km.storage.RemoveHighestProposal(pkDecoded); err != nil {
-			return errors.Wrap(err, "could not remove highest proposal")
-		}
-		if err := km.wallet.DeleteAccountByPublicKey(pubKey); err != nil {
-			return errors.Wrap(err, "could not delete share")
+		km.accountIDs.Delete(hex.EncodeToString(pkDecoded))
+		if err := km.reloadWallet(); err != nil {
+			return errors.Wrap(err, "could not reload wallet")
 		}
 	}
 	return nil
 }
 
+// reloadWallet updates the wallet in memory, which the signer shares, from the stored wallet.
+func (km *ethKeyManagerSigner) reloadWallet() error {
+	stored, err := km.storage.OpenWallet()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal wallet")
+	}
+	wallet, ok := km.wallet.(json.Unmarshaler)
+	if !ok {
+		return errors.Errorf("wallet of type %T can't be reloaded", km.wallet)
+	}
+	return wallet.UnmarshalJSON(data)
+}
+
 // BumpSlashingProtection updates the slashing protection data for a given public key.
 func (km *ethKeyManagerSigner) BumpSlashingProtection(pubKey []byte) error {
 	currentSlot := km.storage.BeaconNetwork().EstimatedCurrentSlot()
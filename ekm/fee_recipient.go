@@ -0,0 +1,65 @@
+package ekm
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/pkg/errors"
+)
+
+// FeeRecipientStorage stores the fee recipients of validators.
+type FeeRecipientStorage interface {
+	SetFeeRecipient(pubKey []byte, address []byte) error
+	GetFeeRecipient(pubKey []byte) (bellatrix.ExecutionAddress, bool, error)
+}
+
+var _ FeeRecipientStorage = (*storage)(nil)
+
+// SetFeeRecipient sets the execution address which receives the fees of blocks proposed by the given validator,
+// overriding the default fee recipient. The address must be 20 bytes and not the zero address, which would
+// burn the fees.
+func (s *storage) SetFeeRecipient(pubKey []byte, address []byte) error {
+	if err := validatePubKey(pubKey); err != nil {
+		return err
+	}
+	var feeRecipient bellatrix.ExecutionAddress
+	if len(address) != len(feeRecipient) {
+		return errors.Errorf("fee recipient must be %d bytes, got %d", len(feeRecipient), len(address))
+	}
+	copy(feeRecipient[:], address)
+	if feeRecipient == (bellatrix.ExecutionAddress{}) {
+		return errors.New("fee recipient must not be the zero address")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.db.Set(s.objPrefix(feeRecipientPrefix), pubKey, feeRecipient[:])
+}
+
+// GetFeeRecipient returns the fee recipient of the given validator, or the default fee recipient
+// if none was set for it. It returns false if neither is set.
+func (s *storage) GetFeeRecipient(pubKey []byte) (bellatrix.ExecutionAddress, bool, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return bellatrix.ExecutionAddress{}, false, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	obj, found, err := s.db.Get(s.objPrefix(feeRecipientPrefix), pubKey)
+	if err != nil {
+		return bellatrix.ExecutionAddress{}, false, errors.Wrap(err, "could not get fee recipient")
+	}
+	if !found {
+		if s.defaultFeeRecipient == nil {
+			return bellatrix.ExecutionAddress{}, false, nil
+		}
+		return *s.defaultFeeRecipient, true, nil
+	}
+
+	var feeRecipient bellatrix.ExecutionAddress
+	if len(obj.Value) != len(feeRecipient) {
+		return bellatrix.ExecutionAddress{}, false, errors.Errorf("stored fee recipient is %d bytes", len(obj.Value))
+	}
+	copy(feeRecipient[:], obj.Value)
+	return feeRecipient, true, nil
+}
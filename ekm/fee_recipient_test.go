@@ -0,0 +1,55 @@
+package ekm
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+)
+
+func TestFeeRecipient(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	pk := _byteArray(pk1Str)
+	feeRecipient := bellatrix.ExecutionAddress{1, 2, 3}
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+	_, found, err := signerStorage.(FeeRecipientStorage).GetFeeRecipient(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.ErrorContains(t, signerStorage.(FeeRecipientStorage).SetFeeRecipient(pk, feeRecipient[:19]), "fee recipient must be 20 bytes")
+	require.ErrorContains(t, signerStorage.(FeeRecipientStorage).SetFeeRecipient(pk, make([]byte, 20)), "zero address")
+	require.NoError(t, signerStorage.(FeeRecipientStorage).SetFeeRecipient(pk, feeRecipient[:]))
+
+	got, found, err := signerStorage.(FeeRecipientStorage).GetFeeRecipient(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, feeRecipient, got)
+
+	// Validators without a fee recipient get the default one.
+	defaultFeeRecipient := bellatrix.ExecutionAddress{4, 5, 6}
+	signerStorage = newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithDefaultFeeRecipient(defaultFeeRecipient))
+	got, found, err = signerStorage.(FeeRecipientStorage).GetFeeRecipient(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, feeRecipient, got)
+	got, found, err = signerStorage.(FeeRecipientStorage).GetFeeRecipient(_byteArray(pk2Str))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, defaultFeeRecipient, got)
+
+	// Removing the validator removes its fee recipient.
+	require.NoError(t, signerStorage.RemoveValidator(uuid.New(), pk))
+	got, found, err = signerStorage.(FeeRecipientStorage).GetFeeRecipient(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, defaultFeeRecipient, got)
+}
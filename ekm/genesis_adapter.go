@@ -72,6 +72,10 @@ func (k *GenesisKeyManagerAdapter) AddShare(shareKey *bls.SecretKey) error {
 	return k.KeyManager.AddShare(shareKey)
 }
 
+func (k *GenesisKeyManagerAdapter) AddDelegatedShare(pubKey []byte, keyType string) error {
+	return k.KeyManager.AddDelegatedShare(pubKey, keyType)
+}
+
 func (k *GenesisKeyManagerAdapter) RemoveShare(pubKey string) error {
 	return k.KeyManager.RemoveShare(pubKey)
 }
@@ -0,0 +1,154 @@
+package ekm
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+type highestWrite struct {
+	prefix []byte
+	key    []byte
+	value  []byte
+}
+
+// highestBatch is a set of highest-value writes which are committed together in one transaction.
+type highestBatch struct {
+	writes map[string]highestWrite
+	done   chan struct{}
+	err    error
+}
+
+// wait blocks until the batch is committed, and returns the commit error.
+func (b *highestBatch) wait() error {
+	<-b.done
+	return b.err
+}
+
+// highestBatcher coalesces highest-value writes made within a time window and commits them together,
+// to avoid a synchronous DB write per signature during heavy activity.
+// Writers are blocked until their batch is committed, so a value is durable before its signature is released.
+type highestBatcher struct {
+	db     basedb.Database
+	window time.Duration
+
+	lock       sync.Mutex
+	current    *highestBatch // accepting writes
+	committing *highestBatch // being written to the db
+
+	commitLock sync.Mutex
+}
+
+func newHighestBatcher(db basedb.Database, window time.Duration) *highestBatcher {
+	return &highestBatcher{
+		db:     db,
+		window: window,
+	}
+}
+
+// set adds the given writes to the current batch, and returns the batch to wait on.
+// The writes are committed together in the same batch.
+func (b *highestBatcher) set(writes ...highestWrite) *highestBatch {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.current == nil {
+		b.current = &highestBatch{
+			writes: make(map[string]highestWrite),
+			done:   make(chan struct{}),
+		}
+		time.AfterFunc(b.window, func() {
+			_ = b.commit()
+		})
+	}
+	for _, w := range writes {
+		b.current.writes[string(w.prefix)+string(w.key)] = w
+	}
+	return b.current
+}
+
+// get returns the pending value of the given key, if any.
+func (b *highestBatcher) get(prefix []byte, key []byte) ([]byte, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	batch := b.pending(prefix, key)
+	if batch == nil {
+		return nil, false
+	}
+	return batch.writes[string(prefix)+string(key)].value, true
+}
+
+// pendingBatch returns the batch holding the pending write of the given key, if any.
+func (b *highestBatcher) pendingBatch(prefix []byte, key []byte) *highestBatch {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.pending(prefix, key)
+}
+
+// pendingKeys returns the keys of the pending writes with the given prefix.
+func (b *highestBatcher) pendingKeys(prefix []byte) [][]byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var keys [][]byte
+	for _, batch := range []*highestBatch{b.current, b.committing} {
+		if batch == nil {
+			continue
+		}
+		for _, w := range batch.writes {
+			if bytes.Equal(w.prefix, prefix) {
+				keys = append(keys, w.key)
+			}
+		}
+	}
+	return keys
+}
+
+func (b *highestBatcher) pending(prefix []byte, key []byte) *highestBatch {
+	k := string(prefix) + string(key)
+	for _, batch := range []*highestBatch{b.current, b.committing} {
+		if batch == nil {
+			continue
+		}
+		if _, ok := batch.writes[k]; ok {
+			return batch
+		}
+	}
+	return nil
+}
+
+// commit writes the current batch to the db, if any.
+func (b *highestBatcher) commit() error {
+	b.commitLock.Lock()
+	defer b.commitLock.Unlock()
+
+	b.lock.Lock()
+	batch := b.current
+	b.current = nil
+	b.committing = batch
+	b.lock.Unlock()
+
+	if batch == nil {
+		return nil
+	}
+
+	batch.err = b.db.Update(func(txn basedb.Txn) error {
+		for _, w := range batch.writes {
+			if err := txn.Set(w.prefix, w.key, w.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	b.lock.Lock()
+	b.committing = nil
+	b.lock.Unlock()
+
+	close(batch.done)
+	return batch.err
+}
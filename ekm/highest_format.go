@@ -0,0 +1,107 @@
+package ekm
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/pkg/errors"
+)
+
+// highestFormatV1 is the format version of stored highest values.
+// Version 1 holds the attestation as SSZ, the proposal and sync committee slots as an SSZ uint64,
+// and the randao epoch as an SSZ uint64 followed by the randao reveal's signature.
+const highestFormatV1 byte = 1
+
+const (
+	legacyHighestAttestationSize   = 128
+	legacyHighestProposalSize      = 8
+	legacyHighestSyncCommitteeSize = 8
+	// legacyHighestRandaoSize assumes a BLS signature, which is the only kind signed for randao reveals.
+	legacyHighestRandaoSize = 8 + phase0.SignatureLength
+)
+
+// encodeHighestAttestation encodes the given attestation in the current highest format.
+func encodeHighestAttestation(att *phase0.AttestationData) ([]byte, error) {
+	return att.MarshalSSZTo([]byte{highestFormatV1})
+}
+
+// decodeHighestAttestation decodes a stored highest attestation. Attestations stored before
+// the format was versioned have no version prefix and are decoded as version 1.
+func decodeHighestAttestation(data []byte) (*phase0.AttestationData, error) {
+	payload, err := highestPayload(data, legacyHighestAttestationSize)
+	if err != nil {
+		return nil, err
+	}
+	att := &phase0.AttestationData{}
+	if err := att.UnmarshalSSZ(payload); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
+
+// encodeHighestProposal encodes the given proposal slot in the current highest format.
+func encodeHighestProposal(slot phase0.Slot) []byte {
+	return ssz.MarshalUint64([]byte{highestFormatV1}, uint64(slot))
+}
+
+// decodeHighestProposal decodes a stored highest proposal slot. Slots stored before
+// the format was versioned have no version prefix and are decoded as version 1.
+func decodeHighestProposal(data []byte) (phase0.Slot, error) {
+	payload, err := highestPayload(data, legacyHighestProposalSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(payload) != legacyHighestProposalSize {
+		return 0, ssz.ErrSize
+	}
+	return phase0.Slot(ssz.UnmarshallUint64(payload)), nil
+}
+
+// encodeHighestRandao encodes the given randao epoch and reveal signature in the current highest format.
+func encodeHighestRandao(epoch phase0.Epoch, signature []byte) []byte {
+	return append(ssz.MarshalUint64([]byte{highestFormatV1}, uint64(epoch)), signature...)
+}
+
+// decodeHighestRandao decodes a stored highest randao epoch and its reveal signature. Values stored before
+// the format was versioned have no version prefix and are decoded as version 1.
+func decodeHighestRandao(data []byte) (phase0.Epoch, []byte, error) {
+	payload, err := highestPayload(data, legacyHighestRandaoSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 8 {
+		return 0, nil, errors.New("highest randao epoch value is too short")
+	}
+	return phase0.Epoch(ssz.UnmarshallUint64(payload[:8])), payload[8:], nil
+}
+
+// encodeHighestSyncCommittee encodes the given sync committee slot in the current highest format.
+func encodeHighestSyncCommittee(slot phase0.Slot) []byte {
+	return ssz.MarshalUint64([]byte{highestFormatV1}, uint64(slot))
+}
+
+// decodeHighestSyncCommittee decodes a stored highest sync committee slot. Slots stored before
+// the format was versioned have no version prefix and are decoded as version 1.
+func decodeHighestSyncCommittee(data []byte) (phase0.Slot, error) {
+	payload, err := highestPayload(data, legacyHighestSyncCommitteeSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(payload) != legacyHighestSyncCommitteeSize {
+		return 0, errors.Errorf("highest sync committee slot value must be 8 bytes, got %d", len(payload))
+	}
+	return phase0.Slot(ssz.UnmarshallUint64(payload)), nil
+}
+
+// highestPayload returns the version 1 payload of the given stored value.
+func highestPayload(data []byte, legacySize int) ([]byte, error) {
+	if len(data) == legacySize {
+		return data, nil
+	}
+	if len(data) == 0 {
+		return nil, errors.New("highest value is empty")
+	}
+	if data[0] != highestFormatV1 {
+		return nil, errors.Errorf("unsupported highest value format version %d", data[0])
+	}
+	return data[1:], nil
+}
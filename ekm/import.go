@@ -0,0 +1,162 @@
+package ekm
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+)
+
+// ErrConflictingImportEntries is returned when an import batch has entries
+// for the same public key with different highest values.
+var ErrConflictingImportEntries = errors.New("import batch has conflicting entries for the same public key")
+
+// ImportEntry is a validator share to import, along with its slashing protection data.
+type ImportEntry struct {
+	ShareKey *bls.SecretKey
+	// HighestAttestation is the highest attestation signed with the share, if any.
+	HighestAttestation *phase0.AttestationData
+	// HighestProposalSlot is the highest proposal slot signed with the share, or zero if none.
+	HighestProposalSlot phase0.Slot
+}
+
+// ImportFailure is an entry which failed to import.
+type ImportFailure struct {
+	PubKey []byte
+	Err    error
+}
+
+// BatchImportResult reports the outcome of each entry of an import batch.
+type BatchImportResult struct {
+	Imported [][]byte
+	Failed   []ImportFailure
+}
+
+// ImportValidators imports the given shares with their slashing protection data. The whole batch is validated
+// before anything is imported, and fails with ErrConflictingImportEntries if two entries for the same
+// public key have different highest values. Each entry is then imported on its own: its highest values are
+// raised to at least the imported ones, and only then its share is saved, so that a failed entry never
+// leaves an unprotected share. Entries which fail are reported in the result rather than failing the batch.
+func (km *ethKeyManagerSigner) ImportValidators(entries []ImportEntry) (*BatchImportResult, error) {
+	unique, err := validateImportBatch(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchImportResult{}
+	for _, entry := range unique {
+		pubKey := entry.ShareKey.GetPublicKey().Serialize()
+		if err := km.importValidator(entry); err != nil {
+			result.Failed = append(result.Failed, ImportFailure{PubKey: pubKey, Err: err})
+			continue
+		}
+		result.Imported = append(result.Imported, pubKey)
+	}
+	return result, nil
+}
+
+// validateImportBatch checks the batch for conflicting entries and returns it without duplicates.
+func validateImportBatch(entries []ImportEntry) ([]ImportEntry, error) {
+	seen := make(map[string]ImportEntry, len(entries))
+	unique := make([]ImportEntry, 0, len(entries))
+	for i, entry := range entries {
+		if entry.ShareKey == nil {
+			return nil, errors.Errorf("entry %d has no share key", i)
+		}
+		pubKey := entry.ShareKey.GetPublicKey().SerializeToHexStr()
+		prev, ok := seen[pubKey]
+		if !ok {
+			seen[pubKey] = entry
+			unique = append(unique, entry)
+			continue
+		}
+		same, err := sameHighestValues(prev, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return nil, errors.Wrapf(ErrConflictingImportEntries, "public key %s", pubKey)
+		}
+	}
+	return unique, nil
+}
+
+func sameHighestValues(a, b ImportEntry) (bool, error) {
+	if a.HighestProposalSlot != b.HighestProposalSlot {
+		return false, nil
+	}
+	if a.HighestAttestation == nil || b.HighestAttestation == nil {
+		return a.HighestAttestation == nil && b.HighestAttestation == nil, nil
+	}
+	aData, err := a.HighestAttestation.MarshalSSZ()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal attestation")
+	}
+	bData, err := b.HighestAttestation.MarshalSSZ()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal attestation")
+	}
+	return bytes.Equal(aData, bData), nil
+}
+
+func (km *ethKeyManagerSigner) importValidator(entry ImportEntry) error {
+	km.walletLock.Lock()
+	defer km.walletLock.Unlock()
+
+	pubKey := entry.ShareKey.GetPublicKey().Serialize()
+
+	currentSlot := km.storage.BeaconNetwork().EstimatedCurrentSlot()
+	attestation := km.computeMinimalAttestationSP(km.storage.BeaconNetwork().EstimatedEpochAtSlot(currentSlot))
+	proposalSlot := km.computeMinimalProposerSP(currentSlot)
+
+	if entry.HighestAttestation != nil {
+		attestation = raiseAttestation(attestation, entry.HighestAttestation)
+	}
+	proposalSlot = max(proposalSlot, entry.HighestProposalSlot)
+
+	highestAtt, found, err := km.storage.RetrieveHighestAttestation(pubKey)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve highest attestation")
+	}
+	if found {
+		if attestation.Target.Epoch <= highestAtt.Target.Epoch {
+			// The stored attestation already protects up to the imported target.
+			attestation = highestAtt
+		} else {
+			attestation = raiseAttestation(attestation, highestAtt)
+		}
+	}
+
+	highestSlot, found, err := km.storage.RetrieveHighestProposal(pubKey)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve highest proposal")
+	}
+	if found {
+		proposalSlot = max(proposalSlot, highestSlot)
+	}
+
+	if err := km.storage.SaveHighest(pubKey, attestation, proposalSlot); err != nil {
+		return errors.Wrap(err, "could not save highest values")
+	}
+
+	acc, err := km.wallet.AccountByPublicKey(hex.EncodeToString(pubKey))
+	if err != nil && err.Error() != "account not found" {
+		return errors.Wrap(err, "could not check share existence")
+	}
+	if acc == nil {
+		if err := km.saveShare(entry.ShareKey); err != nil {
+			return errors.Wrap(err, "could not save share")
+		}
+	}
+	return nil
+}
+
+// raiseAttestation returns an attestation with the highest source and target epochs of the given attestations.
+func raiseAttestation(a, b *phase0.AttestationData) *phase0.AttestationData {
+	return &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: max(a.Source.Epoch, b.Source.Epoch)},
+		Target: &phase0.Checkpoint{Epoch: max(a.Target.Epoch, b.Target.Epoch)},
+	}
+}
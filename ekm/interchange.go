@@ -0,0 +1,169 @@
+package ekm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// InterchangeStorage imports slashing protection from EIP-3076 interchange files.
+type InterchangeStorage interface {
+	ImportInterchange(r io.Reader, progress func(imported, total int)) error
+}
+
+var _ InterchangeStorage = (*storage)(nil)
+
+// interchangeFormatVersion is the EIP-3076 interchange format version accepted by ImportInterchange.
+const interchangeFormatVersion = "5"
+
+// interchange is an EIP-3076 slashing protection interchange file. Only the fields needed
+// to derive the highest values are decoded.
+type interchange struct {
+	Metadata struct {
+		InterchangeFormatVersion string `json:"interchange_format_version"`
+	} `json:"metadata"`
+	Data []struct {
+		PubKey       string `json:"pubkey"`
+		SignedBlocks []struct {
+			Slot uint64 `json:"slot,string"`
+		} `json:"signed_blocks"`
+		SignedAttestations []struct {
+			SourceEpoch uint64 `json:"source_epoch,string"`
+			TargetEpoch uint64 `json:"target_epoch,string"`
+		} `json:"signed_attestations"`
+	} `json:"data"`
+}
+
+// interchangeValidator holds the highest values of a validator of an interchange file.
+type interchangeValidator struct {
+	pubKey             []byte
+	highestAttestation *phase0.AttestationData // nil if the validator has no signed attestations
+	highestProposal    *phase0.Slot            // nil if the validator has no signed blocks
+}
+
+// ImportInterchange imports the slashing protection data of an EIP-3076 interchange file.
+// Each validator's highest values are raised to the highest ones signed in the file: values are only ever raised,
+// so importing a file again, or a file older than the stored data, never lowers them.
+//
+// Validators are imported one at a time, and a cursor of the file's progress is stored along with each one,
+// so that importing the same file after a crash resumes after the last imported validator.
+// The cursor is deleted once the file is fully imported. If not nil, progress is called after each validator
+// with the number of imported validators and the total, and must not call the storage.
+func (s *storage) ImportInterchange(r io.Reader, progress func(imported, total int)) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "could not read interchange")
+	}
+	validators, err := parseInterchange(data)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	cursorKey := digest[:]
+	obj, found, err := s.db.Get(s.objPrefix(interchangeCursorPrefix), cursorKey)
+	if err != nil {
+		return errors.Wrap(err, "could not get interchange cursor")
+	}
+	start := 0
+	if found {
+		if len(obj.Value) != 8 {
+			return errors.Errorf("invalid interchange cursor length %d", len(obj.Value))
+		}
+		start = int(binary.BigEndian.Uint64(obj.Value))
+		s.logger.Info("resuming interchange import", zap.Int("imported", start), zap.Int("total", len(validators)))
+	}
+
+	for i := start; i < len(validators); i++ {
+		if err := s.importInterchangeValidator(validators[i], cursorKey, i+1 == len(validators), uint64(i+1)); err != nil {
+			return errors.Wrapf(err, "could not import validator %x", validators[i].pubKey)
+		}
+		if progress != nil {
+			progress(i+1, len(validators))
+		}
+	}
+	return nil
+}
+
+// importInterchangeValidator raises the highest values of the given validator and stores the given cursor,
+// or deletes it if the validator is the last one.
+func (s *storage) importInterchangeValidator(v interchangeValidator, cursorKey []byte, last bool, cursor uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return errors.Wrap(err, "could not flush highest values")
+	}
+
+	return s.db.Update(func(txn basedb.Txn) error {
+		if v.highestAttestation != nil {
+			if err := s.raiseHighestAttestation(txn, v.pubKey, v.highestAttestation); err != nil {
+				return err
+			}
+		}
+		if v.highestProposal != nil {
+			if err := s.raiseHighestProposal(txn, v.pubKey, *v.highestProposal); err != nil {
+				return err
+			}
+		}
+		if last {
+			return txn.Delete(s.objPrefix(interchangeCursorPrefix), cursorKey)
+		}
+		return txn.Set(s.objPrefix(interchangeCursorPrefix), cursorKey, binary.BigEndian.AppendUint64(nil, cursor))
+	})
+}
+
+// parseInterchange decodes the given interchange file and returns the highest values of its validators,
+// in the order they appear. The whole file is validated before anything is imported.
+func parseInterchange(data []byte) ([]interchangeValidator, error) {
+	var file interchange
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "could not decode interchange")
+	}
+	if version := file.Metadata.InterchangeFormatVersion; version != interchangeFormatVersion {
+		return nil, errors.Errorf("unsupported interchange format version %q", version)
+	}
+
+	validators := make([]interchangeValidator, 0, len(file.Data))
+	for i, entry := range file.Data {
+		pubKey, err := hex.DecodeString(strings.TrimPrefix(entry.PubKey, "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public key of entry %d", i)
+		}
+		if err := validatePubKey(pubKey); err != nil {
+			return nil, errors.Wrapf(err, "invalid public key of entry %d", i)
+		}
+
+		v := interchangeValidator{pubKey: pubKey}
+		for _, block := range entry.SignedBlocks {
+			if slot := phase0.Slot(block.Slot); v.highestProposal == nil || slot > *v.highestProposal {
+				v.highestProposal = &slot
+			}
+		}
+		for _, att := range entry.SignedAttestations {
+			if att.SourceEpoch > att.TargetEpoch {
+				return nil, errors.Errorf("attestation of entry %d has source epoch %d after target epoch %d",
+					i, att.SourceEpoch, att.TargetEpoch)
+			}
+			if v.highestAttestation == nil {
+				v.highestAttestation = &phase0.AttestationData{
+					Source: &phase0.Checkpoint{},
+					Target: &phase0.Checkpoint{},
+				}
+			}
+			v.highestAttestation.Source.Epoch = max(v.highestAttestation.Source.Epoch, phase0.Epoch(att.SourceEpoch))
+			v.highestAttestation.Target.Epoch = max(v.highestAttestation.Target.Epoch, phase0.Epoch(att.TargetEpoch))
+		}
+		validators = append(validators, v)
+	}
+	return validators, nil
+}
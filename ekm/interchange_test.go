@@ -0,0 +1,96 @@
+package ekm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+)
+
+func TestImportInterchange(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+
+	pk1, pk2 := _byteArray(pk1Str), _byteArray(pk2Str)
+	file := fmt.Sprintf(`{
+		"metadata": {"interchange_format_version": "5", "genesis_validators_root": "0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"},
+		"data": [
+			{
+				"pubkey": "0x%s",
+				"signed_blocks": [{"slot": "81952"}, {"slot": "81951"}],
+				"signed_attestations": [{"source_epoch": "2290", "target_epoch": "3007"}, {"source_epoch": "2291", "target_epoch": "3006"}]
+			},
+			{
+				"pubkey": "0x%s",
+				"signed_blocks": [],
+				"signed_attestations": [{"source_epoch": "10", "target_epoch": "11"}]
+			}
+		]
+	}`, pk1Str, pk2Str)
+
+	// pk2 already has a higher target epoch, which isn't lowered.
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk2, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 5},
+		Target: &phase0.Checkpoint{Epoch: 20},
+	}))
+
+	type progress struct{ imported, total int }
+	var progressed []progress
+	onProgress := func(imported, total int) {
+		progressed = append(progressed, progress{imported, total})
+	}
+
+	// An import which stopped after the first validator resumes from the second.
+	digest := sha256.Sum256([]byte(file))
+	s := signerStorage.(*storage)
+	require.NoError(t, db.Set(s.objPrefix(interchangeCursorPrefix), digest[:], binary.BigEndian.AppendUint64(nil, 1)))
+	require.NoError(t, signerStorage.(InterchangeStorage).ImportInterchange(strings.NewReader(file), onProgress))
+	require.Equal(t, []progress{{2, 2}}, progressed)
+	_, found, err := signerStorage.RetrieveHighestProposal(pk1)
+	require.NoError(t, err)
+	require.False(t, found)
+	att, found, err := signerStorage.RetrieveHighestAttestation(pk2)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(10), att.Source.Epoch)
+	require.Equal(t, phase0.Epoch(20), att.Target.Epoch)
+
+	// The cursor is deleted once the import completes, so importing again starts over.
+	progressed = nil
+	require.NoError(t, signerStorage.(InterchangeStorage).ImportInterchange(strings.NewReader(file), onProgress))
+	require.Equal(t, []progress{{1, 2}, {2, 2}}, progressed)
+	_, found, err = db.Get(s.objPrefix(interchangeCursorPrefix), digest[:])
+	require.NoError(t, err)
+	require.False(t, found)
+
+	slot, found, err := signerStorage.RetrieveHighestProposal(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(81952), slot)
+	att, found, err = signerStorage.RetrieveHighestAttestation(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(2291), att.Source.Epoch)
+	require.Equal(t, phase0.Epoch(3007), att.Target.Epoch)
+	att, found, err = signerStorage.RetrieveHighestAttestation(pk2)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(10), att.Source.Epoch)
+	require.Equal(t, phase0.Epoch(20), att.Target.Epoch)
+
+	// Invalid files are rejected before anything is imported.
+	err = signerStorage.(InterchangeStorage).ImportInterchange(strings.NewReader(`{"metadata": {"interchange_format_version": "4"}, "data": []}`), nil)
+	require.EqualError(t, err, `unsupported interchange format version "4"`)
+	err = signerStorage.(InterchangeStorage).ImportInterchange(strings.NewReader(`{"metadata": {"interchange_format_version": "5"}, "data": [{"pubkey": "0x01"}]}`), nil)
+	require.ErrorContains(t, err, "invalid public key of entry 0")
+}
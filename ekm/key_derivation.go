@@ -0,0 +1,50 @@
+package ekm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// saltSize is the size of the random salt mixed into the encryption key derivation.
+const saltSize = 32
+
+// deriveKey mixes the store's salt into the given encryption key, so that identical keys yield distinct
+// keys across stores. The salt is generated when the store has no wallet yet, and stored unencrypted.
+// Stores which already have a wallet but no salt keep using the key as is.
+func (s *storage) deriveKey(key []byte) ([]byte, error) {
+	obj, found, err := s.db.Get(s.objPrefix(saltPrefix), []byte(saltPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get salt")
+	}
+
+	salt := obj.Value
+	if !found {
+		_, walletFound, err := s.db.Get(s.objPrefix(walletPrefix), []byte(walletPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get wallet")
+		}
+		if walletFound {
+			// Legacy store.
+			return key, nil
+		}
+
+		salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, errors.Wrap(err, "could not generate salt")
+		}
+		if err := s.db.Set(s.objPrefix(saltPrefix), []byte(saltPath), salt); err != nil {
+			return nil, errors.Wrap(err, "could not save salt")
+		}
+	}
+
+	return saltedKey(salt, key), nil
+}
+
+// saltedKey mixes the given salt into the given encryption key.
+func saltedKey(salt, key []byte) []byte {
+	hash := sha256.Sum256(append(append([]byte{}, salt...), key...))
+	return hash[:]
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package ekm
+
+// lockedBuffer is a buffer which is zeroed when released.
+// Memory locking is only supported on Linux, so on other platforms the buffer may be swapped to disk.
+type lockedBuffer struct {
+	mem []byte
+}
+
+// newLockedBuffer allocates a buffer of the given size.
+func newLockedBuffer(size int) (*lockedBuffer, error) {
+	return &lockedBuffer{mem: make([]byte, size)}, nil
+}
+
+// bytes returns the buffer's memory.
+func (b *lockedBuffer) bytes() []byte {
+	return b.mem
+}
+
+// release zeroes the buffer.
+func (b *lockedBuffer) release() {
+	clear(b.mem)
+	b.mem = nil
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package ekm
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockedBuffer is a buffer in memory which is locked into RAM, so it's never swapped to disk,
+// and which is zeroed when released.
+type lockedBuffer struct {
+	mem []byte
+}
+
+// newLockedBuffer allocates a locked buffer of the given size.
+// Locking memory requires CAP_IPC_LOCK or a sufficient RLIMIT_MEMLOCK.
+func newLockedBuffer(size int) (*lockedBuffer, error) {
+	if size == 0 {
+		size = 1
+	}
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not allocate memory")
+	}
+	if err := syscall.Mlock(mem); err != nil {
+		_ = syscall.Munmap(mem)
+		return nil, errors.Wrap(err, "could not lock memory")
+	}
+	return &lockedBuffer{mem: mem}, nil
+}
+
+// bytes returns the buffer's memory.
+func (b *lockedBuffer) bytes() []byte {
+	return b.mem
+}
+
+// release zeroes and frees the buffer.
+func (b *lockedBuffer) release() {
+	clear(b.mem)
+	_ = syscall.Munlock(b.mem)
+	_ = syscall.Munmap(b.mem)
+	b.mem = nil
+}
@@ -0,0 +1,75 @@
+package ekm
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxNonceAttempts is the number of times a fresh nonce is drawn after a duplicate is detected
+// before giving up, since repeated duplicates indicate a broken randomness source.
+const maxNonceAttempts = 3
+
+// maxTrackedNonces is the number of most recent nonces remembered per encryption key.
+// A broken randomness source repeats nonces quickly, so older nonces are forgotten to bound memory.
+const maxTrackedNonces = 1 << 16
+
+var ErrNonceReuse = errors.New("duplicate encryption nonce detected, randomness source may be broken")
+
+// nonceTracker remembers the most recent nonces used with each encryption key within the process session.
+// Keys are tracked by their hash, so the tracker doesn't hold on to key material.
+type nonceTracker struct {
+	lock sync.Mutex
+	keys map[[sha256.Size]byte]*nonceSet
+}
+
+// nonceSet is a bounded set of nonces, which evicts the oldest nonce once it's full.
+type nonceSet struct {
+	seen  map[string]struct{}
+	order []string
+	next  int
+}
+
+func newNonceTracker() *nonceTracker {
+	return &nonceTracker{
+		keys: make(map[[sha256.Size]byte]*nonceSet),
+	}
+}
+
+// add records the given nonce for the given key and returns false if it was already used with that key.
+func (t *nonceTracker) add(key, nonce []byte) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	id := sha256.Sum256(key)
+	set, ok := t.keys[id]
+	if !ok {
+		set = &nonceSet{seen: make(map[string]struct{})}
+		t.keys[id] = set
+	}
+	return set.add(string(nonce))
+}
+
+func (s *nonceSet) add(nonce string) bool {
+	if _, ok := s.seen[nonce]; ok {
+		return false
+	}
+	if len(s.order) < maxTrackedNonces {
+		s.order = append(s.order, nonce)
+	} else {
+		delete(s.seen, s.order[s.next])
+		s.order[s.next] = nonce
+		s.next = (s.next + 1) % maxTrackedNonces
+	}
+	s.seen[nonce] = struct{}{}
+	return true
+}
+
+// reset forgets all recorded nonces, it must be called whenever the encryption key changes.
+func (t *nonceTracker) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.keys = make(map[[sha256.Size]byte]*nonceSet)
+}
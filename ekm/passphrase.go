@@ -0,0 +1,69 @@
+package ekm
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNothingToVerify is returned by VerifyPassphrase when the storage holds no encrypted data.
+var ErrNothingToVerify = errors.New("storage holds no encrypted data to verify against")
+
+// errEncryptedFound stops the scan for an encrypted account.
+var errEncryptedFound = errors.New("encrypted account found")
+
+// VerifyPassphrase returns whether the given hex-encoded key decrypts the stored data, without setting it.
+// With envelope encryption the key is checked against the stored data key, and otherwise against
+// the canary, or the first encrypted account in stores without a canary. Keys are derived like SetEncryptionKey derives them, but without generating
+// a salt, so the storage is never modified.
+func (s *storage) VerifyPassphrase(candidate string) (bool, error) {
+	keyBytes, err := hex.DecodeString(candidate)
+	if err != nil || len(keyBytes) == 0 {
+		return false, errors.New("the key must be a valid hexadecimal string")
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.saltedKeys {
+		obj, found, err := s.db.Get(s.objPrefix(saltPrefix), []byte(saltPath))
+		if err != nil {
+			return false, errors.Wrap(err, "could not get salt")
+		}
+		// Without a salt, stored data is either absent or encrypted with the key as is.
+		if found {
+			keyBytes = saltedKey(obj.Value, keyBytes)
+		}
+	}
+
+	if s.envelope {
+		obj, found, err := s.db.Get(s.objPrefix(dataKeyPrefix), []byte(dataKeyPath))
+		if err != nil {
+			return false, errors.Wrap(err, "could not get data key")
+		}
+		if found {
+			_, err := decryptWithKey(keyBytes, obj.Value)
+			return err == nil, nil
+		}
+	}
+
+	obj, found, err := s.db.Get(s.objPrefix(canaryPrefix), []byte(canaryPath))
+	if err != nil {
+		return false, errors.Wrap(err, "could not get canary")
+	}
+	if found {
+		plaintext, err := decryptWithKey(keyBytes, obj.Value)
+		return err == nil && bytes.Equal(plaintext, canaryPlaintext), nil
+	}
+
+	encrypted, err := s.firstEncryptedAccount()
+	if err != nil {
+		return false, err
+	}
+	if encrypted == nil {
+		return false, ErrNothingToVerify
+	}
+	_, err = decryptWithKey(keyBytes, encrypted)
+	return err == nil, nil
+}
@@ -0,0 +1,77 @@
+package ekm
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+
+	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// RebuildHighestFromDecided seeds slashing protection data, lost or never stored, from the decided instances
+// of the given identifier. Values are only ever raised: a value which is lower than the stored one is ignored.
+//
+// Decided instances are stored at their duty's slot, so the highest decided instance holds the highest
+// slot and epochs, and only it is read:
+//   - For committee identifiers, the highest attestation of each of the given validators of the committee
+//     is raised to the decided vote.
+//   - For proposer identifiers, the highest proposal of the identifier's validator is raised to the decided
+//     duty's slot, and pubKeys are ignored.
+func (s *storage) RebuildHighestFromDecided(store qbftstorage.InstanceStore, identifier spectypes.MessageID, pubKeys [][]byte) error {
+	inst, err := store.GetHighestInstance(identifier[:])
+	if err != nil {
+		return errors.Wrap(err, "could not get highest instance")
+	}
+	if inst == nil || inst.State == nil || !inst.State.Decided || len(inst.State.DecidedValue) == 0 {
+		return errors.New("no decided instance found")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return errors.Wrap(err, "could not flush highest values")
+	}
+
+	switch role := identifier.GetRoleType(); role {
+	case spectypes.RoleCommittee:
+		vote := &spectypes.BeaconVote{}
+		if err := vote.Decode(inst.State.DecidedValue); err != nil {
+			return errors.Wrap(err, "could not decode beacon vote")
+		}
+		att := &phase0.AttestationData{
+			Slot:            phase0.Slot(inst.State.Height),
+			BeaconBlockRoot: vote.BlockRoot,
+			Source:          vote.Source,
+			Target:          vote.Target,
+		}
+		return s.db.Update(func(txn basedb.Txn) error {
+			for _, pubKey := range pubKeys {
+				if err := validatePubKey(pubKey); err != nil {
+					return err
+				}
+				if err := s.raiseHighestAttestation(txn, pubKey, att); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case spectypes.RoleProposer:
+		data := &spectypes.ValidatorConsensusData{}
+		if err := data.Decode(inst.State.DecidedValue); err != nil {
+			return errors.Wrap(err, "could not decode consensus data")
+		}
+		pubKey := identifier.GetDutyExecutorID()
+		if err := validatePubKey(pubKey); err != nil {
+			return err
+		}
+		return s.db.Update(func(txn basedb.Txn) error {
+			return s.raiseHighestProposal(txn, pubKey, data.Duty.Slot)
+		})
+
+	default:
+		return errors.Errorf("role %s has no slashing protection data", role)
+	}
+}
@@ -0,0 +1,183 @@
+package ekm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// ReconcileReport describes the drift between the wallet's accounts and the stored accounts.
+type ReconcileReport struct {
+	// Orphaned are stored accounts which aren't referenced by the wallet.
+	Orphaned []uuid.UUID
+	// Dangling are accounts referenced by the wallet which aren't stored.
+	Dangling []uuid.UUID
+	// Relinked are orphaned accounts which were linked back into the wallet.
+	Relinked []uuid.UUID
+}
+
+// ReconcileAccounts compares the accounts referenced by the wallet against the stored accounts,
+// which may drift if a save partially failed. If relinkOrphans is true, orphaned accounts are
+// added back into the wallet.
+func (s *storage) ReconcileAccounts(relinkOrphans bool) (*ReconcileReport, error) {
+	wallet, err := s.OpenWallet()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open wallet")
+	}
+
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal wallet")
+	}
+	var walletData struct {
+		IndexMapper map[string]uuid.UUID `json:"indexMapper"`
+	}
+	if err := json.Unmarshal(data, &walletData); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal wallet index")
+	}
+
+	// Orphans may be relinked, so accounts are listed from the primary database.
+	accounts, err := s.ListAccountsTxn(s.db)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list accounts")
+	}
+
+	report := &ReconcileReport{}
+	stored := make(map[uuid.UUID]struct{}, len(accounts))
+	for _, acc := range accounts {
+		stored[acc.ID()] = struct{}{}
+
+		id, ok := walletData.IndexMapper[hex.EncodeToString(acc.ValidatorPublicKey())]
+		if ok && id == acc.ID() {
+			continue
+		}
+		report.Orphaned = append(report.Orphaned, acc.ID())
+
+		if relinkOrphans {
+			if err := wallet.AddValidatorAccount(acc); err != nil {
+				return report, errors.Wrapf(err, "could not relink account %s", acc.ID())
+			}
+			report.Relinked = append(report.Relinked, acc.ID())
+		}
+	}
+
+	for _, id := range walletData.IndexMapper {
+		if _, ok := stored[id]; !ok {
+			report.Dangling = append(report.Dangling, id)
+		}
+	}
+
+	return report, nil
+}
+
+// ReconcileProtection returns the sorted public keys which have slashing protection data but no account,
+// which may be left behind by an incomplete validator removal. If deleteOrphans is true, their slashing
+// protection data is deleted in the same pass. Shares are protected before their account is saved,
+// so it must not be called while shares are being added.
+func (s *storage) ReconcileProtection(deleteOrphans bool) ([][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return nil, errors.Wrap(err, "could not flush highest values")
+	}
+
+	accounts, err := s.listAccounts(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list accounts")
+	}
+	local := make(map[string]struct{}, len(accounts))
+	for _, acc := range accounts {
+		local[string(acc.ValidatorPublicKey())] = struct{}{}
+	}
+
+	prefixes := []string{highestAttPrefix, highestAttHistoryPrefix, highestProposalPrefix, highestRandaoPrefix, highestSyncCommitteePrefix}
+	orphans := make(map[string]struct{})
+	for _, p := range prefixes {
+		err := s.db.GetAll(s.objPrefix(p), func(i int, obj basedb.Obj) error {
+			if _, ok := local[string(obj.Key)]; !ok {
+				orphans[string(obj.Key)] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not list slashing protection data")
+		}
+	}
+
+	pubKeys := make([][]byte, 0, len(orphans))
+	for pubKey := range orphans {
+		pubKeys = append(pubKeys, []byte(pubKey))
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
+	if !deleteOrphans || len(pubKeys) == 0 {
+		return pubKeys, nil
+	}
+	err = s.db.Update(func(txn basedb.Txn) error {
+		for _, pubKey := range pubKeys {
+			for _, p := range prefixes {
+				if err := txn.Delete(s.objPrefix(p), pubKey); err != nil {
+					return errors.Wrapf(err, "could not delete %s", p)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not delete orphaned slashing protection data")
+	}
+	s.logger.Info("deleted orphaned slashing protection data", zap.Int("pubkeys", len(pubKeys)))
+	return pubKeys, nil
+}
+
+// ValidatorReconcileReport describes the drift between the validators registered to the operator
+// and the validators which have an account in the signer storage.
+type ValidatorReconcileReport struct {
+	// MissingKeys are public keys of registered validators which have no account.
+	MissingKeys [][]byte
+	// Unregistered are public keys of accounts whose validator isn't registered.
+	Unregistered [][]byte
+}
+
+// ReconcileValidators compares the public keys of the validators registered to the operator,
+// such as those of the operator's shares, against the given accounts. Both lists of the report are sorted.
+func ReconcileValidators(registered [][]byte, accounts []core.ValidatorAccount) *ValidatorReconcileReport {
+	local := make(map[string]struct{}, len(accounts))
+	for _, acc := range accounts {
+		local[string(acc.ValidatorPublicKey())] = struct{}{}
+	}
+	remote := make(map[string]struct{}, len(registered))
+	for _, pubKey := range registered {
+		remote[string(pubKey)] = struct{}{}
+	}
+
+	report := &ValidatorReconcileReport{}
+	for pubKey := range remote {
+		if _, ok := local[pubKey]; !ok {
+			report.MissingKeys = append(report.MissingKeys, []byte(pubKey))
+		}
+	}
+	for pubKey := range local {
+		if _, ok := remote[pubKey]; !ok {
+			report.Unregistered = append(report.Unregistered, []byte(pubKey))
+		}
+	}
+
+	for _, keys := range [][][]byte{report.MissingKeys, report.Unregistered} {
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+	}
+	return report
+}
@@ -0,0 +1,135 @@
+package ekm
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// retryDB retries the idempotent operations of the wrapped database when they fail with a transient error.
+// Transactions (Update) aren't retried, since their functions may have side effects. As highest values
+// are always written in a transaction, slashing protection writes are never retried by retryDB.
+type retryDB struct {
+	basedb.Database
+	attempts int
+	backoff  time.Duration
+}
+
+func newRetryDB(db basedb.Database, attempts int, backoff time.Duration) *retryDB {
+	return &retryDB{
+		Database: db,
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+// isTransientDBError returns true if the given error may not recur when the operation is retried.
+func isTransientDBError(err error) bool {
+	return errors.Is(err, badger.ErrConflict) || errors.Is(err, badger.ErrBlockedWrites)
+}
+
+// retry calls f until it succeeds, fails with a non-transient error, or the attempts are exhausted,
+// doubling the backoff between attempts.
+func (r *retryDB) retry(f func() error) error {
+	backoff := r.backoff
+	var err error
+	for attempt := 0; attempt < r.attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = f(); err == nil || !isTransientDBError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *retryDB) Get(prefix []byte, key []byte) (obj basedb.Obj, found bool, err error) {
+	err = r.retry(func() error {
+		obj, found, err = r.Database.Get(prefix, key)
+		return err
+	})
+	return obj, found, err
+}
+
+// GetMany is retried only if it failed before calling the iterator, so that no object is iterated twice.
+func (r *retryDB) GetMany(prefix []byte, keys [][]byte, iterator func(basedb.Obj) error) error {
+	called := false
+	var iterErr error
+	err := r.retry(func() error {
+		err := r.Database.GetMany(prefix, keys, func(obj basedb.Obj) error {
+			called = true
+			return iterator(obj)
+		})
+		if called {
+			iterErr = err
+			return nil
+		}
+		return err
+	})
+	if called {
+		return iterErr
+	}
+	return err
+}
+
+// GetAll is retried only if it failed before calling the handler, so that no object is handled twice.
+func (r *retryDB) GetAll(prefix []byte, handler func(int, basedb.Obj) error) error {
+	called := false
+	var handlerErr error
+	err := r.retry(func() error {
+		err := r.Database.GetAll(prefix, func(i int, obj basedb.Obj) error {
+			called = true
+			return handler(i, obj)
+		})
+		if called {
+			handlerErr = err
+			return nil
+		}
+		return err
+	})
+	if called {
+		return handlerErr
+	}
+	return err
+}
+
+func (r *retryDB) Set(prefix []byte, key []byte, value []byte) error {
+	return r.retry(func() error {
+		return r.Database.Set(prefix, key, value)
+	})
+}
+
+func (r *retryDB) Delete(prefix []byte, key []byte) error {
+	return r.retry(func() error {
+		return r.Database.Delete(prefix, key)
+	})
+}
+
+func (r *retryDB) CountPrefix(prefix []byte) (n int64, err error) {
+	err = r.retry(func() error {
+		n, err = r.Database.CountPrefix(prefix)
+		return err
+	})
+	return n, err
+}
+
+// Using returns the given ReadWriter, falling back to the retrying database if it's nil.
+func (r *retryDB) Using(rw basedb.ReadWriter) basedb.ReadWriter {
+	if rw == nil {
+		return r
+	}
+	return rw
+}
+
+// UsingReader returns the given Reader, falling back to the retrying database if it's nil.
+func (r *retryDB) UsingReader(rd basedb.Reader) basedb.Reader {
+	if rd == nil {
+		return r
+	}
+	return rd
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/eth2-key-manager/core"
 	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/google/uuid"
 	"github.com/herumi/bls-eth-go-binary/bls"
 	"github.com/holiman/uint256"
 	"github.com/pkg/errors"
@@ -86,7 +87,7 @@ func TestEncryptedKeyManager(t *testing.T) {
 	db, err := getBaseStorage(logger)
 	require.NoError(t, err)
 
-	signerStorage := NewSignerStorage(db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
 	err = signerStorage.SetEncryptionKey(encryptionKey)
 	require.NoError(t, err)
 
@@ -685,6 +686,50 @@ func TestSlashing_Attestation(t *testing.T) {
 	signAttestation(secretKeys[2], phase0.Root{7}, createAttestationData(6, 6), true, "HighestAttestationVote")
 }
 
+func TestSlashing_EmptyHighestValues(t *testing.T) {
+	km := testKeyManager(t, nil)
+	signer := km.(*ethKeyManagerSigner)
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	pk := sk.GetPublicKey().Serialize()
+	require.NoError(t, signer.BumpSlashingProtection(pk))
+	require.NoError(t, signer.saveShare(sk))
+
+	s := signer.storage.(*storage)
+	require.NoError(t, s.db.Set(s.objPrefix(highestAttPrefix), pk, []byte{}))
+	require.NoError(t, s.db.Set(s.objPrefix(highestProposalPrefix), pk, []byte{}))
+
+	currentEpoch := signer.storage.BeaconNetwork().EstimatedCurrentEpoch()
+	attestation := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: currentEpoch + 1},
+		Target: &phase0.Checkpoint{Epoch: currentEpoch + 2},
+	}
+
+	// An empty value is reported as not found rather than as a storage error.
+	_, _, err := signer.SignBeaconObject(attestation, phase0.Domain{}, pk, spectypes.DomainAttester)
+	require.ErrorContains(t, err, "highest attestation data is not found")
+	require.NotContains(t, err.Error(), "could not retrieve highest attestation")
+
+	// The protection can then be re-seeded and signing resumes.
+	require.NoError(t, signer.BumpSlashingProtection(pk))
+	sig, root, err := signer.SignBeaconObject(attestation, phase0.Domain{}, pk, spectypes.DomainAttester)
+	require.NoError(t, err)
+	require.NotZero(t, sig)
+	require.NotZero(t, root)
+
+	highAtt, found, err := signer.storage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, attestation.Target.Epoch, highAtt.Target.Epoch)
+
+	// The proposal protection is re-seeded by the same bump.
+	highestSlot, found, err := signer.storage.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NotZero(t, highestSlot)
+}
+
 func TestSignRoot(t *testing.T) {
 	require.NoError(t, bls.Init(bls.BLS12_381))
 
@@ -767,6 +812,14 @@ func TestRemoveShare(t *testing.T) {
 		require.NoError(t, err)
 		err = km.RemoveShare(pk.GetPublicKey().GetHexString())
 		require.NoError(t, err)
+
+		// The account is gone from both the wallet in memory and the stored wallet.
+		_, err = km.(*ethKeyManagerSigner).wallet.AccountByPublicKey(pk.GetPublicKey().GetHexString())
+		require.EqualError(t, err, "account not found")
+		stored, err := km.(*ethKeyManagerSigner).storage.OpenWallet()
+		require.NoError(t, err)
+		_, err = stored.AccountByPublicKey(pk.GetPublicKey().GetHexString())
+		require.EqualError(t, err, "account not found")
 	})
 
 	t.Run("key doesn't exist", func(t *testing.T) {
@@ -788,3 +841,343 @@ func TestEkmListAccounts(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 2, len(accounts))
 }
+
+func TestDelegatedShare(t *testing.T) {
+	km := testKeyManager(t, nil)
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	pk := sk.GetPublicKey().Serialize()
+
+	require.ErrorContains(t, km.AddDelegatedShare(pk, "unregistered"), "no signing backend registered")
+
+	backend := NewSoftwareSigningBackend()
+	backend.AddKey(sk)
+	RegisterSigningBackend(SoftwareKeyType, backend)
+
+	require.NoError(t, km.AddDelegatedShare(pk, SoftwareKeyType))
+
+	attestation := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 13},
+		Target: &phase0.Checkpoint{Epoch: 14},
+	}
+	sig, root, err := km.SignBeaconObject(attestation, phase0.Domain{}, pk, spectypes.DomainAttester)
+	require.NoError(t, err)
+
+	blsSig := &bls.Sign{}
+	require.NoError(t, blsSig.Deserialize(sig))
+	require.True(t, blsSig.VerifyByte(sk.GetPublicKey(), root[:]))
+
+	// Slashing protection is still enforced locally.
+	_, _, err = km.SignBeaconObject(attestation, phase0.Domain{}, pk, spectypes.DomainAttester)
+	require.ErrorContains(t, err, "slashable attestation")
+
+	// The stored account holds no key material.
+	accounts, err := km.(*ethKeyManagerSigner).ListAccounts()
+	require.NoError(t, err)
+	var delegated int
+	for _, acc := range accounts {
+		if d, ok := acc.(*delegatedAccount); ok {
+			require.Equal(t, pk, d.ValidatorPublicKey())
+			delegated++
+		}
+	}
+	require.Equal(t, 1, delegated)
+
+	require.NoError(t, km.RemoveShare(hex.EncodeToString(pk)))
+}
+
+func TestSignRandao(t *testing.T) {
+	km := testKeyManager(t, nil)
+	ekm := km.(*ethKeyManagerSigner)
+
+	sk := &bls.SecretKey{}
+	require.NoError(t, sk.SetHexString(sk1Str))
+	pk := sk.GetPublicKey().Serialize()
+
+	sig, root, err := km.SignBeaconObject(spectypes.SSZUint64(5), phase0.Domain{}, pk, spectypes.DomainRandao)
+	require.NoError(t, err)
+	blsSig := &bls.Sign{}
+	require.NoError(t, blsSig.Deserialize(sig))
+	require.True(t, blsSig.VerifyByte(sk.GetPublicKey(), root[:]))
+
+	epoch, storedSig, found, err := ekm.storage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(5), epoch)
+	require.EqualValues(t, sig, storedSig)
+
+	// The same epoch returns the stored signature.
+	sig2, root2, err := km.SignBeaconObject(spectypes.SSZUint64(5), phase0.Domain{}, pk, spectypes.DomainRandao)
+	require.NoError(t, err)
+	require.Equal(t, sig, sig2)
+	require.Equal(t, root, root2)
+
+	// A lower epoch is already covered.
+	_, _, err = km.SignBeaconObject(spectypes.SSZUint64(4), phase0.Domain{}, pk, spectypes.DomainRandao)
+	require.ErrorContains(t, err, "lower than the highest signed randao epoch")
+
+	_, _, err = km.SignBeaconObject(spectypes.SSZUint64(6), phase0.Domain{}, pk, spectypes.DomainRandao)
+	require.NoError(t, err)
+	epoch, _, _, err = ekm.storage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(6), epoch)
+
+	require.NoError(t, km.RemoveShare(hex.EncodeToString(pk)))
+	_, _, found, err = ekm.storage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestSignSyncCommittee(t *testing.T) {
+	km := testKeyManager(t, nil)
+	ekm := km.(*ethKeyManagerSigner)
+
+	sk := &bls.SecretKey{}
+	require.NoError(t, sk.SetHexString(sk1Str))
+	pk := sk.GetPublicKey().Serialize()
+
+	selectionData := func(slot phase0.Slot, subcommitteeIndex uint64) *altair.SyncAggregatorSelectionData {
+		return &altair.SyncAggregatorSelectionData{Slot: slot, SubcommitteeIndex: subcommitteeIndex}
+	}
+
+	_, _, err := km.SignBeaconObject(selectionData(10, 1), phase0.Domain{}, pk, spectypes.DomainSyncCommitteeSelectionProof)
+	require.NoError(t, err)
+	slot, found, err := ekm.storage.RetrieveHighestSyncCommitteeSlot(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(10), slot)
+
+	// The same slot can be signed for another subcommittee.
+	_, _, err = km.SignBeaconObject(selectionData(10, 2), phase0.Domain{}, pk, spectypes.DomainSyncCommitteeSelectionProof)
+	require.NoError(t, err)
+
+	// A lower slot is refused, also for contributions.
+	_, _, err = km.SignBeaconObject(selectionData(9, 1), phase0.Domain{}, pk, spectypes.DomainSyncCommitteeSelectionProof)
+	require.ErrorContains(t, err, "lower than the highest signed sync committee slot")
+	_, _, err = km.SignBeaconObject(&altair.ContributionAndProof{
+		Contribution: &altair.SyncCommitteeContribution{Slot: 9},
+	}, phase0.Domain{}, pk, spectypes.DomainContributionAndProof)
+	require.ErrorContains(t, err, "lower than the highest signed sync committee slot")
+
+	_, _, err = km.SignBeaconObject(&altair.ContributionAndProof{
+		Contribution: &altair.SyncCommitteeContribution{
+			Slot:            11,
+			AggregationBits: bitfield.NewBitvector128(),
+		},
+	}, phase0.Domain{}, pk, spectypes.DomainContributionAndProof)
+	require.NoError(t, err)
+	slot, _, err = ekm.storage.RetrieveHighestSyncCommitteeSlot(pk)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(11), slot)
+
+	require.ErrorIs(t, ekm.storage.SaveHighestSyncCommitteeSlot(pk, 11), ErrSyncCommitteeSlotNotIncreasing)
+
+	require.NoError(t, km.RemoveShare(hex.EncodeToString(pk)))
+	_, found, err = ekm.storage.RetrieveHighestSyncCommitteeSlot(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestAccountEnabled(t *testing.T) {
+	km := testKeyManager(t, nil)
+	ekm := km.(*ethKeyManagerSigner)
+
+	sk := &bls.SecretKey{}
+	require.NoError(t, sk.SetHexString(sk1Str))
+	pk := sk.GetPublicKey().Serialize()
+
+	acc, err := ekm.wallet.AccountByPublicKey(hex.EncodeToString(pk))
+	require.NoError(t, err)
+
+	enabled, err := ekm.storage.IsAccountEnabled(acc.ID())
+	require.NoError(t, err)
+	require.True(t, enabled)
+
+	require.NoError(t, ekm.storage.SetAccountEnabled(acc.ID(), false))
+	enabled, err = ekm.storage.IsAccountEnabled(acc.ID())
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	_, _, err = km.SignBeaconObject(spectypes.SSZUint64(1), phase0.Domain{}, pk, spectypes.DomainSelectionProof)
+	require.ErrorIs(t, err, ErrAccountDisabled)
+	_, err = km.SignRoot(&spectypes.SignedSSVMessage{}, spectypes.QBFTSignatureType, pk)
+	require.ErrorIs(t, err, ErrAccountDisabled)
+
+	// Disabled accounts are still listed, with their flag.
+	accounts, err := ekm.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	statuses, err := ekm.ListAccountsWithStatus()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	for _, status := range statuses {
+		require.Equal(t, status.Account.ID() != acc.ID(), status.Enabled)
+	}
+
+	// Enabling the account invalidates the cached flag.
+	require.NoError(t, ekm.storage.SetAccountEnabled(acc.ID(), true))
+	_, _, err = km.SignBeaconObject(spectypes.SSZUint64(1), phase0.Domain{}, pk, spectypes.DomainSelectionProof)
+	require.NoError(t, err)
+	statuses, err = ekm.ListAccountsWithStatus()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.True(t, status.Enabled)
+	}
+
+	require.ErrorContains(t, ekm.storage.SetAccountEnabled(uuid.New(), false), "account not found")
+}
+
+func TestAuditingKeyManager(t *testing.T) {
+	km := testKeyManager(t, nil)
+	storage := km.(*ethKeyManagerSigner).storage.(*storage)
+
+	auditing := NewAuditingKeyManager(km, storage.db, storage.network)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	auditing.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	sk := &bls.SecretKey{}
+	require.NoError(t, sk.SetHexString(sk1Str))
+	pk := sk.GetPublicKey().Serialize()
+
+	_, randaoRoot, err := auditing.SignBeaconObject(spectypes.SSZUint64(5), phase0.Domain{1}, pk, spectypes.DomainRandao)
+	require.NoError(t, err)
+	_, selectionRoot, err := auditing.SignBeaconObject(spectypes.SSZUint64(64), phase0.Domain{2}, pk, spectypes.DomainSelectionProof)
+	require.NoError(t, err)
+
+	// Failed signatures aren't recorded.
+	_, _, err = auditing.SignBeaconObject(spectypes.SSZUint64(4), phase0.Domain{1}, pk, spectypes.DomainRandao)
+	require.Error(t, err)
+
+	records, err := auditing.QueryAuditLog(pk, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	epoch := phase0.Epoch(5)
+	require.Equal(t, &AuditRecord{
+		PubKey:     pk,
+		DomainType: spectypes.DomainRandao,
+		Domain:     phase0.Domain{1},
+		Root:       randaoRoot[:],
+		Epoch:      &epoch,
+		Time:       start.Add(time.Minute),
+	}, records[0])
+
+	slot := phase0.Slot(64)
+	require.Equal(t, &AuditRecord{
+		PubKey:     pk,
+		DomainType: spectypes.DomainSelectionProof,
+		Domain:     phase0.Domain{2},
+		Root:       selectionRoot[:],
+		Slot:       &slot,
+		Time:       start.Add(2 * time.Minute),
+	}, records[1])
+
+	// The time range is applied.
+	records, err = auditing.QueryAuditLog(pk, start.Add(2*time.Minute), start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, phase0.DomainType(spectypes.DomainSelectionProof), records[0].DomainType)
+	records, err = auditing.QueryAuditLog(pk, start, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, phase0.DomainType(spectypes.DomainRandao), records[0].DomainType)
+	// Times beyond the range of keys are clamped.
+	records, err = auditing.QueryAuditLog(pk, time.Time{}, time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	// Other validators have their own records.
+	sk2 := &bls.SecretKey{}
+	require.NoError(t, sk2.SetHexString(sk2Str))
+	records, err = auditing.QueryAuditLog(sk2.GetPublicKey().Serialize(), start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	// The wrapped key manager's storage is still provided.
+	var provider StorageProvider = auditing
+	accounts, err := provider.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+}
+
+func TestImportValidators(t *testing.T) {
+	km := testKeyManager(t, nil)
+	ekm := km.(*ethKeyManagerSigner)
+
+	newKey := func() *bls.SecretKey {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		return sk
+	}
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+
+	sk1, sk2 := newKey(), newKey()
+
+	t.Run("conflicting entries", func(t *testing.T) {
+		_, err := ekm.ImportValidators([]ImportEntry{
+			{ShareKey: sk1, HighestAttestation: attestation(1_000_000, 1_000_001), HighestProposalSlot: 50_000_000},
+			{ShareKey: sk1, HighestAttestation: attestation(1_000_000, 1_000_002), HighestProposalSlot: 50_000_000},
+		})
+		require.ErrorIs(t, err, ErrConflictingImportEntries)
+
+		_, err = ekm.wallet.AccountByPublicKey(sk1.GetPublicKey().SerializeToHexStr())
+		require.Error(t, err)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		result, err := ekm.ImportValidators([]ImportEntry{
+			{ShareKey: sk1, HighestAttestation: attestation(1_000_000, 1_000_001), HighestProposalSlot: 50_000_000},
+			{ShareKey: sk1, HighestAttestation: attestation(1_000_000, 1_000_001), HighestProposalSlot: 50_000_000},
+			{ShareKey: sk2},
+		})
+		require.NoError(t, err)
+		require.Empty(t, result.Failed)
+		require.Equal(t, [][]byte{sk1.GetPublicKey().Serialize(), sk2.GetPublicKey().Serialize()}, result.Imported)
+
+		for _, sk := range []*bls.SecretKey{sk1, sk2} {
+			acc, err := ekm.wallet.AccountByPublicKey(sk.GetPublicKey().SerializeToHexStr())
+			require.NoError(t, err)
+			require.NotNil(t, acc)
+		}
+
+		highestAtt, found, err := ekm.RetrieveHighestAttestation(sk1.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, phase0.Epoch(1_000_000), highestAtt.Source.Epoch)
+		require.Equal(t, phase0.Epoch(1_000_001), highestAtt.Target.Epoch)
+		highestSlot, found, err := ekm.RetrieveHighestProposal(sk1.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, phase0.Slot(50_000_000), highestSlot)
+
+		// Shares without protection data are protected from the current slot.
+		_, found, err = ekm.RetrieveHighestAttestation(sk2.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.True(t, found)
+	})
+
+	t.Run("import never lowers highest values", func(t *testing.T) {
+		result, err := ekm.ImportValidators([]ImportEntry{
+			{ShareKey: sk1, HighestAttestation: attestation(10, 11), HighestProposalSlot: 100},
+		})
+		require.NoError(t, err)
+		require.Empty(t, result.Failed)
+
+		highestAtt, _, err := ekm.RetrieveHighestAttestation(sk1.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.Equal(t, phase0.Epoch(1_000_001), highestAtt.Target.Epoch)
+		highestSlot, _, err := ekm.RetrieveHighestProposal(sk1.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.Equal(t, phase0.Slot(50_000_000), highestSlot)
+	})
+}
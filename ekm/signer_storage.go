@@ -1,6 +1,7 @@
 package ekm
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -8,64 +9,166 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/eth2-key-manager/core"
 	"github.com/bloxapp/eth2-key-manager/encryptor"
 	"github.com/bloxapp/eth2-key-manager/wallets"
 	"github.com/bloxapp/eth2-key-manager/wallets/hd"
-	ssz "github.com/ferranbt/fastssz"
 	"github.com/google/uuid"
+	"github.com/herumi/bls-eth-go-binary/bls"
 	"github.com/pkg/errors"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/logging/fields"
 	"github.com/ssvlabs/ssv/protocol/v2/blockchain/beacon"
 	registry "github.com/ssvlabs/ssv/protocol/v2/blockchain/eth1"
+	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
 	"github.com/ssvlabs/ssv/storage/basedb"
 )
 
 const (
-	prefix                = "signer_data-"
-	walletPrefix          = prefix + "wallet-"
-	walletPath            = "wallet"
-	accountsPrefix        = prefix + "accounts-"
-	accountsPath          = "accounts_%s"
-	highestAttPrefix      = prefix + "highest_att-"
-	highestProposalPrefix = prefix + "highest_prop-"
+	prefix                     = "signer_data-"
+	walletPrefix               = prefix + "wallet-"
+	walletPath                 = "wallet"
+	walletNetworkPath          = "wallet_network"
+	accountsPrefix             = prefix + "accounts-"
+	accountsPath               = "accounts_%s"
+	highestAttPrefix           = prefix + "highest_att-"
+	highestAttHistoryPrefix    = prefix + "highest_att_history-"
+	highestProposalPrefix      = prefix + "highest_prop-"
+	highestRandaoPrefix        = prefix + "highest_randao-"
+	highestSyncCommitteePrefix = prefix + "highest_sync_committee-"
+	dataKeyPrefix              = prefix + "data_key-"
+	dataKeyPath                = "data_key"
+	saltPrefix                 = prefix + "salt-"
+	disabledAccountsPrefix     = prefix + "disabled_accounts-"
+	accountTimesPrefix         = prefix + "account_times-"
+	accountTagsPrefix          = prefix + "account_tags-"
+	canaryPrefix               = prefix + "canary-"
+	canaryPath                 = "canary"
+	feeRecipientPrefix         = prefix + "fee_recipient-"
+	interchangeCursorPrefix    = prefix + "interchange_cursor-"
+	encryptionSchemePrefix     = prefix + "encryption_scheme-"
+	encryptionSchemePath       = "encryption_scheme"
+	schemeUpgradePrefix        = prefix + "encryption_upgrade-"
+	schemeUpgradePath          = "encryption_upgrade"
+	saltPath                   = "salt"
+	// restorePrefix holds the records of a backup being restored. It's outside of prefix,
+	// so that staged records aren't mistaken for signer data.
+	restorePrefix = "signer_restore-"
 )
 
-// Storage represents the interface for ssv node storage
+// Storage represents the interface for ssv node storage. It holds the accounts and their slashing protection;
+// other features of the signer storage are exposed by their own interfaces, such as AccountTagStorage,
+// AccountTimeStorage, InterchangeStorage, BundleStorage and FeeRecipientStorage.
 type Storage interface {
 	registry.RegistryStore
 	core.Storage
 	core.SlashingStore
 
 	RemoveHighestAttestation(pubKey []byte) error
+	WasAttestationSigned(pubKey []byte, data *phase0.AttestationData) (bool, error)
 	RemoveHighestProposal(pubKey []byte) error
+	RetrieveHighestProposals(pubKeys [][]byte) (map[string]phase0.Slot, error)
+	RetrieveHighestAttestationAsOf(pubKey []byte, epoch phase0.Epoch) (*phase0.AttestationData, bool, error)
+	SaveHighest(pubKey []byte, attestation *phase0.AttestationData, proposalSlot phase0.Slot) error
+	SaveHighestRandaoEpoch(pubKey []byte, epoch phase0.Epoch, signature []byte) error
+	RetrieveHighestRandaoEpoch(pubKey []byte) (phase0.Epoch, []byte, bool, error)
+	RemoveHighestRandaoEpoch(pubKey []byte) error
+	SaveHighestSyncCommitteeSlot(pubKey []byte, slot phase0.Slot) error
+	RetrieveHighestSyncCommitteeSlot(pubKey []byte) (phase0.Slot, bool, error)
+	RemoveHighestSyncCommitteeSlot(pubKey []byte) error
+	ResetProtection(pubKey []byte, confirm ResetConfirmation) error
+	ListProtectedPubKeys() ([][]byte, error)
+	StorageSize() (accounts, highestData, wallet int64, err error)
 	SetEncryptionKey(newKey string) error
+	CheckEncryptionKey() error
+	RotateEncryptionKey(newKey string) error
+	UpgradeEncryptionScheme(oldKey string, scheme EncryptionScheme) error
+	VerifyPassphrase(candidate string) (bool, error)
+	SetAccountEnabled(accountID uuid.UUID, enabled bool) error
+	IsAccountEnabled(accountID uuid.UUID) (bool, error)
+	IsAccountDisabled(accountID uuid.UUID) (bool, error)
+	ListAccountsWithStatus() ([]AccountStatus, error)
+	RemoveValidator(accountID uuid.UUID, pubKey []byte) error
+	Snapshot(w io.Writer) error
+	RestoreSnapshot(r io.Reader) error
+	RebuildHighestFromDecided(store qbftstorage.InstanceStore, identifier spectypes.MessageID, pubKeys [][]byte) error
+	BackupWallet(w io.Writer, key string) error
+	RestoreWallet(r io.Reader, key string) error
 	ListAccountsTxn(r basedb.Reader) ([]core.ValidatorAccount, error)
 	SaveAccountTxn(rw basedb.ReadWriter, account core.ValidatorAccount) error
+	ReconcileAccounts(relinkOrphans bool) (*ReconcileReport, error)
+	ReconcileProtection(deleteOrphans bool) ([][]byte, error)
+	ReplaceAllAccounts(accounts []core.ValidatorAccount) error
+	Flush() error
 
 	BeaconNetwork() beacon.BeaconNetwork
 }
 
 type storage struct {
-	db            basedb.Database
-	network       beacon.BeaconNetwork
-	encryptionKey []byte
-	logger        *zap.Logger // struct logger is used because core.Storage does not support passing a logger
-	lock          sync.RWMutex
+	db                  basedb.Database
+	readDB              basedb.Database // serves read-only queries, nil to use db
+	network             beacon.BeaconNetwork
+	encryptionKey       []byte
+	logger              *zap.Logger  // struct logger is used because core.Storage does not support passing a logger
+	lock                sync.RWMutex // not re-entrant, so methods holding it must only call helpers which don't acquire it
+	nonceSource         io.Reader
+	nonces              *nonceTracker               // nil unless nonce reuse check is enabled
+	batchWindow         time.Duration               // highest write batching window, as given to WithHighestWriteBatching
+	batcher             *highestBatcher             // nil unless highest write batching is enabled
+	envelope            bool                        // whether accounts are encrypted with a data key wrapped by the encryption key
+	masterKey           []byte                      // the encryption key which wraps the data key, if envelope is true
+	saltedKeys          bool                        // whether the store's salt is mixed into the encryption key
+	decryptSem          chan struct{}               // bounds concurrent decryptions, nil if unbounded
+	lockedMemory        bool                        // decrypt accounts into locked memory
+	accountFormat       AccountFormat               // serialization format of saved accounts
+	secondaryHex        []string                    // secondary decryption keys, as given to WithSecondaryDecryptionKeys
+	secondaryKeys       [][]byte                    // secondary decryption keys, derived like the encryption key
+	listWorkers         int                         // goroutines decrypting accounts when listing them, serial if at most 1
+	hardened            bool                        // never log about encryption state nor detail decryption errors
+	defaultFeeRecipient *bellatrix.ExecutionAddress // fee recipient of validators without one, nil if unset
+	attHistorySize      int                         // highest attestations kept in the history, none if 0
+	now                 func() time.Time
+
+	disabledLock sync.Mutex
+	disabled     map[string]struct{} // account keys of disabled accounts, nil until loaded
 }
 
-func NewSignerStorage(db basedb.Database, network beacon.BeaconNetwork, logger *zap.Logger) Storage {
-	return &storage{
-		db:      db,
-		network: network,
-		logger:  logger.Named(logging.NameSignerStorage).Named(fmt.Sprintf("%sstorage", prefix)),
-		lock:    sync.RWMutex{},
+// NewSignerStorage returns a signer storage for the given network. Since the network name prefixes
+// all stored keys, an unknown network is rejected rather than writing to a keyspace shared with other stores.
+func NewSignerStorage(db basedb.Database, network beacon.BeaconNetwork, logger *zap.Logger, opts ...StorageOption) (Storage, error) {
+	if network == nil {
+		return nil, errors.New("beacon network is not configured")
 	}
+	if name := network.GetBeaconNetwork(); spectypes.NetworkFromString(string(name)) == "" {
+		return nil, errors.Errorf("unknown beacon network %q", name)
+	}
+
+	s := &storage{
+		db:          db,
+		network:     network,
+		logger:      logger.Named(logging.NameSignerStorage).Named(fmt.Sprintf("%sstorage", prefix)),
+		lock:        sync.RWMutex{},
+		nonceSource: rand.Reader,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// The batcher is built once all options have run, so it writes to the final db.
+	if s.batchWindow > 0 {
+		s.batcher = newHighestBatcher(s.db, s.batchWindow)
+	}
+	return s, nil
 }
 
 // SetEncryptionKey Add a new method to the storage type
@@ -79,6 +182,44 @@ func (s *storage) SetEncryptionKey(newKey string) error {
 		return errors.New("the key must be a valid hexadecimal string")
 	}
 
+	if s.nonces != nil {
+		s.nonces.reset()
+	}
+
+	if len(keyBytes) != 0 {
+		if err := s.checkEncryptionScheme(); err != nil {
+			return err
+		}
+	}
+
+	if s.saltedKeys && len(keyBytes) != 0 {
+		if keyBytes, err = s.deriveKey(keyBytes); err != nil {
+			return err
+		}
+	}
+
+	secondaryKeys, err := s.deriveSecondaryKeys(keyBytes)
+	if err != nil {
+		return err
+	}
+
+	if s.envelope && len(keyBytes) != 0 {
+		if err := s.setMasterKey(keyBytes, secondaryKeys); err != nil {
+			return err
+		}
+		// Accounts are encrypted with the data key, so secondary keys only apply to unwrapping it.
+		s.secondaryKeys = nil
+		// The canary of a store which was just migrated to envelope encryption is encrypted with the master key.
+		return s.refreshCanary(s.encryptionKey, append([][]byte{s.masterKey}, secondaryKeys...))
+	}
+
+	if len(keyBytes) != 0 {
+		if err := s.refreshCanary(keyBytes, secondaryKeys); err != nil {
+			return err
+		}
+	}
+	s.secondaryKeys = secondaryKeys
+
 	// Set the encryption key
 	s.encryptionKey = keyBytes
 	return nil
@@ -88,6 +229,14 @@ func (s *storage) DropRegistryData() error {
 	return s.db.DropPrefix(s.objPrefix(accountsPrefix))
 }
 
+// reader returns the database serving read-only queries.
+func (s *storage) reader() basedb.Database {
+	if s.readDB != nil {
+		return s.readDB
+	}
+	return s.db
+}
+
 func (s *storage) objPrefix(obj string) []byte {
 	return []byte(string(s.network.GetBeaconNetwork()) + obj)
 }
@@ -102,7 +251,7 @@ func (s *storage) Network() core.Network {
 	return core.Network(s.network.GetBeaconNetwork())
 }
 
-// SaveWallet stores the given wallet.
+// SaveWallet stores the given wallet, unless the stored wallet belongs to a different network.
 func (s *storage) SaveWallet(wallet core.Wallet) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -112,9 +261,25 @@ func (s *storage) SaveWallet(wallet core.Wallet) error {
 		return errors.Wrap(err, "failed to marshal wallet")
 	}
 
-	return s.db.Set(s.objPrefix(walletPrefix), []byte(walletPath), data)
+	return s.db.Update(func(txn basedb.Txn) error {
+		// wallets saved before the network was stored are not checked
+		network, found, err := txn.Get(s.objPrefix(walletPrefix), []byte(walletNetworkPath))
+		if err != nil {
+			return errors.Wrap(err, "failed to get wallet network")
+		}
+		if found && string(network.Value) != string(s.network.GetBeaconNetwork()) {
+			return errors.Wrapf(ErrNetworkMismatch, "wallet network %s, storage network %s", network.Value, s.network.GetBeaconNetwork())
+		}
+		if err := txn.Set(s.objPrefix(walletPrefix), []byte(walletPath), data); err != nil {
+			return err
+		}
+		return txn.Set(s.objPrefix(walletPrefix), []byte(walletNetworkPath), []byte(s.network.GetBeaconNetwork()))
+	})
 }
 
+// ErrNetworkMismatch is returned when the stored wallet was created for a different network than the storage's.
+var ErrNetworkMismatch = errors.New("stored wallet belongs to a different network")
+
 // OpenWallet returns nil,err if no wallet was found
 func (s *storage) OpenWallet() (core.Wallet, error) {
 	s.lock.RLock()
@@ -131,6 +296,16 @@ func (s *storage) OpenWallet() (core.Wallet, error) {
 	if len(obj.Value) == 0 {
 		return nil, errors.New("failed to open wallet")
 	}
+
+	// wallets saved before the network was stored are not checked
+	network, found, err := s.db.Get(s.objPrefix(walletPrefix), []byte(walletNetworkPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get wallet network")
+	}
+	if found && string(network.Value) != string(s.network.GetBeaconNetwork()) {
+		return nil, errors.Wrapf(ErrNetworkMismatch, "wallet network %s, storage network %s", network.Value, s.network.GetBeaconNetwork())
+	}
+
 	// decode
 	var ret *hd.Wallet
 	if err := json.Unmarshal(obj.Value, &ret); err != nil {
@@ -140,9 +315,10 @@ func (s *storage) OpenWallet() (core.Wallet, error) {
 	return ret, nil
 }
 
-// ListAccounts returns an empty array for no accounts
+// ListAccounts returns an empty array for no accounts.
+// It reads from the read replica if one is set, so it may miss the latest account changes.
 func (s *storage) ListAccounts() ([]core.ValidatorAccount, error) {
-	return s.ListAccountsTxn(nil)
+	return s.ListAccountsTxn(s.reader())
 }
 
 // ListAccountsTxn returns an empty array for no accounts
@@ -150,14 +326,22 @@ func (s *storage) ListAccountsTxn(r basedb.Reader) ([]core.ValidatorAccount, err
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
+	return s.listAccounts(r)
+}
+
+func (s *storage) listAccounts(r basedb.Reader) ([]core.ValidatorAccount, error) {
+	if s.listWorkers > 1 {
+		return s.listAccountsParallel(r)
+	}
 	ret := make([]core.ValidatorAccount, 0)
 
 	err := s.db.UsingReader(r).GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
-		value, err := s.decryptData(obj.Value)
+		value, release, err := s.decryptData(obj.Value)
 		if err != nil {
 			return errors.Wrap(err, "failed to decrypt accounts")
 		}
 		acc, err := s.decodeAccount(value)
+		release()
 		if err != nil {
 			return errors.Wrap(err, "failed to list accounts")
 		}
@@ -168,22 +352,70 @@ func (s *storage) ListAccountsTxn(r basedb.Reader) ([]core.ValidatorAccount, err
 	return ret, err
 }
 
+// listAccountsParallel reads all the stored accounts first, and then decrypts and decodes them
+// across listWorkers goroutines. Accounts are returned in the same order as listAccounts returns them.
+func (s *storage) listAccountsParallel(r basedb.Reader) ([]core.ValidatorAccount, error) {
+	var values [][]byte
+	err := s.db.UsingReader(r).GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+		values = append(values, obj.Value)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read accounts")
+	}
+
+	ret := make([]core.ValidatorAccount, len(values))
+	var g errgroup.Group
+	g.SetLimit(s.listWorkers)
+	for i, v := range values {
+		g.Go(func() error {
+			value, release, err := s.decryptData(v)
+			if err != nil {
+				return errors.Wrap(err, "failed to decrypt accounts")
+			}
+			acc, err := s.decodeAccount(value)
+			release()
+			if err != nil {
+				return errors.Wrap(err, "failed to list accounts")
+			}
+			ret[i] = acc
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 func (s *storage) SaveAccountTxn(rw basedb.ReadWriter, account core.ValidatorAccount) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	data, err := json.Marshal(account)
+	data, err := s.encodeAccount(account)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal account")
 	}
 
-	key := fmt.Sprintf(accountsPath, account.ID().String())
+	key := []byte(fmt.Sprintf(accountsPath, account.ID().String()))
 
 	encryptedValue, err := s.encryptData(data)
 	if err != nil {
 		return err
 	}
-	return s.db.Using(rw).Set(s.objPrefix(accountsPrefix), []byte(key), encryptedValue)
+
+	save := func(rw basedb.ReadWriter) error {
+		if err := rw.Set(s.objPrefix(accountsPrefix), key, encryptedValue); err != nil {
+			return err
+		}
+		return s.touchAccount(rw, key)
+	}
+	if rw == nil {
+		return s.db.Update(func(txn basedb.Txn) error {
+			return save(txn)
+		})
+	}
+	return save(rw)
 }
 
 // SaveAccount saves the given account
@@ -191,17 +423,174 @@ func (s *storage) SaveAccount(account core.ValidatorAccount) error {
 	return s.SaveAccountTxn(nil, account)
 }
 
-// DeleteAccount deletes account by uuid
+// DeleteAccount deletes account by uuid, along with its enabled flag, times and tags, in a single transaction
 func (s *storage) DeleteAccount(accountID uuid.UUID) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	defer s.resetDisabled()
 
-	key := fmt.Sprintf(accountsPath, accountID.String())
-	return s.db.Delete(s.objPrefix(accountsPrefix), []byte(key))
+	key := []byte(fmt.Sprintf(accountsPath, accountID.String()))
+	return s.db.Update(func(txn basedb.Txn) error {
+		if err := txn.Delete(s.objPrefix(disabledAccountsPrefix), key); err != nil {
+			return errors.Wrap(err, "failed to delete account enabled flag")
+		}
+		if err := txn.Delete(s.objPrefix(accountTimesPrefix), key); err != nil {
+			return errors.Wrap(err, "failed to delete account times")
+		}
+		if err := txn.Delete(s.objPrefix(accountTagsPrefix), key); err != nil {
+			return errors.Wrap(err, "failed to delete account tags")
+		}
+		return txn.Delete(s.objPrefix(accountsPrefix), key)
+	})
+}
+
+// ReplaceAllAccounts replaces all the stored accounts with the given ones in a single transaction,
+// so the stored account set is never partially replaced. Slashing protection data is kept as is.
+func (s *storage) ReplaceAllAccounts(accounts []core.ValidatorAccount) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer s.resetDisabled()
+
+	values := make(map[string][]byte, len(accounts))
+	for _, account := range accounts {
+		data, err := s.encodeAccount(account)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal account")
+		}
+		encryptedValue, err := s.encryptData(data)
+		if err != nil {
+			return err
+		}
+		values[fmt.Sprintf(accountsPath, account.ID().String())] = encryptedValue
+	}
+
+	return s.db.Update(func(txn basedb.Txn) error {
+		var existing [][]byte
+		err := txn.GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+			existing = append(existing, obj.Key)
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to list accounts")
+		}
+		for _, key := range existing {
+			if err := txn.Delete(s.objPrefix(accountsPrefix), key); err != nil {
+				return errors.Wrap(err, "failed to delete account")
+			}
+			if _, replaced := values[string(key)]; replaced {
+				continue
+			}
+			if err := txn.Delete(s.objPrefix(disabledAccountsPrefix), key); err != nil {
+				return errors.Wrap(err, "failed to delete account enabled flag")
+			}
+			if err := txn.Delete(s.objPrefix(accountTimesPrefix), key); err != nil {
+				return errors.Wrap(err, "failed to delete account times")
+			}
+			if err := txn.Delete(s.objPrefix(accountTagsPrefix), key); err != nil {
+				return errors.Wrap(err, "failed to delete account tags")
+			}
+		}
+		for key, value := range values {
+			if err := txn.Set(s.objPrefix(accountsPrefix), []byte(key), value); err != nil {
+				return errors.Wrap(err, "failed to save account")
+			}
+			if err := s.touchAccount(txn, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveValidator deletes the given account together with the slashing protection data of the given public key,
+// and removes the public key from the stored wallet's account index, in a single transaction.
+func (s *storage) RemoveValidator(accountID uuid.UUID, pubKey []byte) error {
+	if err := validatePubKey(pubKey); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer s.resetDisabled()
+
+	// Pending highest writes must not be committed after the deletion.
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	accountKey := []byte(fmt.Sprintf(accountsPath, accountID.String()))
+	deletions := []struct {
+		prefix string
+		key    []byte
+	}{
+		{accountsPrefix, accountKey},
+		{disabledAccountsPrefix, accountKey},
+		{accountTimesPrefix, accountKey},
+		{accountTagsPrefix, accountKey},
+		{highestAttPrefix, pubKey},
+		{highestAttHistoryPrefix, pubKey},
+		{highestProposalPrefix, pubKey},
+		{highestRandaoPrefix, pubKey},
+		{highestSyncCommitteePrefix, pubKey},
+		{feeRecipientPrefix, pubKey},
+	}
+	return s.db.Update(func(txn basedb.Txn) error {
+		if err := s.removeFromWalletIndex(txn, pubKey); err != nil {
+			return err
+		}
+		for _, d := range deletions {
+			if err := txn.Delete(s.objPrefix(d.prefix), d.key); err != nil {
+				return errors.Wrapf(err, "could not delete %s", d.prefix)
+			}
+		}
+		return nil
+	})
+}
+
+// removeFromWalletIndex removes the given public key from the account index of the stored wallet, if any.
+func (s *storage) removeFromWalletIndex(txn basedb.Txn, pubKey []byte) error {
+	obj, found, err := txn.Get(s.objPrefix(walletPrefix), []byte(walletPath))
+	if err != nil {
+		return errors.Wrap(err, "could not get wallet")
+	}
+	if !found {
+		return nil
+	}
+	var wallet map[string]json.RawMessage
+	if err := json.Unmarshal(obj.Value, &wallet); err != nil {
+		return errors.Wrap(err, "failed to unmarshal wallet")
+	}
+	var index map[string]string
+	if err := json.Unmarshal(wallet["indexMapper"], &index); err != nil {
+		return errors.Wrap(err, "failed to unmarshal wallet index")
+	}
+	key := hex.EncodeToString(pubKey)
+	if _, ok := index[key]; !ok {
+		return nil
+	}
+	delete(index, key)
+	if wallet["indexMapper"], err = json.Marshal(index); err != nil {
+		return errors.Wrap(err, "failed to marshal wallet index")
+	}
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal wallet")
+	}
+	return txn.Set(s.objPrefix(walletPrefix), []byte(walletPath), data)
 }
 
 var ErrCantDecrypt = errors.New("can't decrypt stored wallet, wrong password?")
 
+var ErrEncryptionKeyRequired = errors.New("stored account appears to be encrypted, but no encryption key was provided")
+
+// ErrMalformedAccount is returned when a stored account decodes without a usable validation key.
+var ErrMalformedAccount = errors.New("malformed account")
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
 // OpenAccount returns nil,nil if no account was found
 func (s *storage) OpenAccount(accountID uuid.UUID) (core.ValidatorAccount, error) {
 	s.lock.RLock()
@@ -217,10 +606,11 @@ func (s *storage) OpenAccount(accountID uuid.UUID) (core.ValidatorAccount, error
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open account")
 	}
-	decryptedData, err := s.decryptData(obj.Value)
+	decryptedData, release, err := s.decryptData(obj.Value)
 	if err != nil {
-		return nil, errors.Wrap(ErrCantDecrypt, err.Error())
+		return nil, s.cantDecrypt(err)
 	}
+	defer release()
 	return s.decodeAccount(decryptedData)
 }
 
@@ -229,51 +619,186 @@ func (s *storage) decodeAccount(byts []byte) (core.ValidatorAccount, error) {
 		return nil, errors.New("bytes are empty")
 	}
 
+	if len(s.encryptionKey) == 0 && looksEncrypted(byts) {
+		return nil, ErrEncryptionKeyRequired
+	}
+
+	if AccountFormat(byts[0]) == AccountFormatGob {
+		ret, err := decodeGobAccount(byts)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode HD account object")
+		}
+		if err := validateHDAccount(ret); err != nil {
+			return nil, err
+		}
+		ret.SetContext(&core.WalletContext{Storage: s})
+		return ret, nil
+	}
+
+	if isDelegatedAccount(byts) {
+		var acc *delegatedAccount
+		if err := json.Unmarshal(byts, &acc); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal delegated account object")
+		}
+		return acc, nil
+	}
+
 	// decode
-	var ret *wallets.HDAccount
-	if err := json.Unmarshal(byts, &ret); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal HD account object")
+	ret, err := unmarshalHDAccount(byts)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateHDAccount(ret); err != nil {
+		return nil, err
 	}
 	ret.SetContext(&core.WalletContext{Storage: s})
 
 	return ret, nil
 }
 
+// unmarshalHDAccount unmarshals an HD account. HDAccount's unmarshaler panics on fields of unexpected types,
+// which is reported as ErrMalformedAccount.
+func unmarshalHDAccount(byts []byte) (ret *wallets.HDAccount, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, err = nil, errors.Wrapf(ErrMalformedAccount, "%v", r)
+		}
+	}()
+	if err := json.Unmarshal(byts, &ret); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal HD account object")
+	}
+	return ret, nil
+}
+
+// validateHDAccount checks that the given account has a validation key share,
+// so that a malformed account fails when it's loaded rather than when it's used for signing.
+func validateHDAccount(acc *wallets.HDAccount) error {
+	if acc == nil {
+		return errors.Wrap(ErrMalformedAccount, "account is empty")
+	}
+	pubKey := &bls.PublicKey{}
+	if err := pubKey.Deserialize(acc.ValidatorPublicKey()); err != nil || pubKey.IsZero() {
+		return errors.Wrapf(ErrMalformedAccount, "account %s has no validation key", acc.ID())
+	}
+	return nil
+}
+
+// looksEncrypted returns true if the given bytes aren't a plain account and are long enough to be AES-GCM ciphertext.
+func looksEncrypted(byts []byte) bool {
+	return len(byts) >= gcmNonceSize+gcmTagSize && !isPlainAccount(byts)
+}
+
 // SetEncryptor sets the given encryptor to the wallet.
 func (s *storage) SetEncryptor(encryptor encryptor.Encryptor, password []byte) {
 
 }
 
+var (
+	// ErrAttestationSourceRegression is returned when the source epoch is lower than the highest source epoch (surround vote).
+	ErrAttestationSourceRegression = errors.New("attestation source epoch is lower than the highest source epoch")
+	// ErrAttestationTargetRegression is returned when the target epoch is not higher than the highest target epoch (double vote).
+	ErrAttestationTargetRegression = errors.New("attestation target epoch is not higher than the highest target epoch")
+	// ErrProposalSlotRegression is returned when the proposal slot is lower than the highest proposal slot.
+	ErrProposalSlotRegression = errors.New("proposal slot is lower than the highest proposal slot")
+)
+
+// SaveHighestAttestation saves the given attestation as the highest attestation,
+// rejecting it if it violates the EIP-3076 minimal conditions against the stored one.
+// Saving an attestation identical to the stored one is a no-op.
 func (s *storage) SaveHighestAttestation(pubKey []byte, attestation *phase0.AttestationData) error {
+	wait, err := s.saveHighestAttestation(pubKey, attestation)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+func (s *storage) saveHighestAttestation(pubKey []byte, attestation *phase0.AttestationData) (func() error, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if pubKey == nil {
-		return errors.New("pubKey must not be nil")
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, err
 	}
 
 	if attestation == nil {
-		return errors.New("attestation data could not be nil")
+		return nil, errors.New("attestation data could not be nil")
 	}
 
-	data, err := attestation.MarshalSSZ()
+	sszData, err := attestation.MarshalSSZ()
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal attestation")
+		return nil, errors.Wrap(err, "failed to marshal attestation")
+	}
+	data, err := encodeHighestAttestation(attestation)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal attestation")
+	}
+
+	highest, found, err := s.retrieveHighestAttestation(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if highestData, err := highest.MarshalSSZ(); err == nil && bytes.Equal(highestData, sszData) {
+			// Identical to the highest attestation, so there's nothing to write.
+			return s.waitHighest(s.objPrefix(highestAttPrefix), pubKey), nil
+		}
+		if err := checkAttestationRegression(highest, attestation); err != nil {
+			return nil, err
+		}
 	}
 
-	return s.db.Set(s.objPrefix(highestAttPrefix), pubKey, data)
+	writes := []highestWrite{{prefix: s.objPrefix(highestAttPrefix), key: pubKey, value: data}}
+	historyWrite, err := s.attestationHistoryWrite(pubKey, attestation)
+	if err != nil {
+		return nil, err
+	}
+	if historyWrite != nil {
+		writes = append(writes, *historyWrite)
+	}
+	return s.setHighestValues(writes...), nil
+}
+
+// validatePubKey returns an error if the given public key isn't a BLS public key.
+func validatePubKey(pubKey []byte) error {
+	switch {
+	case pubKey == nil:
+		return errors.New("public key must not be nil")
+	case len(pubKey) == 0:
+		return errors.New("public key must not be empty")
+	case len(pubKey) != len(phase0.BLSPubKey{}):
+		return errors.Errorf("public key must be %d bytes, got %d", len(phase0.BLSPubKey{}), len(pubKey))
+	}
+	return nil
+}
+
+// checkAttestationRegression returns the EIP-3076 minimal condition violated by the given attestation, if any.
+func checkAttestationRegression(highest, attestation *phase0.AttestationData) error {
+	if attestation.Source.Epoch < highest.Source.Epoch {
+		return errors.Wrapf(ErrAttestationSourceRegression, "source epoch %d, highest source epoch %d",
+			attestation.Source.Epoch, highest.Source.Epoch)
+	}
+	if attestation.Target.Epoch <= highest.Target.Epoch {
+		return errors.Wrapf(ErrAttestationTargetRegression, "target epoch %d, highest target epoch %d",
+			attestation.Target.Epoch, highest.Target.Epoch)
+	}
+	return nil
 }
 
 func (s *storage) RetrieveHighestAttestation(pubKey []byte) (*phase0.AttestationData, bool, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	if pubKey == nil {
-		return nil, false, errors.New("public key could not be nil")
+	return s.retrieveHighestAttestation(pubKey)
+}
+
+func (s *storage) retrieveHighestAttestation(pubKey []byte) (*phase0.AttestationData, bool, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, false, err
 	}
 
 	// get wallet bytes
-	obj, found, err := s.db.Get(s.objPrefix(highestAttPrefix), pubKey)
+	obj, found, err := s.getHighest(s.objPrefix(highestAttPrefix), pubKey)
 	if err != nil {
 		return nil, found, errors.Wrap(err, "could not get highest attestation from db")
 	}
@@ -281,52 +806,97 @@ func (s *storage) RetrieveHighestAttestation(pubKey []byte) (*phase0.Attestation
 		return nil, false, nil
 	}
 	if len(obj.Value) == 0 {
-		return nil, found, errors.Wrap(err, "highest attestation value is empty")
+		// An empty value carries no protection data, so it's reported as not found for the caller to re-seed.
+		s.logger.Warn("highest attestation value is empty", fields.PubKey(pubKey))
+		return nil, false, nil
 	}
 
 	// decode
-	ret := &phase0.AttestationData{}
-	if err := ret.UnmarshalSSZ(obj.Value); err != nil {
+	ret, err := decodeHighestAttestation(obj.Value)
+	if err != nil {
 		return nil, found, errors.Wrap(err, "could not unmarshal attestation data")
 	}
 	return ret, found, nil
 }
 
+// WasAttestationSigned returns whether the given attestation data equals the highest attestation of the given
+// public key. Only the highest attestation is stored, so attestations signed before it are reported as not signed.
+func (s *storage) WasAttestationSigned(pubKey []byte, data *phase0.AttestationData) (bool, error) {
+	if data == nil {
+		return false, errors.New("attestation data must not be nil")
+	}
+	root, err := data.HashTreeRoot()
+	if err != nil {
+		return false, errors.Wrap(err, "could not hash attestation data")
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	highest, found, err := s.retrieveHighestAttestation(pubKey)
+	if err != nil || !found {
+		return false, err
+	}
+	highestRoot, err := highest.HashTreeRoot()
+	if err != nil {
+		return false, errors.Wrap(err, "could not hash highest attestation")
+	}
+	return root == highestRoot, nil
+}
+
 func (s *storage) RemoveHighestAttestation(pubKey []byte) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	return s.db.Delete(s.objPrefix(highestAttPrefix), pubKey)
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn basedb.Txn) error {
+		if err := txn.Delete(s.objPrefix(highestAttPrefix), pubKey); err != nil {
+			return err
+		}
+		return txn.Delete(s.objPrefix(highestAttHistoryPrefix), pubKey)
+	})
 }
 
 func (s *storage) SaveHighestProposal(pubKey []byte, slot phase0.Slot) error {
+	wait, err := s.saveHighestProposal(pubKey, slot)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+func (s *storage) saveHighestProposal(pubKey []byte, slot phase0.Slot) (func() error, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if pubKey == nil {
-		return errors.New("pubKey must not be nil")
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, err
 	}
 
 	if slot == 0 {
-		return errors.New("invalid proposal slot, slot could not be 0")
+		return nil, errors.New("invalid proposal slot, slot could not be 0")
 	}
 
-	var data []byte
-	data = ssz.MarshalUint64(data, uint64(slot))
-
-	return s.db.Set(s.objPrefix(highestProposalPrefix), pubKey, data)
+	return s.setHighest(s.objPrefix(highestProposalPrefix), pubKey, encodeHighestProposal(slot)), nil
 }
 
 func (s *storage) RetrieveHighestProposal(pubKey []byte) (phase0.Slot, bool, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	if pubKey == nil {
-		return 0, false, errors.New("public key could not be nil")
+	return s.retrieveHighestProposal(pubKey)
+}
+
+func (s *storage) retrieveHighestProposal(pubKey []byte) (phase0.Slot, bool, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return 0, false, err
 	}
 
 	// get wallet bytes
-	obj, found, err := s.db.Get(s.objPrefix(highestProposalPrefix), pubKey)
+	obj, found, err := s.getHighest(s.objPrefix(highestProposalPrefix), pubKey)
 	if err != nil {
 		return 0, found, errors.Wrap(err, "could not get highest proposal from db")
 	}
@@ -334,32 +904,477 @@ func (s *storage) RetrieveHighestProposal(pubKey []byte) (phase0.Slot, bool, err
 		return 0, found, nil
 	}
 	if len(obj.Value) == 0 {
-		return 0, found, errors.Wrap(err, "highest proposal value is empty")
+		// An empty value carries no protection data, so it's reported as not found for the caller to re-seed.
+		s.logger.Warn("highest proposal value is empty", fields.PubKey(pubKey))
+		return 0, false, nil
 	}
 
 	// decode
-	slot := phase0.Slot(ssz.UnmarshallUint64(obj.Value))
+	slot, err := decodeHighestProposal(obj.Value)
+	if err != nil {
+		return 0, found, errors.Wrap(err, "could not unmarshal highest proposal")
+	}
 	return slot, found, nil
 }
 
+// SaveHighest saves the given attestation and proposal slot as the highest attestation and proposal atomically,
+// so that either both or neither are saved. The attestation must satisfy the EIP-3076 minimal conditions
+// against the stored one, and the proposal slot must not be lower than the stored one.
+// Values identical to the stored ones aren't written again.
+func (s *storage) SaveHighest(pubKey []byte, attestation *phase0.AttestationData, proposalSlot phase0.Slot) error {
+	wait, err := s.saveHighest(pubKey, attestation, proposalSlot)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+func (s *storage) saveHighest(pubKey []byte, attestation *phase0.AttestationData, proposalSlot phase0.Slot) (func() error, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := validatePubKey(pubKey); err != nil {
+		return nil, err
+	}
+	if attestation == nil {
+		return nil, errors.New("attestation data could not be nil")
+	}
+	if proposalSlot == 0 {
+		return nil, errors.New("invalid proposal slot, slot could not be 0")
+	}
+
+	attPrefix := s.objPrefix(highestAttPrefix)
+	proposalPrefix := s.objPrefix(highestProposalPrefix)
+
+	attData, err := attestation.MarshalSSZ()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal attestation")
+	}
+	encodedAtt, err := encodeHighestAttestation(attestation)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal attestation")
+	}
+	proposalData := encodeHighestProposal(proposalSlot)
+
+	var writes []highestWrite
+	var waits []func() error
+
+	highestAtt, found, err := s.retrieveHighestAttestation(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	identical := false
+	if found {
+		if highestData, err := highestAtt.MarshalSSZ(); err == nil && bytes.Equal(highestData, attData) {
+			identical = true
+		} else if err := checkAttestationRegression(highestAtt, attestation); err != nil {
+			return nil, err
+		}
+	}
+	if identical {
+		waits = append(waits, s.waitHighest(attPrefix, pubKey))
+	} else {
+		writes = append(writes, highestWrite{prefix: attPrefix, key: pubKey, value: encodedAtt})
+		historyWrite, err := s.attestationHistoryWrite(pubKey, attestation)
+		if err != nil {
+			return nil, err
+		}
+		if historyWrite != nil {
+			writes = append(writes, *historyWrite)
+		}
+	}
+
+	highestSlot, found, err := s.retrieveHighestProposal(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case found && proposalSlot < highestSlot:
+		return nil, errors.Wrapf(ErrProposalSlotRegression, "proposal slot %d, highest proposal slot %d",
+			proposalSlot, highestSlot)
+	case found && proposalSlot == highestSlot:
+		waits = append(waits, s.waitHighest(proposalPrefix, pubKey))
+	default:
+		writes = append(writes, highestWrite{prefix: proposalPrefix, key: pubKey, value: proposalData})
+	}
+
+	if len(writes) > 0 {
+		waits = append(waits, s.setHighestValues(writes...))
+	}
+	return func() error {
+		for _, wait := range waits {
+			if err := wait(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// RetrieveHighestProposals returns the highest proposal slots of the given public keys,
+// keyed by hex-encoded public key. Public keys with no stored proposal are omitted.
+func (s *storage) RetrieveHighestProposals(pubKeys [][]byte) (map[string]phase0.Slot, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ret := make(map[string]phase0.Slot, len(pubKeys))
+	err := s.db.GetMany(s.objPrefix(highestProposalPrefix), pubKeys, func(obj basedb.Obj) error {
+		if len(obj.Value) == 0 {
+			return fmt.Errorf("highest proposal value is empty for %x", obj.Key)
+		}
+		slot, err := decodeHighestProposal(obj.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal highest proposal of %x", obj.Key)
+		}
+		ret[hex.EncodeToString(obj.Key)] = slot
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get highest proposals from db")
+	}
+	if s.batcher != nil {
+		for _, pubKey := range pubKeys {
+			if value, ok := s.batcher.get(s.objPrefix(highestProposalPrefix), pubKey); ok {
+				slot, err := decodeHighestProposal(value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "could not unmarshal highest proposal of %x", pubKey)
+				}
+				ret[hex.EncodeToString(pubKey)] = slot
+			}
+		}
+	}
+	return ret, nil
+}
+
 func (s *storage) RemoveHighestProposal(pubKey []byte) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if err := s.flush(); err != nil {
+		return err
+	}
+
 	return s.db.Delete(s.objPrefix(highestProposalPrefix), pubKey)
 }
 
-func (s *storage) decryptData(objectValue []byte) ([]byte, error) {
-	if len(s.encryptionKey) == 0 {
-		return objectValue, nil
+// ListProtectedPubKeys returns the public keys which have a highest attestation or proposal,
+// regardless of whether they have an account.
+func (s *storage) ListProtectedPubKeys() ([][]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	seen := make(map[string]struct{})
+	var pubKeys [][]byte
+	add := func(pubKey []byte) {
+		if _, ok := seen[string(pubKey)]; ok {
+			return
+		}
+		seen[string(pubKey)] = struct{}{}
+		pubKeys = append(pubKeys, append([]byte{}, pubKey...))
+	}
+
+	for _, p := range []string{highestAttPrefix, highestProposalPrefix} {
+		err := s.db.GetAll(s.objPrefix(p), func(i int, obj basedb.Obj) error {
+			add(obj.Key)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not list slashing protection data")
+		}
+		if s.batcher != nil {
+			for _, pubKey := range s.batcher.pendingKeys(s.objPrefix(p)) {
+				add(pubKey)
+			}
+		}
+	}
+
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+	return pubKeys, nil
+}
+
+// StorageSize returns the total size in bytes of the stored values of the accounts,
+// the highest (slashing protection) data and the wallet. Key sizes and database overhead aren't accounted for,
+// so it's a rough estimate of the space the signer store consumes.
+func (s *storage) StorageSize() (accounts, highestData, wallet int64, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	sizeOf := func(prefixes ...string) (int64, error) {
+		var size int64
+		for _, p := range prefixes {
+			err := s.reader().GetAll(s.objPrefix(p), func(i int, obj basedb.Obj) error {
+				size += int64(len(obj.Value))
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+		return size, nil
+	}
+
+	if accounts, err = sizeOf(accountsPrefix); err != nil {
+		return 0, 0, 0, errors.Wrap(err, "could not get accounts size")
+	}
+	if highestData, err = sizeOf(highestAttPrefix, highestAttHistoryPrefix, highestProposalPrefix, highestRandaoPrefix, highestSyncCommitteePrefix); err != nil {
+		return 0, 0, 0, errors.Wrap(err, "could not get highest data size")
+	}
+	if wallet, err = sizeOf(walletPrefix); err != nil {
+		return 0, 0, 0, errors.Wrap(err, "could not get wallet size")
+	}
+	return accounts, highestData, wallet, nil
+}
+
+// ErrRandaoEpochNotIncreasing is returned when saving a randao epoch which isn't higher than the highest randao epoch.
+var ErrRandaoEpochNotIncreasing = errors.New("randao epoch is not higher than the highest randao epoch")
+
+// SaveHighestRandaoEpoch saves the given epoch and its randao reveal signature as the highest signed randao.
+// The epoch must be strictly higher than the stored one.
+func (s *storage) SaveHighestRandaoEpoch(pubKey []byte, epoch phase0.Epoch, signature []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := validatePubKey(pubKey); err != nil {
+		return err
+	}
+
+	highest, _, found, err := s.retrieveHighestRandaoEpoch(pubKey)
+	if err != nil {
+		return err
+	}
+	if found && epoch <= highest {
+		return errors.Wrapf(ErrRandaoEpochNotIncreasing, "epoch %d, highest epoch %d", epoch, highest)
+	}
+
+	return s.db.Set(s.objPrefix(highestRandaoPrefix), pubKey, encodeHighestRandao(epoch, signature))
+}
+
+// RetrieveHighestRandaoEpoch returns the highest epoch for which a randao reveal was signed, and its signature.
+func (s *storage) RetrieveHighestRandaoEpoch(pubKey []byte) (phase0.Epoch, []byte, bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.retrieveHighestRandaoEpoch(pubKey)
+}
+
+func (s *storage) retrieveHighestRandaoEpoch(pubKey []byte) (phase0.Epoch, []byte, bool, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return 0, nil, false, err
+	}
+
+	obj, found, err := s.db.Get(s.objPrefix(highestRandaoPrefix), pubKey)
+	if err != nil {
+		return 0, nil, found, errors.Wrap(err, "could not get highest randao epoch from db")
+	}
+	if !found {
+		return 0, nil, found, nil
+	}
+	epoch, signature, err := decodeHighestRandao(obj.Value)
+	if err != nil {
+		return 0, nil, found, errors.Wrap(err, "could not unmarshal highest randao epoch")
+	}
+	return epoch, signature, found, nil
+}
+
+func (s *storage) RemoveHighestRandaoEpoch(pubKey []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.db.Delete(s.objPrefix(highestRandaoPrefix), pubKey)
+}
+
+// ResetConfirmation confirms a call which removes slashing protection.
+type ResetConfirmation string
+
+// ConfirmResetProtection must be passed to ResetProtection to confirm the removal of slashing protection.
+const ConfirmResetProtection ResetConfirmation = "I understand the validator may be slashed if its key is used elsewhere"
+
+// ResetProtection deletes all highest (slashing protection) values of the given validator atomically.
+// It's only safe if the validator's key isn't used anywhere else, for example during a controlled key migration,
+// and so it must be confirmed by passing ConfirmResetProtection.
+func (s *storage) ResetProtection(pubKey []byte, confirm ResetConfirmation) error {
+	if confirm != ConfirmResetProtection {
+		return errors.New("resetting slashing protection must be confirmed")
+	}
+	if err := validatePubKey(pubKey); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Pending highest writes must not be committed after the deletion.
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	s.logger.Warn("resetting slashing protection", fields.PubKey(pubKey))
+
+	return s.db.Update(func(txn basedb.Txn) error {
+		for _, p := range []string{highestAttPrefix, highestAttHistoryPrefix, highestProposalPrefix, highestRandaoPrefix, highestSyncCommitteePrefix} {
+			if err := txn.Delete(s.objPrefix(p), pubKey); err != nil {
+				return errors.Wrapf(err, "could not delete %s", p)
+			}
+		}
+		return nil
+	})
+}
+
+// ErrSyncCommitteeSlotNotIncreasing is returned when saving a sync committee slot which isn't higher than the highest one.
+var ErrSyncCommitteeSlotNotIncreasing = errors.New("sync committee slot is not higher than the highest sync committee slot")
+
+// SaveHighestSyncCommitteeSlot saves the given slot as the highest slot signed for a sync committee duty.
+// The slot must be strictly higher than the stored one.
+func (s *storage) SaveHighestSyncCommitteeSlot(pubKey []byte, slot phase0.Slot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := validatePubKey(pubKey); err != nil {
+		return err
+	}
+
+	highest, found, err := s.retrieveHighestSyncCommitteeSlot(pubKey)
+	if err != nil {
+		return err
+	}
+	if found && slot <= highest {
+		return errors.Wrapf(ErrSyncCommitteeSlotNotIncreasing, "slot %d, highest slot %d", slot, highest)
+	}
+
+	return s.db.Set(s.objPrefix(highestSyncCommitteePrefix), pubKey, encodeHighestSyncCommittee(slot))
+}
+
+// RetrieveHighestSyncCommitteeSlot returns the highest slot signed for a sync committee duty.
+func (s *storage) RetrieveHighestSyncCommitteeSlot(pubKey []byte) (phase0.Slot, bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.retrieveHighestSyncCommitteeSlot(pubKey)
+}
+
+func (s *storage) retrieveHighestSyncCommitteeSlot(pubKey []byte) (phase0.Slot, bool, error) {
+	if err := validatePubKey(pubKey); err != nil {
+		return 0, false, err
 	}
 
-	decryptedData, err := s.decrypt(objectValue)
+	obj, found, err := s.db.Get(s.objPrefix(highestSyncCommitteePrefix), pubKey)
+	if err != nil {
+		return 0, found, errors.Wrap(err, "could not get highest sync committee slot from db")
+	}
+	if !found {
+		return 0, found, nil
+	}
+	slot, err := decodeHighestSyncCommittee(obj.Value)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decrypt wallet")
+		return 0, found, errors.Wrap(err, "could not unmarshal highest sync committee slot")
+	}
+	return slot, found, nil
+}
+
+func (s *storage) RemoveHighestSyncCommitteeSlot(pubKey []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.db.Delete(s.objPrefix(highestSyncCommitteePrefix), pubKey)
+}
+
+// setHighest writes the given highest value, and returns a function which waits for the write to be durable.
+// With highest write batching, the write is added to the current batch and is readable through getHighest
+// until it is committed. The returned function must be called without holding the storage lock.
+func (s *storage) setHighest(prefix []byte, key []byte, value []byte) func() error {
+	return s.setHighestValues(highestWrite{prefix: prefix, key: key, value: value})
+}
+
+// setHighestValues is like setHighest, but writes the given highest values atomically.
+func (s *storage) setHighestValues(writes ...highestWrite) func() error {
+	if s.batcher != nil {
+		return s.batcher.set(writes...).wait
 	}
+	err := s.db.Update(func(txn basedb.Txn) error {
+		for _, w := range writes {
+			if err := txn.Set(w.prefix, w.key, w.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return func() error { return err }
+}
 
-	return decryptedData, nil
+// waitHighest returns a function which waits for a pending write of the given highest value to be durable, if any.
+func (s *storage) waitHighest(prefix []byte, key []byte) func() error {
+	if s.batcher != nil {
+		if batch := s.batcher.pendingBatch(prefix, key); batch != nil {
+			return batch.wait
+		}
+	}
+	return func() error { return nil }
+}
+
+// getHighest returns the given highest value, preferring a pending batched write over the stored value.
+func (s *storage) getHighest(prefix []byte, key []byte) (basedb.Obj, bool, error) {
+	if s.batcher != nil {
+		if value, ok := s.batcher.get(prefix, key); ok {
+			return basedb.Obj{Key: key, Value: value}, true, nil
+		}
+	}
+	return s.db.Get(prefix, key)
+}
+
+// Flush commits the pending highest writes. It's a no-op unless highest write batching is enabled.
+func (s *storage) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.flush()
+}
+
+func (s *storage) flush() error {
+	if s.batcher == nil {
+		return nil
+	}
+	return s.batcher.commit()
+}
+
+// decryptData decrypts the given stored value. The returned release function must be called
+// once the decrypted data is no longer used.
+func (s *storage) decryptData(objectValue []byte) ([]byte, func(), error) {
+	if len(s.encryptionKey) == 0 {
+		return objectValue, func() {}, nil
+	}
+
+	if s.decryptSem != nil {
+		s.decryptSem <- struct{}{}
+		defer func() { <-s.decryptSem }()
+	}
+
+	if !s.lockedMemory {
+		decryptedData, err := s.decrypt(objectValue)
+		if err != nil {
+			if s.hardened {
+				return nil, nil, ErrCantDecrypt
+			}
+			return nil, nil, errors.Wrap(err, "failed to decrypt wallet")
+		}
+		return decryptedData, func() {}, nil
+	}
+
+	// The plaintext is never longer than the ciphertext, so decrypting in place doesn't reallocate.
+	buf, err := newLockedBuffer(len(objectValue))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to allocate locked memory")
+	}
+	decryptedData, err := s.open(buf.bytes()[:0], objectValue)
+	if err != nil {
+		buf.release()
+		if s.hardened {
+			return nil, nil, ErrCantDecrypt
+		}
+		return nil, nil, errors.Wrap(err, "failed to decrypt wallet")
+	}
+	return decryptedData, buf.release, nil
 }
 
 func (s *storage) encryptData(objectValue []byte) ([]byte, error) {
@@ -376,7 +1391,11 @@ func (s *storage) encryptData(objectValue []byte) ([]byte, error) {
 }
 
 func (s *storage) encrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.encryptionKey)
+	return s.encryptWithKey(s.encryptionKey, data)
+}
+
+func (s *storage) encryptWithKey(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -384,15 +1403,95 @@ func (s *storage) encrypt(data []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+	nonce, err := s.newNonce(key, gcm.NonceSize())
+	if err != nil {
 		return nil, err
 	}
 	return gcm.Seal(nonce, nonce, data, nil), nil
 }
 
+// newNonce reads a random nonce of the given size for the given key. If nonce reuse check is enabled,
+// a nonce already used with the key is re-drawn a few times before ErrNonceReuse is returned.
+func (s *storage) newNonce(key []byte, size int) ([]byte, error) {
+	for attempt := 0; attempt < maxNonceAttempts; attempt++ {
+		nonce := make([]byte, size)
+		if _, err := io.ReadFull(s.nonceSource, nonce); err != nil {
+			return nil, err
+		}
+		if s.nonces == nil || s.nonces.add(key, nonce) {
+			return nonce, nil
+		}
+		s.sensitiveLogger().Warn("duplicate encryption nonce detected, retrying", zap.Int("attempt", attempt+1))
+	}
+	return nil, ErrNonceReuse
+}
+
+// cantDecrypt returns ErrCantDecrypt for the given decryption failure. The failure's detail
+// is only included outside of hardened mode.
+func (s *storage) cantDecrypt(err error) error {
+	if s.hardened {
+		return ErrCantDecrypt
+	}
+	return errors.Wrap(ErrCantDecrypt, err.Error())
+}
+
+// sensitiveLogger returns the logger for messages about encryption state, which is a no-op in hardened mode.
+func (s *storage) sensitiveLogger() *zap.Logger {
+	if s.hardened {
+		return zap.NewNop()
+	}
+	return s.logger
+}
+
 func (s *storage) decrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.encryptionKey)
+	return s.open(nil, data)
+}
+
+// open decrypts the given data with the encryption key, or else with each of the secondary decryption keys
+// in order, and appends the plaintext to dst.
+func (s *storage) open(dst []byte, data []byte) ([]byte, error) {
+	decrypted, err := openWithKey(dst, s.encryptionKey, data)
+	if err == nil {
+		return decrypted, nil
+	}
+	for i, key := range s.secondaryKeys {
+		if decrypted, keyErr := openWithKey(dst, key, data); keyErr == nil {
+			s.sensitiveLogger().Debug("decrypted data with a secondary key", zap.Int("key_index", i))
+			return decrypted, nil
+		}
+	}
+	return nil, err
+}
+
+// deriveSecondaryKeys decodes the secondary decryption keys, and derives them like the given encryption key.
+// Secondary keys are dropped when encryption is disabled.
+func (s *storage) deriveSecondaryKeys(encryptionKey []byte) ([][]byte, error) {
+	if len(encryptionKey) == 0 {
+		return nil, nil
+	}
+	keys := make([][]byte, 0, len(s.secondaryHex))
+	for _, hexKey := range s.secondaryHex {
+		key, err := hex.DecodeString(hexKey)
+		if err != nil || len(key) == 0 {
+			return nil, errors.New("secondary keys must be valid hexadecimal strings")
+		}
+		if s.saltedKeys {
+			if key, err = s.deriveKey(key); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func decryptWithKey(key []byte, data []byte) ([]byte, error) {
+	return openWithKey(nil, key, data)
+}
+
+// openWithKey decrypts the given data and appends the plaintext to dst.
+func openWithKey(dst []byte, key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -407,7 +1506,7 @@ func (s *storage) decrypt(data []byte) ([]byte, error) {
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 	// #nosec G407 false positive: https://github.com/securego/gosec/issues/1211
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm.Open(dst, nonce, ciphertext, nil)
 }
 
 func (s *storage) BeaconNetwork() beacon.BeaconNetwork {
@@ -1,23 +1,42 @@
 package ekm
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/eth2-key-manager/core"
 	"github.com/bloxapp/eth2-key-manager/encryptor"
 	"github.com/bloxapp/eth2-key-manager/encryptor/keystorev4"
+	"github.com/bloxapp/eth2-key-manager/wallets"
 	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/dgraph-io/badger/v4"
+	ssz "github.com/ferranbt/fastssz"
 	"github.com/google/uuid"
 	"github.com/herumi/bls-eth-go-binary/bls"
+	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
+	ibftstorage "github.com/ssvlabs/ssv/ibft/storage"
 	"github.com/ssvlabs/ssv/logging"
 	"github.com/ssvlabs/ssv/networkconfig"
+	"github.com/ssvlabs/ssv/protocol/v2/blockchain/beacon"
+	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
 	"github.com/ssvlabs/ssv/storage/kv"
 
 	"github.com/ssvlabs/ssv/storage/basedb"
@@ -33,6 +52,12 @@ func getBaseStorage(logger *zap.Logger) (basedb.Database, error) {
 	return kv.NewInMemory(logger, basedb.Options{})
 }
 
+func newSignerStorage(t *testing.T, db basedb.Database, network beacon.BeaconNetwork, logger *zap.Logger, opts ...StorageOption) Storage {
+	s, err := NewSignerStorage(db, network, logger, opts...)
+	require.NoError(t, err)
+	return s
+}
+
 func newStorageForTest(t *testing.T) (Storage, func()) {
 	logger := logging.TestLogger(t)
 	db, err := getBaseStorage(logger)
@@ -40,12 +65,31 @@ func newStorageForTest(t *testing.T) (Storage, func()) {
 		return nil, func() {}
 	}
 
-	s := NewSignerStorage(db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+	s := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
 	return s, func() {
 		db.Close()
 	}
 }
 
+func TestNewSignerStorageNetwork(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = NewSignerStorage(db, beacon.Network{}, logger)
+	require.ErrorContains(t, err, "unknown beacon network")
+
+	_, err = NewSignerStorage(db, beacon.NewNetwork("unknown"), logger)
+	require.ErrorContains(t, err, "unknown beacon network")
+
+	_, err = NewSignerStorage(db, nil, logger)
+	require.ErrorContains(t, err, "not configured")
+
+	_, err = NewSignerStorage(db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+	require.NoError(t, err)
+}
+
 func testWallet(t *testing.T) (core.Wallet, Storage, func()) {
 	threshold.Init()
 	sk := bls.SecretKey{}
@@ -291,7 +335,7 @@ func TestSavingAttestation(t *testing.T) {
 			},
 		},
 		{
-			name: "simple save with no change to latest attestation target",
+			name: "simple save with higher attestation target",
 			att: &phase0.AttestationData{
 				Slot:            30,
 				Index:           1,
@@ -301,7 +345,7 @@ func TestSavingAttestation(t *testing.T) {
 					Root:  [32]byte{},
 				},
 				Target: &phase0.Checkpoint{
-					Epoch: 3,
+					Epoch: 5,
 					Root:  [32]byte{},
 				},
 			},
@@ -365,7 +409,7 @@ func TestSavingHighestAttestation(t *testing.T) {
 			},
 		},
 		{
-			name: "simple save with no change to latest attestation target",
+			name: "simple save with higher attestation target",
 			att: &phase0.AttestationData{
 				Slot:            30,
 				Index:           1,
@@ -375,7 +419,7 @@ func TestSavingHighestAttestation(t *testing.T) {
 					Root:  [32]byte{},
 				},
 				Target: &phase0.Checkpoint{
-					Epoch: 3,
+					Epoch: 5,
 					Root:  [32]byte{},
 				},
 			},
@@ -546,3 +590,2095 @@ func TestDropRegistryData(t *testing.T) {
 	err := signerStorage.DropRegistryData()
 	require.NoError(t, err)
 }
+
+type repeatingReader struct {
+	b byte
+}
+
+func (r repeatingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.b
+	}
+	return len(p), nil
+}
+
+func TestNonceReuseCheck(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithNonceReuseCheck())
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+
+	s := signerStorage.(*storage)
+	s.nonceSource = repeatingReader{b: 1}
+
+	_, err = s.encrypt([]byte("data"))
+	require.NoError(t, err)
+
+	_, err = s.encrypt([]byte("data"))
+	require.ErrorIs(t, err, ErrNonceReuse)
+
+	// A nonce is only a duplicate for the key it was used with.
+	_, err = s.encryptWithKey([]byte("fedcba9876543210fedcba9876543210"), []byte("data"))
+	require.NoError(t, err)
+
+	// Changing the key starts a new nonce session.
+	require.NoError(t, signerStorage.SetEncryptionKey("fedcba9876543210fedcba9876543210"))
+	_, err = s.encrypt([]byte("data"))
+	require.NoError(t, err)
+}
+
+func TestNonceTrackerBound(t *testing.T) {
+	tracker := newNonceTracker()
+	key := []byte("key")
+
+	nonce := func(i int) []byte {
+		return binary.BigEndian.AppendUint32(nil, uint32(i))
+	}
+	for i := 0; i < maxTrackedNonces; i++ {
+		require.True(t, tracker.add(key, nonce(i)))
+	}
+	require.False(t, tracker.add(key, nonce(0)))
+
+	// Once full, the oldest nonce is forgotten.
+	require.True(t, tracker.add(key, nonce(maxTrackedNonces)))
+	require.True(t, tracker.add(key, nonce(0)))
+	require.False(t, tracker.add(key, nonce(2)))
+	require.Len(t, tracker.keys[sha256.Sum256(key)].seen, maxTrackedNonces)
+}
+
+func TestRetrieveHighestProposals(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	pk1 := _byteArray(pk1Str)
+	pk2 := _byteArray(pk2Str)
+	missing := make([]byte, 48)
+
+	require.NoError(t, signerStorage.SaveHighestProposal(pk1, 10))
+	require.NoError(t, signerStorage.SaveHighestProposal(pk2, 20))
+
+	proposals, err := signerStorage.RetrieveHighestProposals([][]byte{pk1, pk2, missing})
+	require.NoError(t, err)
+	require.Len(t, proposals, 2)
+	require.Equal(t, phase0.Slot(10), proposals[pk1Str])
+	require.Equal(t, phase0.Slot(20), proposals[pk2Str])
+}
+
+func TestReconcileAccounts(t *testing.T) {
+	wallet, signerStorage, done := testWallet(t)
+	defer done()
+
+	report, err := signerStorage.ReconcileAccounts(false)
+	require.NoError(t, err)
+	require.Empty(t, report.Orphaned)
+	require.Empty(t, report.Dangling)
+
+	// Dangling: the account is deleted from storage but still referenced by the wallet.
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	dangling := accounts[0].ID()
+	require.NoError(t, signerStorage.DeleteAccount(dangling))
+
+	// Orphaned: the account is stored without being referenced by the wallet.
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	key, err := core.NewHDKeyFromPrivateKey(sk.Serialize(), "")
+	require.NoError(t, err)
+	orphan := wallets.NewValidatorAccount("", key, nil, "", nil)
+	require.NoError(t, signerStorage.SaveAccount(orphan))
+
+	report, err = signerStorage.ReconcileAccounts(false)
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{orphan.ID()}, report.Orphaned)
+	require.Equal(t, []uuid.UUID{dangling}, report.Dangling)
+	require.Empty(t, report.Relinked)
+
+	_, err = wallet.AccountByPublicKey(hex.EncodeToString(orphan.ValidatorPublicKey()))
+	require.Error(t, err)
+
+	report, err = signerStorage.ReconcileAccounts(true)
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{orphan.ID()}, report.Relinked)
+
+	reopened, err := signerStorage.OpenWallet()
+	require.NoError(t, err)
+	_, err = reopened.AccountByPublicKey(hex.EncodeToString(orphan.ValidatorPublicKey()))
+	require.NoError(t, err)
+
+	report, err = signerStorage.ReconcileAccounts(false)
+	require.NoError(t, err)
+	require.Empty(t, report.Orphaned)
+}
+
+func TestSaveHighestAttestationMinimalConditions(t *testing.T) {
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		att         *phase0.AttestationData
+		expectedErr error
+	}{
+		{name: "higher source and target", att: attestation(6, 11)},
+		{name: "same source, higher target", att: attestation(5, 11)},
+		{name: "identical", att: attestation(5, 10)},
+		{name: "double vote", att: attestation(6, 10), expectedErr: ErrAttestationTargetRegression},
+		{name: "lower target", att: attestation(5, 9), expectedErr: ErrAttestationTargetRegression},
+		{name: "surrounding vote", att: attestation(4, 11), expectedErr: ErrAttestationSourceRegression},
+		{name: "surrounded vote", att: attestation(6, 9), expectedErr: ErrAttestationTargetRegression},
+		{name: "lower source and target", att: attestation(4, 9), expectedErr: ErrAttestationSourceRegression},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			signerStorage, done := newStorageForTest(t)
+			defer done()
+
+			pk := _byteArray(pk1Str)
+			require.NoError(t, signerStorage.SaveHighestAttestation(pk, attestation(5, 10)))
+
+			err := signerStorage.SaveHighestAttestation(pk, test.att)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			highest, found, err := signerStorage.RetrieveHighestAttestation(pk)
+			require.NoError(t, err)
+			require.True(t, found)
+			if test.expectedErr != nil {
+				require.Equal(t, attestation(5, 10), highest)
+			} else {
+				require.Equal(t, test.att, highest)
+			}
+		})
+	}
+}
+
+func TestEncryptionKeyRequired(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	require.NoError(t, signerStorage.SetEncryptionKey(""))
+
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.ErrorIs(t, err, ErrEncryptionKeyRequired)
+
+	_, err = signerStorage.ListAccounts()
+	require.ErrorIs(t, err, ErrEncryptionKeyRequired)
+}
+
+func TestHighestWriteBatchingUsesFinalDB(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The batcher writes through the retrying db, even though batching is configured first.
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger,
+		WithHighestWriteBatching(time.Millisecond), WithDBRetry(3, time.Millisecond))
+	s := signerStorage.(*storage)
+	require.NotNil(t, s.batcher)
+	require.IsType(t, &retryDB{}, s.batcher.db)
+	require.Same(t, s.db, s.batcher.db)
+}
+
+func TestHighestWriteBatching(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithHighestWriteBatching(time.Hour))
+	s := signerStorage.(*storage)
+
+	pk1 := _byteArray(pk1Str)
+	pk2 := _byteArray(pk2Str)
+	att := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 5},
+		Target: &phase0.Checkpoint{Epoch: 10},
+	}
+
+	saved := make(chan error, 3)
+	go func() { saved <- signerStorage.SaveHighestAttestation(pk1, att) }()
+	go func() { saved <- signerStorage.SaveHighestProposal(pk2, 20) }()
+
+	// Pending writes are readable, but not yet committed nor released.
+	require.Eventually(t, func() bool {
+		_, found, _ := signerStorage.RetrieveHighestAttestation(pk1)
+		_, found2, _ := signerStorage.RetrieveHighestProposal(pk2)
+		return found && found2
+	}, time.Second, 10*time.Millisecond)
+
+	_, found, err := db.Get(s.objPrefix(highestAttPrefix), pk1)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Empty(t, saved)
+
+	// Regression checks apply against pending writes.
+	require.ErrorIs(t, signerStorage.SaveHighestAttestation(pk1, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 5},
+		Target: &phase0.Checkpoint{Epoch: 9},
+	}), ErrAttestationTargetRegression)
+
+	proposals, err := signerStorage.RetrieveHighestProposals([][]byte{pk2})
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(20), proposals[pk2Str])
+
+	// Saving an identical attestation waits for the pending write.
+	go func() { saved <- signerStorage.SaveHighestAttestation(pk1, att) }()
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, saved)
+
+	require.NoError(t, signerStorage.Flush())
+	require.NoError(t, <-saved)
+	require.NoError(t, <-saved)
+	require.NoError(t, <-saved)
+
+	_, found, err = db.Get(s.objPrefix(highestAttPrefix), pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	_, found, err = db.Get(s.objPrefix(highestProposalPrefix), pk2)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// Writes are committed once the window elapses.
+	s.batcher.window = 10 * time.Millisecond
+	require.NoError(t, signerStorage.SaveHighestProposal(pk2, 30))
+	obj, found, err := db.Get(s.objPrefix(highestProposalPrefix), pk2)
+	require.NoError(t, err)
+	require.True(t, found)
+	slot, err := decodeHighestProposal(obj.Value)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(30), slot)
+}
+
+func TestSaveHighestRandaoEpoch(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	pk := _byteArray(pk1Str)
+
+	_, _, found, err := signerStorage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, signerStorage.SaveHighestRandaoEpoch(pk, 10, []byte{1, 2, 3}))
+	require.ErrorIs(t, signerStorage.SaveHighestRandaoEpoch(pk, 10, []byte{1, 2, 3}), ErrRandaoEpochNotIncreasing)
+	require.ErrorIs(t, signerStorage.SaveHighestRandaoEpoch(pk, 9, []byte{1, 2, 3}), ErrRandaoEpochNotIncreasing)
+
+	epoch, signature, found, err := signerStorage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(10), epoch)
+	require.Equal(t, []byte{1, 2, 3}, signature)
+
+	require.NoError(t, signerStorage.RemoveHighestRandaoEpoch(pk))
+	_, _, found, err = signerStorage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestListProtectedPubKeys(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	pubKeys, err := signerStorage.ListProtectedPubKeys()
+	require.NoError(t, err)
+	require.Empty(t, pubKeys)
+
+	pk1 := _byteArray(pk1Str)
+	pk2 := _byteArray(pk2Str)
+
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk1, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}))
+	require.NoError(t, signerStorage.SaveHighestProposal(pk1, 10))
+	require.NoError(t, signerStorage.SaveHighestProposal(pk2, 10))
+
+	pubKeys, err = signerStorage.ListProtectedPubKeys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]byte{pk1, pk2}, pubKeys)
+
+	require.NoError(t, signerStorage.RemoveHighestProposal(pk2))
+	pubKeys, err = signerStorage.ListProtectedPubKeys()
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{pk1}, pubKeys)
+}
+
+func TestEnvelopeEncryption(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key1 = "0123456789abcdef0123456789abcdef"
+		key2 = "fedcba9876543210fedcba9876543210"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+
+	// Accounts stored before envelope encryption are encrypted with the key directly.
+	legacyStorage := newSignerStorage(t, db, network, logger)
+	require.NoError(t, legacyStorage.SetEncryptionKey(key1))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: legacyStorage})
+	require.NoError(t, legacyStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	accountKey := []byte(fmt.Sprintf(accountsPath, acc.ID().String()))
+	rawAccount := func() []byte {
+		obj, found, err := db.Get(legacyStorage.(*storage).objPrefix(accountsPrefix), accountKey)
+		require.NoError(t, err)
+		require.True(t, found)
+		return obj.Value
+	}
+	legacyAccount := rawAccount()
+
+	// Enabling envelope encryption re-encrypts the accounts with a new data key.
+	signerStorage := newSignerStorage(t, db, network, logger, WithEnvelopeEncryption())
+	require.NoError(t, signerStorage.SetEncryptionKey(key1))
+	require.NotEqual(t, legacyAccount, rawAccount())
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.NoError(t, err)
+
+	// Rotation only re-wraps the data key.
+	envelopeAccount := rawAccount()
+	require.NoError(t, signerStorage.RotateEncryptionKey(key2))
+	require.Equal(t, envelopeAccount, rawAccount())
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.NoError(t, err)
+
+	reopened := newSignerStorage(t, db, network, logger, WithEnvelopeEncryption())
+	require.ErrorIs(t, reopened.SetEncryptionKey(key1), ErrCantDecrypt)
+	require.NoError(t, reopened.SetEncryptionKey(key2))
+	opened, err := reopened.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.Equal(t, acc.ValidatorPublicKey(), opened.ValidatorPublicKey())
+
+	require.ErrorContains(t, legacyStorage.RotateEncryptionKey(key2), "requires envelope encryption")
+}
+
+func TestSecondaryDecryptionKeys(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key1 = "0123456789abcdef0123456789abcdef"
+		key2 = "fedcba9876543210fedcba9876543210"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+
+	oldStorage := newSignerStorage(t, db, network, logger)
+	require.NoError(t, oldStorage.SetEncryptionKey(key1))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: oldStorage})
+	require.NoError(t, oldStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	newAccount := func(w core.Wallet, index int) core.ValidatorAccount {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		acc, err := w.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+		require.NoError(t, err)
+		return acc
+	}
+	oldAccount := newAccount(wallet, 0)
+
+	// Without the previous key, accounts encrypted with it can't be decrypted.
+	plainStorage := newSignerStorage(t, db, network, logger)
+	require.NoError(t, plainStorage.SetEncryptionKey(key2))
+	_, err = plainStorage.OpenAccount(oldAccount.ID())
+	require.Error(t, err)
+
+	// With the previous key as a secondary key, both old and new accounts are decrypted,
+	// and new accounts are encrypted with the primary key.
+	rotatingStorage := newSignerStorage(t, db, network, logger, WithSecondaryDecryptionKeys(key1))
+	require.NoError(t, rotatingStorage.SetEncryptionKey(key2))
+	opened, err := rotatingStorage.OpenAccount(oldAccount.ID())
+	require.NoError(t, err)
+	require.Equal(t, oldAccount.ValidatorPublicKey(), opened.ValidatorPublicKey())
+
+	rotatingWallet := hd.NewWallet(&core.WalletContext{Storage: rotatingStorage})
+	newAcc := newAccount(rotatingWallet, 1)
+	_, err = plainStorage.OpenAccount(newAcc.ID())
+	require.NoError(t, err)
+
+	invalid := newSignerStorage(t, db, network, logger, WithSecondaryDecryptionKeys("not hex"))
+	require.ErrorContains(t, invalid.SetEncryptionKey(key2), "secondary keys")
+}
+
+func TestHardenedMode(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key1 = "0123456789abcdef0123456789abcdef"
+		key2 = "fedcba9876543210fedcba9876543210"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+
+	oldStorage := newSignerStorage(t, db, network, logger)
+	require.NoError(t, oldStorage.SetEncryptionKey(key1))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: oldStorage})
+	require.NoError(t, oldStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zap.DebugLevel)
+	observedLogger := zap.New(core)
+
+	// Decryption errors carry no detail beyond ErrCantDecrypt.
+	hardened := newSignerStorage(t, db, network, observedLogger, WithHardenedMode())
+	require.NoError(t, hardened.SetEncryptionKey(key2))
+	_, err = hardened.OpenAccount(acc.ID())
+	require.Equal(t, ErrCantDecrypt, err)
+	_, err = hardened.ListAccounts()
+	require.ErrorIs(t, err, ErrCantDecrypt)
+	for _, sensitive := range []string{"cipher", "authentication", key1, key2} {
+		require.NotContains(t, err.Error(), sensitive)
+	}
+
+	// Nothing is logged about encryption state.
+	rotating := newSignerStorage(t, db, network, observedLogger, WithHardenedMode(), WithSecondaryDecryptionKeys(key1))
+	require.NoError(t, rotating.SetEncryptionKey(key2))
+	_, err = rotating.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.Zero(t, logs.Len())
+
+	// Without hardened mode, the same operations are detailed.
+	plain := newSignerStorage(t, db, network, observedLogger, WithSecondaryDecryptionKeys(key1))
+	require.NoError(t, plain.SetEncryptionKey(key2))
+	_, err = plain.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.Equal(t, 1, logs.FilterMessage("decrypted data with a secondary key").Len())
+}
+
+func TestCombinedEncryptionModes(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key1 = "0123456789abcdef0123456789abcdef"
+		key2 = "fedcba9876543210fedcba9876543210"
+		key3 = "00112233445566778899aabbccddeeff"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+
+	observedCore, logs := observer.New(zap.DebugLevel)
+	observedLogger := zap.New(observedCore)
+	newStorage := func(secondaryKeys ...string) Storage {
+		return newSignerStorage(t, db, network, observedLogger,
+			WithEnvelopeEncryption(),
+			WithSaltedKeyDerivation(),
+			WithSecondaryDecryptionKeys(secondaryKeys...),
+			WithHardenedMode(),
+		)
+	}
+
+	signerStorage := newStorage(key3)
+	require.NoError(t, signerStorage.SetEncryptionKey(key1))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	// Rotation re-wraps the data key with the new salted key.
+	require.NoError(t, signerStorage.RotateEncryptionKey(key2))
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.NoError(t, err)
+
+	rotated := newStorage()
+	require.NoError(t, rotated.SetEncryptionKey(key2))
+	_, err = rotated.OpenAccount(acc.ID())
+	require.NoError(t, err)
+
+	// A secondary key unwraps the data key when the primary key doesn't.
+	fallback := newStorage(key2)
+	require.NoError(t, fallback.SetEncryptionKey(key1))
+	_, err = fallback.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.NoError(t, fallback.RotateEncryptionKey(key3))
+
+	// The previous keys no longer unwrap the data key, and the error carries no detail.
+	for _, key := range []string{key1, key2} {
+		require.Equal(t, ErrCantDecrypt, newStorage().SetEncryptionKey(key))
+	}
+	reopened := newStorage(key1)
+	require.NoError(t, reopened.SetEncryptionKey(key3))
+	_, err = reopened.OpenAccount(acc.ID())
+	require.NoError(t, err)
+
+	// Nothing is logged about encryption state.
+	require.Zero(t, logs.Len())
+}
+
+func TestSaltedKeyDerivation(t *testing.T) {
+	logger := logging.TestLogger(t)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	const key = "0123456789abcdef0123456789abcdef"
+
+	newDB := func() basedb.Database {
+		db, err := getBaseStorage(logger)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	// Identical keys yield distinct keys across new stores.
+	db1, db2 := newDB(), newDB()
+	s1 := newSignerStorage(t, db1, network, logger, WithSaltedKeyDerivation())
+	s2 := newSignerStorage(t, db2, network, logger, WithSaltedKeyDerivation())
+	require.NoError(t, s1.SetEncryptionKey(key))
+	require.NoError(t, s2.SetEncryptionKey(key))
+	require.NotEqual(t, _byteArray(key), s1.(*storage).encryptionKey)
+	require.NotEqual(t, s1.(*storage).encryptionKey, s2.(*storage).encryptionKey)
+
+	// The salt is persisted, so reopening derives the same key.
+	require.NoError(t, s1.SaveWallet(hd.NewWallet(&core.WalletContext{Storage: s1})))
+	reopened := newSignerStorage(t, db1, network, logger, WithSaltedKeyDerivation())
+	require.NoError(t, reopened.SetEncryptionKey(key))
+	require.Equal(t, s1.(*storage).encryptionKey, reopened.(*storage).encryptionKey)
+
+	// Legacy stores with a wallet but no salt use the key as is.
+	legacyDB := newDB()
+	legacy := newSignerStorage(t, legacyDB, network, logger)
+	require.NoError(t, legacy.SetEncryptionKey(key))
+	require.NoError(t, legacy.SaveWallet(hd.NewWallet(&core.WalletContext{Storage: legacy})))
+	legacy = newSignerStorage(t, legacyDB, network, logger, WithSaltedKeyDerivation())
+	require.NoError(t, legacy.SetEncryptionKey(key))
+	require.Equal(t, _byteArray(key), legacy.(*storage).encryptionKey)
+}
+
+func TestUpgradeEncryptionScheme(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key      = "0123456789abcdef0123456789abcdef"
+		wrongKey = "fedcba9876543210fedcba9876543210"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	scheme := EncryptionScheme{SaltedKey: true, Envelope: true}
+
+	legacy := newSignerStorage(t, db, network, logger)
+	require.NoError(t, legacy.SetEncryptionKey(key))
+	require.NoError(t, legacy.SaveWallet(hd.NewWallet(&core.WalletContext{Storage: legacy})))
+	saveTestAccounts(t, legacy, 3)
+
+	upgraded := newSignerStorage(t, db, network, logger, WithSaltedKeyDerivation(), WithEnvelopeEncryption())
+	require.ErrorContains(t, legacy.UpgradeEncryptionScheme(key, scheme), "storage options do not match")
+	require.ErrorIs(t, upgraded.UpgradeEncryptionScheme(wrongKey, scheme), ErrWrongEncryptionKey)
+	pending, err := upgraded.(*storage).pendingSchemeUpgrade()
+	require.NoError(t, err)
+	require.Nil(t, pending)
+
+	// Simulate an upgrade which was interrupted after rewriting the first account.
+	s := upgraded.(*storage)
+	pending, err = s.newSchemeUpgrade(scheme, _byteArray(key))
+	require.NoError(t, err)
+	_, accountKey, err := s.schemeKeys(scheme, pending.Salt, pending.WrappedDataKey, _byteArray(key))
+	require.NoError(t, err)
+	var first basedb.Obj
+	require.NoError(t, db.GetAll(s.objPrefix(accountsPrefix), func(i int, obj basedb.Obj) error {
+		if i == 0 {
+			first = obj
+		}
+		return nil
+	}))
+	decrypted, err := decryptWithKey(_byteArray(key), first.Value)
+	require.NoError(t, err)
+	encrypted, err := s.encryptWithKey(accountKey, decrypted)
+	require.NoError(t, err)
+	require.NoError(t, db.Set(s.objPrefix(accountsPrefix), first.Key, encrypted))
+
+	require.ErrorContains(t, upgraded.SetEncryptionKey(key), "upgrade is in progress")
+	require.ErrorContains(t, upgraded.UpgradeEncryptionScheme(key, EncryptionScheme{SaltedKey: true}), "storage options do not match")
+
+	// Resuming the upgrade rewrites the remaining accounts.
+	require.NoError(t, upgraded.UpgradeEncryptionScheme(key, scheme))
+	require.Equal(t, accountKey, s.encryptionKey)
+	accounts, err := upgraded.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+
+	// Upgrading again does nothing.
+	require.NoError(t, upgraded.UpgradeEncryptionScheme(key, scheme))
+	require.Equal(t, accountKey, s.encryptionKey)
+
+	// The store is now opened with the options of the new scheme only.
+	reopened := newSignerStorage(t, db, network, logger, WithSaltedKeyDerivation(), WithEnvelopeEncryption())
+	require.NoError(t, reopened.SetEncryptionKey(key))
+	require.NoError(t, reopened.CheckEncryptionKey())
+	accounts, err = reopened.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+	legacy = newSignerStorage(t, db, network, logger)
+	require.ErrorContains(t, legacy.SetEncryptionKey(key), "do not match the stored encryption scheme")
+}
+
+func TestSnapshot(t *testing.T) {
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+	pk1 := _byteArray(pk1Str)
+	pk2 := _byteArray(pk2Str)
+
+	source, done := newStorageForTest(t)
+	defer done()
+	require.NoError(t, source.SaveHighestAttestation(pk1, attestation(5, 10)))
+	require.NoError(t, source.SaveHighestProposal(pk1, 100))
+	require.NoError(t, source.SaveHighestAttestation(pk2, attestation(5, 10)))
+	require.NoError(t, source.SaveHighestProposal(pk2, 100))
+
+	var buf bytes.Buffer
+	require.NoError(t, source.Snapshot(&buf))
+
+	target, done2 := newStorageForTest(t)
+	defer done2()
+	// pk2 is already higher in the target, and must not be lowered.
+	require.NoError(t, target.SaveHighestAttestation(pk2, attestation(4, 12)))
+	require.NoError(t, target.SaveHighestProposal(pk2, 200))
+
+	require.NoError(t, target.RestoreSnapshot(bytes.NewReader(buf.Bytes())))
+
+	att, found, err := target.RetrieveHighestAttestation(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, attestation(5, 10), att)
+	slot, found, err := target.RetrieveHighestProposal(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(100), slot)
+
+	att, _, err = target.RetrieveHighestAttestation(pk2)
+	require.NoError(t, err)
+	require.Equal(t, attestation(5, 12), att)
+	slot, _, err = target.RetrieveHighestProposal(pk2)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(200), slot)
+
+	require.ErrorContains(t, target.RestoreSnapshot(strings.NewReader(`{"network":"other"}`)), "does not match")
+}
+
+func TestBackupWallet(t *testing.T) {
+	const (
+		encryptionKey = "0123456789abcdef0123456789abcdef"
+		backupKey     = "fedcba9876543210fedcba9876543210"
+	)
+	source, done := newStorageForTest(t)
+	defer done()
+	require.NoError(t, source.SetEncryptionKey(encryptionKey))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: source})
+	require.NoError(t, source.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+	pubKey := acc.ValidatorPublicKey()
+	require.NoError(t, source.SaveHighestProposal(pubKey, 100))
+	// Enough records to span several chunks.
+	for i := 0; i < 3000; i++ {
+		other := make([]byte, 48)
+		binary.BigEndian.PutUint32(other, uint32(i))
+		require.NoError(t, source.SaveHighestProposal(other, 100))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, source.BackupWallet(&buf, backupKey))
+	backup := buf.Bytes()
+	require.NotContains(t, string(backup), "accounts_"+acc.ID().String())
+
+	target, done2 := newStorageForTest(t)
+	defer done2()
+	require.NoError(t, target.SetEncryptionKey(encryptionKey))
+	require.ErrorContains(t, target.RestoreWallet(bytes.NewReader(backup), encryptionKey), "authenticate")
+
+	// A truncated backup fails after staging its first chunks, and leaves nothing behind.
+	require.ErrorIs(t, target.RestoreWallet(bytes.NewReader(backup[:len(backup)-10]), backupKey), io.ErrUnexpectedEOF)
+	_, err = target.OpenWallet()
+	require.Error(t, err)
+	_, found, err := target.RetrieveHighestProposal(pubKey)
+	require.NoError(t, err)
+	require.False(t, found)
+	staged, err := target.(*storage).db.CountPrefix(target.(*storage).objPrefix(restorePrefix))
+	require.NoError(t, err)
+	require.Zero(t, staged)
+
+	require.NoError(t, target.RestoreWallet(bytes.NewReader(backup), backupKey))
+
+	restored, err := target.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.Equal(t, pubKey, restored.ValidatorPublicKey())
+	slot, found, err := target.RetrieveHighestProposal(pubKey)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(100), slot)
+	staged, err = target.(*storage).db.CountPrefix(target.(*storage).objPrefix(restorePrefix))
+	require.NoError(t, err)
+	require.Zero(t, staged)
+
+	require.ErrorContains(t, target.RestoreWallet(bytes.NewReader(backup), backupKey), "already has a wallet")
+}
+
+func TestBackupChunks(t *testing.T) {
+	const network = "holesky"
+	aead, err := newBackupCipher("0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	header, err := newBackupHeader(network)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	bw, err := newBackupWriter(&buf, aead, header, 64)
+	require.NoError(t, err)
+	// Written in uneven pieces, to cross chunk boundaries.
+	for i := 0; i < len(data); i += 100 {
+		_, err := bw.Write(data[i : i+100])
+		require.NoError(t, err)
+	}
+	require.NoError(t, bw.Close())
+	backup := buf.Bytes()
+
+	read := func(backup []byte) ([]byte, error) {
+		br, err := newBackupReader(bytes.NewReader(backup), aead, network)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(br)
+	}
+	decrypted, err := read(backup)
+	require.NoError(t, err)
+	require.Equal(t, data, decrypted)
+
+	// A tampered chunk fails authentication.
+	tampered := bytes.Clone(backup)
+	tampered[len(header)+10] ^= 1
+	_, err = read(tampered)
+	require.ErrorContains(t, err, "could not authenticate backup chunk 0")
+
+	// Dropping the final chunk is detected, even at a chunk boundary.
+	chunkLen := 5 + 64 + aead.Overhead()
+	_, err = read(backup[:len(header)+chunkLen*2])
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Flagging an intermediate chunk as final fails authentication.
+	truncated := bytes.Clone(backup[:len(header)+chunkLen])
+	truncated[len(header)] = 1
+	_, err = read(truncated)
+	require.ErrorContains(t, err, "authenticate")
+
+	_, err = newBackupReader(bytes.NewReader(backup), aead, "mainnet")
+	require.ErrorContains(t, err, "does not match")
+}
+
+func TestMaxConcurrentDecryptions(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithMaxConcurrentDecryptions(1))
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	// Occupy the only decryption slot.
+	s := signerStorage.(*storage)
+	s.decryptSem <- struct{}{}
+
+	opened := make(chan error, 1)
+	go func() {
+		_, err := signerStorage.OpenAccount(acc.ID())
+		opened <- err
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("decryption should wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-s.decryptSem
+	require.NoError(t, <-opened)
+}
+
+func TestHighestValuesInvalidPubKey(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	att := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+
+	tests := []struct {
+		name        string
+		pubKey      []byte
+		expectedErr string
+	}{
+		{name: "nil", pubKey: nil, expectedErr: "public key must not be nil"},
+		{name: "empty", pubKey: []byte{}, expectedErr: "public key must not be empty"},
+		{name: "too short", pubKey: make([]byte, 47), expectedErr: "public key must be 48 bytes, got 47"},
+		{name: "too long", pubKey: make([]byte, 49), expectedErr: "public key must be 48 bytes, got 49"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			require.EqualError(t, signerStorage.SaveHighestAttestation(test.pubKey, att), test.expectedErr)
+			_, _, err := signerStorage.RetrieveHighestAttestation(test.pubKey)
+			require.EqualError(t, err, test.expectedErr)
+			require.EqualError(t, signerStorage.SaveHighestProposal(test.pubKey, 1), test.expectedErr)
+			_, _, err = signerStorage.RetrieveHighestProposal(test.pubKey)
+			require.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestRemoveValidator(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	acc := accounts[0]
+	pk := acc.ValidatorPublicKey()
+
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}))
+	require.NoError(t, signerStorage.SaveHighestProposal(pk, 10))
+	require.NoError(t, signerStorage.SaveHighestRandaoEpoch(pk, 3, []byte{1}))
+	require.NoError(t, signerStorage.SetAccountEnabled(acc.ID(), false))
+
+	// A second validator is left intact.
+	other := _byteArray(pk2Str)
+	require.NoError(t, signerStorage.SaveHighestProposal(other, 10))
+
+	require.NoError(t, signerStorage.RemoveValidator(acc.ID(), pk))
+
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.EqualError(t, err, "account not found")
+	_, found, err := signerStorage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = signerStorage.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, _, found, err = signerStorage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, found, err = signerStorage.RetrieveHighestProposal(other)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// The account is removed from the wallet's index in the same transaction.
+	report, err := signerStorage.ReconcileAccounts(false)
+	require.NoError(t, err)
+	require.Empty(t, report.Dangling)
+}
+
+func TestHighestValuesEmpty(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	s := signerStorage.(*storage)
+	pk := _byteArray(pk1Str)
+	require.NoError(t, s.db.Set(s.objPrefix(highestAttPrefix), pk, []byte{}))
+	require.NoError(t, s.db.Set(s.objPrefix(highestProposalPrefix), pk, []byte{}))
+
+	att, found, err := signerStorage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Nil(t, att)
+
+	slot, found, err := signerStorage.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Zero(t, slot)
+
+	// An empty value can be re-seeded.
+	seed := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, seed))
+	require.NoError(t, signerStorage.SaveHighestProposal(pk, 10))
+
+	att, found, err = signerStorage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, seed, att)
+
+	slot, found, err = signerStorage.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(10), slot)
+}
+
+func TestStorageSize(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	accounts, highestData, wallet, err := signerStorage.StorageSize()
+	require.NoError(t, err)
+	require.Positive(t, accounts)
+	require.Zero(t, highestData)
+	require.Positive(t, wallet)
+
+	pk := _byteArray(pk1Str)
+	require.NoError(t, signerStorage.SaveHighestProposal(pk, 10))
+	require.NoError(t, signerStorage.SaveHighestRandaoEpoch(pk, 3, []byte{1, 2}))
+
+	_, highestData, _, err = signerStorage.StorageSize()
+	require.NoError(t, err)
+	require.Equal(t, int64(1+8+1+8+2), highestData)
+}
+
+func TestSaveHighest(t *testing.T) {
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+
+	for _, batching := range []bool{false, true} {
+		batching := batching
+		t.Run(fmt.Sprintf("batching=%t", batching), func(t *testing.T) {
+			logger := logging.TestLogger(t)
+			db, err := getBaseStorage(logger)
+			require.NoError(t, err)
+			defer db.Close()
+
+			var opts []StorageOption
+			if batching {
+				opts = append(opts, WithHighestWriteBatching(time.Millisecond))
+			}
+			signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, opts...)
+
+			pk := _byteArray(pk1Str)
+			requireHighest := func(att *phase0.AttestationData, slot phase0.Slot) {
+				storedAtt, found, err := signerStorage.RetrieveHighestAttestation(pk)
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, att, storedAtt)
+				storedSlot, found, err := signerStorage.RetrieveHighestProposal(pk)
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, slot, storedSlot)
+			}
+
+			require.NoError(t, signerStorage.SaveHighest(pk, attestation(5, 10), 20))
+			requireHighest(attestation(5, 10), 20)
+
+			// Identical values are accepted.
+			require.NoError(t, signerStorage.SaveHighest(pk, attestation(5, 10), 20))
+			requireHighest(attestation(5, 10), 20)
+
+			// If either guard fails, neither value is saved.
+			require.ErrorIs(t, signerStorage.SaveHighest(pk, attestation(5, 9), 30), ErrAttestationTargetRegression)
+			requireHighest(attestation(5, 10), 20)
+			require.ErrorIs(t, signerStorage.SaveHighest(pk, attestation(6, 11), 19), ErrProposalSlotRegression)
+			requireHighest(attestation(5, 10), 20)
+
+			require.NoError(t, signerStorage.SaveHighest(pk, attestation(6, 11), 21))
+			requireHighest(attestation(6, 11), 21)
+		})
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	logger := zap.New(core)
+
+	db, err := getBaseStorage(logging.TestLogger(t))
+	require.NoError(t, err)
+	defer db.Close()
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger)
+	signerStorage.(*storage).logger.Debug("debug")
+	require.Equal(t, 1, logs.FilterMessage("debug").Len())
+
+	signerStorage = newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithLogLevel(zap.WarnLevel))
+	signerStorage.(*storage).logger.Debug("filtered")
+	signerStorage.(*storage).logger.Warn("warn")
+	require.Zero(t, logs.FilterMessage("filtered").Len())
+	require.Equal(t, 1, logs.FilterMessage("warn").Len())
+}
+
+func TestLockedMemory(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, WithLockedMemory())
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	opened, err := signerStorage.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	require.Equal(t, acc.ValidatorPublicKey(), opened.ValidatorPublicKey())
+
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, acc.ValidatorPublicKey(), accounts[0].ValidatorPublicKey())
+}
+
+func TestLockedBuffer(t *testing.T) {
+	buf, err := newLockedBuffer(64)
+	require.NoError(t, err)
+	require.Len(t, buf.bytes(), 64)
+
+	copy(buf.bytes(), bytes.Repeat([]byte{1}, 64))
+	buf.release()
+	require.Nil(t, buf.bytes())
+}
+
+func TestReconcileValidators(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	local := accounts[0].ValidatorPublicKey()
+
+	report := ReconcileValidators([][]byte{local}, accounts)
+	require.Empty(t, report.MissingKeys)
+	require.Empty(t, report.Unregistered)
+
+	missing := _byteArray(pk1Str)
+	report = ReconcileValidators([][]byte{missing, local}, accounts)
+	require.Equal(t, [][]byte{missing}, report.MissingKeys)
+	require.Empty(t, report.Unregistered)
+
+	report = ReconcileValidators([][]byte{missing}, accounts)
+	require.Equal(t, [][]byte{missing}, report.MissingKeys)
+	require.Equal(t, [][]byte{local}, report.Unregistered)
+}
+
+func TestResetProtection(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	pk := _byteArray(pk1Str)
+	other := _byteArray(pk2Str)
+	for _, pubKey := range [][]byte{pk, other} {
+		require.NoError(t, signerStorage.SaveHighestAttestation(pubKey, &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: 1},
+			Target: &phase0.Checkpoint{Epoch: 2},
+		}))
+		require.NoError(t, signerStorage.SaveHighestProposal(pubKey, 10))
+		require.NoError(t, signerStorage.SaveHighestRandaoEpoch(pubKey, 3, []byte{1}))
+		require.NoError(t, signerStorage.SaveHighestSyncCommitteeSlot(pubKey, 10))
+	}
+
+	require.EqualError(t, signerStorage.ResetProtection(pk, ""), "resetting slashing protection must be confirmed")
+	_, found, err := signerStorage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.NoError(t, signerStorage.ResetProtection(pk, ConfirmResetProtection))
+
+	_, found, err = signerStorage.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = signerStorage.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, _, found, err = signerStorage.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = signerStorage.RetrieveHighestSyncCommitteeSlot(pk)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Other validators are left intact.
+	_, found, err = signerStorage.RetrieveHighestAttestation(other)
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestStorageConcurrency(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	const workers = 8
+	const iterations = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*4)
+	run := func(fn func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := fn(i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for w := 0; w < workers; w++ {
+		w := w
+		pk := make([]byte, 48)
+		pk[0] = byte(w)
+
+		// Save and delete accounts.
+		run(func(i int) error {
+			sk := &bls.SecretKey{}
+			sk.SetByCSPRNG()
+			key, err := core.NewHDKeyFromPrivateKey(sk.Serialize(), "")
+			if err != nil {
+				return err
+			}
+			acc := wallets.NewValidatorAccount("", key, nil, "", nil)
+			if err := signerStorage.SaveAccount(acc); err != nil {
+				return err
+			}
+			return signerStorage.DeleteAccount(acc.ID())
+		})
+
+		// List accounts.
+		run(func(i int) error {
+			_, err := signerStorage.ListAccounts()
+			return err
+		})
+
+		// Save highest attestations.
+		run(func(i int) error {
+			return signerStorage.SaveHighestAttestation(pk, &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: phase0.Epoch(i)},
+				Target: &phase0.Checkpoint{Epoch: phase0.Epoch(i + 1)},
+			})
+		})
+
+		// Retrieve highest attestations.
+		run(func(i int) error {
+			_, _, err := signerStorage.RetrieveHighestAttestation(pk)
+			return err
+		})
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	for w := 0; w < workers; w++ {
+		pk := make([]byte, 48)
+		pk[0] = byte(w)
+		att, found, err := signerStorage.RetrieveHighestAttestation(pk)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, phase0.Epoch(iterations), att.Target.Epoch)
+	}
+
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+}
+
+func TestAccountFormat(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	jsonStorage := newSignerStorage(t, db, network, logger)
+	gobStorage := newSignerStorage(t, db, network, logger, WithAccountFormat(AccountFormatGob))
+
+	wallet := hd.NewWallet(&core.WalletContext{Storage: jsonStorage})
+	require.NoError(t, jsonStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	rawAccount := func() []byte {
+		obj, found, err := db.Get(jsonStorage.(*storage).objPrefix(accountsPrefix), []byte(fmt.Sprintf(accountsPath, acc.ID().String())))
+		require.NoError(t, err)
+		require.True(t, found)
+		return obj.Value
+	}
+	requireAccount := func(s Storage) {
+		opened, err := s.OpenAccount(acc.ID())
+		require.NoError(t, err)
+		require.Equal(t, acc.ID(), opened.ID())
+		require.Equal(t, acc.Name(), opened.Name())
+		require.Equal(t, acc.BasePath(), opened.BasePath())
+		require.Equal(t, acc.ValidatorPublicKey(), opened.ValidatorPublicKey())
+
+		sig, err := opened.ValidationKeySign([]byte("data"))
+		require.NoError(t, err)
+		expected, err := acc.ValidationKeySign([]byte("data"))
+		require.NoError(t, err)
+		require.Equal(t, expected, sig)
+	}
+
+	// JSON accounts are readable by a storage saving in another format.
+	require.True(t, json.Valid(rawAccount()))
+	requireAccount(gobStorage)
+
+	require.NoError(t, gobStorage.SaveAccount(acc))
+	raw := rawAccount()
+	require.Equal(t, byte(AccountFormatGob), raw[0])
+	requireAccount(gobStorage)
+	requireAccount(jsonStorage)
+
+	accounts, err := jsonStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+
+	// Encrypted gob accounts are detected as encrypted.
+	encrypted := newSignerStorage(t, db, network, logger, WithAccountFormat(AccountFormatGob))
+	require.NoError(t, encrypted.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, encrypted.SaveAccount(acc))
+	requireAccount(encrypted)
+	_, err = jsonStorage.OpenAccount(acc.ID())
+	require.ErrorIs(t, err, ErrEncryptionKeyRequired)
+}
+
+func BenchmarkAccountFormat(b *testing.B) {
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	key, err := core.NewHDKeyFromPrivateKey(sk.Serialize(), "")
+	require.NoError(b, err)
+	acc := wallets.NewValidatorAccount("", key, sk.GetPublicKey().Serialize(), "", nil)
+
+	for _, format := range []struct {
+		name   string
+		format AccountFormat
+	}{
+		{"JSON", AccountFormatJSON},
+		{"Gob", AccountFormatGob},
+	} {
+		s := &storage{accountFormat: format.format}
+		data, err := s.encodeAccount(acc)
+		require.NoError(b, err)
+
+		b.Run(format.name+"/Encode", func(b *testing.B) {
+			b.ReportMetric(float64(len(data)), "bytes/account")
+			for i := 0; i < b.N; i++ {
+				if _, err := s.encodeAccount(acc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(format.name+"/Decode", func(b *testing.B) {
+			b.ReportMetric(float64(len(data)), "bytes/account")
+			for i := 0; i < b.N; i++ {
+				if _, err := s.decodeAccount(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelListAccounts(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const key = "0123456789abcdef0123456789abcdef"
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	serial := newSignerStorage(t, db, network, logger)
+	require.NoError(t, serial.SetEncryptionKey(key))
+	parallel := newSignerStorage(t, db, network, logger, WithParallelListAccounts(4), WithMaxConcurrentDecryptions(2))
+	require.NoError(t, parallel.SetEncryptionKey(key))
+
+	accounts, err := parallel.ListAccounts()
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+
+	saveTestAccounts(t, serial, 20)
+
+	expected, err := serial.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, expected, 20)
+	accounts, err = parallel.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].ID(), accounts[i].ID())
+		require.Equal(t, expected[i].ValidatorPublicKey(), accounts[i].ValidatorPublicKey())
+	}
+
+	wrongKey := newSignerStorage(t, db, network, logger, WithParallelListAccounts(4))
+	require.NoError(t, wrongKey.SetEncryptionKey("fedcba9876543210fedcba9876543210"))
+	_, err = wrongKey.ListAccounts()
+	require.ErrorContains(t, err, "failed to decrypt accounts")
+}
+
+// saveTestAccounts saves n random accounts to the given storage.
+func saveTestAccounts(tb testing.TB, s Storage, n int) {
+	threshold.Init()
+	for i := 0; i < n; i++ {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		key, err := core.NewHDKeyFromPrivateKey(sk.Serialize(), "")
+		require.NoError(tb, err)
+		require.NoError(tb, s.SaveAccount(wallets.NewValidatorAccount("", key, sk.GetPublicKey().Serialize(), "", nil)))
+	}
+}
+
+func BenchmarkListAccounts(b *testing.B) {
+	logger := zap.NewNop()
+	db, err := getBaseStorage(logger)
+	require.NoError(b, err)
+	defer db.Close()
+
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	newStorage := func(opts ...StorageOption) Storage {
+		s, err := NewSignerStorage(db, network, logger, opts...)
+		require.NoError(b, err)
+		require.NoError(b, s.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+		return s
+	}
+	saveTestAccounts(b, newStorage(), 10_000)
+
+	for _, bench := range []struct {
+		name    string
+		storage Storage
+	}{
+		{"Serial", newStorage()},
+		{"Parallel", newStorage(WithParallelListAccounts(runtime.NumCPU()))},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bench.storage.ListAccounts(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenWalletNetworkMismatch(t *testing.T) {
+	_, signerStorage, done := testWallet(t)
+	defer done()
+
+	s := signerStorage.(*storage)
+	_, err := s.OpenWallet()
+	require.NoError(t, err)
+
+	require.NoError(t, s.db.Set(s.objPrefix(walletPrefix), []byte(walletNetworkPath), []byte("mainnet")))
+	_, err = s.OpenWallet()
+	require.ErrorIs(t, err, ErrNetworkMismatch)
+
+	// Wallets saved without a network are opened.
+	require.NoError(t, s.db.Delete(s.objPrefix(walletPrefix), []byte(walletNetworkPath)))
+	_, err = s.OpenWallet()
+	require.NoError(t, err)
+}
+
+func TestSaveWalletNetworkMismatch(t *testing.T) {
+	wallet, signerStorage, done := testWallet(t)
+	defer done()
+
+	s := signerStorage.(*storage)
+	require.NoError(t, s.SaveWallet(wallet))
+
+	require.NoError(t, s.db.Set(s.objPrefix(walletPrefix), []byte(walletNetworkPath), []byte("mainnet")))
+	require.ErrorIs(t, s.SaveWallet(wallet), ErrNetworkMismatch)
+	network, found, err := s.db.Get(s.objPrefix(walletPrefix), []byte(walletNetworkPath))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "mainnet", string(network.Value))
+
+	// Wallets saved without a network are overwritten.
+	require.NoError(t, s.db.Delete(s.objPrefix(walletPrefix), []byte(walletNetworkPath)))
+	require.NoError(t, s.SaveWallet(wallet))
+	_, err = s.OpenWallet()
+	require.NoError(t, err)
+}
+
+func TestHighestValueFormat(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+	s := signerStorage.(*storage)
+
+	pk := _byteArray(pk1Str)
+	att := &phase0.AttestationData{
+		Slot:   30,
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+	rawValue := func(prefix string) []byte {
+		obj, found, err := s.db.Get(s.objPrefix(prefix), pk)
+		require.NoError(t, err)
+		require.True(t, found)
+		return obj.Value
+	}
+
+	// Values are stored with a version prefix.
+	require.NoError(t, s.SaveHighestAttestation(pk, att))
+	require.NoError(t, s.SaveHighestProposal(pk, 10))
+	require.Equal(t, highestFormatV1, rawValue(highestAttPrefix)[0])
+	require.Len(t, rawValue(highestAttPrefix), 1+legacyHighestAttestationSize)
+	require.Equal(t, highestFormatV1, rawValue(highestProposalPrefix)[0])
+	require.Len(t, rawValue(highestProposalPrefix), 1+legacyHighestProposalSize)
+
+	// Values stored without a version prefix are read as version 1.
+	legacyAtt, err := att.MarshalSSZ()
+	require.NoError(t, err)
+	require.NoError(t, s.db.Set(s.objPrefix(highestAttPrefix), pk, legacyAtt))
+	require.NoError(t, s.db.Set(s.objPrefix(highestProposalPrefix), pk, ssz.MarshalUint64(nil, 20)))
+
+	highestAtt, found, err := s.RetrieveHighestAttestation(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, att, highestAtt)
+	highestSlot, found, err := s.RetrieveHighestProposal(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(20), highestSlot)
+
+	// Randao and sync committee values are versioned the same way.
+	signature := bytes.Repeat([]byte{7}, phase0.SignatureLength)
+	require.NoError(t, s.SaveHighestRandaoEpoch(pk, 3, signature))
+	require.NoError(t, s.SaveHighestSyncCommitteeSlot(pk, 40))
+	require.Equal(t, highestFormatV1, rawValue(highestRandaoPrefix)[0])
+	require.Len(t, rawValue(highestRandaoPrefix), 1+legacyHighestRandaoSize)
+	require.Equal(t, highestFormatV1, rawValue(highestSyncCommitteePrefix)[0])
+	require.Len(t, rawValue(highestSyncCommitteePrefix), 1+legacyHighestSyncCommitteeSize)
+
+	require.NoError(t, s.db.Set(s.objPrefix(highestRandaoPrefix), pk, append(ssz.MarshalUint64(nil, 4), signature...)))
+	require.NoError(t, s.db.Set(s.objPrefix(highestSyncCommitteePrefix), pk, ssz.MarshalUint64(nil, 50)))
+
+	epoch, storedSignature, found, err := s.RetrieveHighestRandaoEpoch(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(4), epoch)
+	require.Equal(t, signature, storedSignature)
+	syncSlot, found, err := s.RetrieveHighestSyncCommitteeSlot(pk)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(50), syncSlot)
+
+	// Unknown versions are refused.
+	require.NoError(t, s.db.Set(s.objPrefix(highestProposalPrefix), pk, append([]byte{2}, ssz.MarshalUint64(nil, 20)...)))
+	_, _, err = s.RetrieveHighestProposal(pk)
+	require.ErrorContains(t, err, "unsupported highest value format version 2")
+	require.NoError(t, s.db.Set(s.objPrefix(highestSyncCommitteePrefix), pk, append([]byte{2}, ssz.MarshalUint64(nil, 20)...)))
+	_, _, err = s.RetrieveHighestSyncCommitteeSlot(pk)
+	require.ErrorContains(t, err, "unsupported highest value format version 2")
+}
+
+// flakyDB fails the first failures calls to Get, GetAll and Update with a transient error.
+type flakyDB struct {
+	basedb.Database
+	failures int
+	calls    int
+}
+
+func (f *flakyDB) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return badger.ErrConflict
+	}
+	return nil
+}
+
+func (f *flakyDB) Get(prefix []byte, key []byte) (basedb.Obj, bool, error) {
+	if err := f.fail(); err != nil {
+		return basedb.Obj{}, false, err
+	}
+	return f.Database.Get(prefix, key)
+}
+
+func (f *flakyDB) GetAll(prefix []byte, handler func(int, basedb.Obj) error) error {
+	return f.Database.GetAll(prefix, func(i int, obj basedb.Obj) error {
+		if err := handler(i, obj); err != nil {
+			return err
+		}
+		return f.fail()
+	})
+}
+
+func (f *flakyDB) Update(fn func(basedb.Txn) error) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Database.Update(fn)
+}
+
+func TestDBRetry(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	flaky := &flakyDB{Database: db}
+	retrying := newRetryDB(flaky, 3, time.Millisecond)
+	prefix := []byte("test")
+	require.NoError(t, retrying.Set(prefix, []byte("a"), []byte{1}))
+	require.NoError(t, retrying.Set(prefix, []byte("b"), []byte{2}))
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		flaky.calls, flaky.failures = 0, 2
+		obj, found, err := retrying.Get(prefix, []byte("a"))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, []byte{1}, obj.Value)
+		require.Equal(t, 3, flaky.calls)
+	})
+
+	t.Run("attempts are bounded", func(t *testing.T) {
+		flaky.calls, flaky.failures = 0, 3
+		_, _, err := retrying.Get(prefix, []byte("a"))
+		require.ErrorIs(t, err, badger.ErrConflict)
+		require.Equal(t, 3, flaky.calls)
+	})
+
+	t.Run("iteration is not retried once started", func(t *testing.T) {
+		flaky.calls, flaky.failures = 0, 1
+		handled := 0
+		err := retrying.GetAll(prefix, func(i int, obj basedb.Obj) error {
+			handled++
+			return nil
+		})
+		require.ErrorIs(t, err, badger.ErrConflict)
+		require.Equal(t, 1, handled)
+	})
+
+	t.Run("transactions are not retried", func(t *testing.T) {
+		flaky.calls, flaky.failures = 0, 1
+		err := retrying.Update(func(txn basedb.Txn) error {
+			return txn.Set(prefix, []byte("a"), []byte{3})
+		})
+		require.ErrorIs(t, err, badger.ErrConflict)
+		require.Equal(t, 1, flaky.calls)
+	})
+}
+
+func TestReconcileProtection(t *testing.T) {
+	wallet, signerStorage, done := testWallet(t)
+	defer done()
+
+	accounts := wallet.Accounts()
+	require.Len(t, accounts, 1)
+	accountPK := accounts[0].ValidatorPublicKey()
+	orphanPK := _byteArray(pk1Str)
+
+	for _, pk := range [][]byte{accountPK, orphanPK} {
+		require.NoError(t, signerStorage.SaveHighestProposal(pk, 10))
+		require.NoError(t, signerStorage.SaveHighestRandaoEpoch(pk, 1, []byte{1}))
+	}
+	require.NoError(t, signerStorage.SaveHighestAttestation(orphanPK, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}))
+
+	orphans, err := signerStorage.ReconcileProtection(false)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{orphanPK}, orphans)
+	_, found, err := signerStorage.RetrieveHighestProposal(orphanPK)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	orphans, err = signerStorage.ReconcileProtection(true)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{orphanPK}, orphans)
+	pubKeys, err := signerStorage.ListProtectedPubKeys()
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{accountPK}, pubKeys)
+	_, _, found, err = signerStorage.RetrieveHighestRandaoEpoch(orphanPK)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	orphans, err = signerStorage.ReconcileProtection(true)
+	require.NoError(t, err)
+	require.Empty(t, orphans)
+}
+
+func TestReplaceAllAccounts(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+	require.NoError(t, signerStorage.SetEncryptionKey("0123456789abcdef0123456789abcdef"))
+
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	newAccount := func(index int) core.ValidatorAccount {
+		sk := &bls.SecretKey{}
+		sk.SetByCSPRNG()
+		acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+		require.NoError(t, err)
+		return acc
+	}
+	kept := newAccount(0)
+	removed := newAccount(1)
+	require.NoError(t, signerStorage.SetAccountEnabled(removed.ID(), false))
+	require.NoError(t, signerStorage.SaveHighestProposal(removed.ValidatorPublicKey(), 10))
+
+	// An account which isn't stored yet.
+	otherStorage, otherDone := newStorageForTest(t)
+	defer otherDone()
+	otherWallet := hd.NewWallet(&core.WalletContext{Storage: otherStorage})
+	require.NoError(t, otherStorage.SaveWallet(otherWallet))
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 2
+	added, err := otherWallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	require.NoError(t, signerStorage.ReplaceAllAccounts([]core.ValidatorAccount{kept, added}))
+
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	var ids []uuid.UUID
+	for _, acc := range accounts {
+		ids = append(ids, acc.ID())
+	}
+	require.ElementsMatch(t, []uuid.UUID{kept.ID(), added.ID()}, ids)
+
+	_, err = signerStorage.OpenAccount(removed.ID())
+	require.EqualError(t, err, "account not found")
+	s := signerStorage.(*storage)
+	_, found, err := s.db.Get(s.objPrefix(disabledAccountsPrefix), []byte(fmt.Sprintf(accountsPath, removed.ID().String())))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Slashing protection of replaced accounts is kept.
+	slot, found, err := signerStorage.RetrieveHighestProposal(removed.ValidatorPublicKey())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(10), slot)
+
+	require.NoError(t, signerStorage.ReplaceAllAccounts(nil))
+	accounts, err = signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+}
+
+func TestReadReplica(t *testing.T) {
+	logger := logging.TestLogger(t)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	primary, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer primary.Close()
+	replica, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer replica.Close()
+
+	signerStorage := newSignerStorage(t, primary, network, logger, WithReadReplica(replica))
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+	require.NoError(t, signerStorage.SaveHighestProposal(acc.ValidatorPublicKey(), 10))
+
+	// Queries are served by the replica, which hasn't caught up yet.
+	accounts, err := signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+	modified, err := signerStorage.(AccountTimeStorage).ListAccountsModifiedSince(time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, modified)
+
+	// Accounts and highest values used for signing are read from the primary.
+	_, err = signerStorage.OpenAccount(acc.ID())
+	require.NoError(t, err)
+	slot, found, err := signerStorage.RetrieveHighestProposal(acc.ValidatorPublicKey())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(10), slot)
+
+	// Once the replica catches up, queries reflect the writes.
+	err = primary.GetAll([]byte(network.GetBeaconNetwork()), func(i int, obj basedb.Obj) error {
+		return replica.Set([]byte(network.GetBeaconNetwork()), obj.Key, obj.Value)
+	})
+	require.NoError(t, err)
+	accounts, err = signerStorage.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, acc.ID(), accounts[0].ID())
+}
+
+func TestMalformedAccount(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+	s := signerStorage.(*storage)
+
+	id := uuid.New()
+	keyID := uuid.New()
+	account := func(privKey string) string {
+		return fmt.Sprintf(`{"id":%q,"name":"account","baseAccountPath":"/0","withdrawalPubKey":"",`+
+			`"validationKey":{"id":%q,"path":"/0/0","privKey":%q}}`, id, keyID, privKey)
+	}
+
+	tests := map[string]string{
+		"null":         `null`,
+		"zero key":     account("0"),
+		"invalid type": fmt.Sprintf(`{"id":5,"name":"account","validationKey":{"id":%q}}`, keyID),
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, s.db.Set(s.objPrefix(accountsPrefix), []byte(fmt.Sprintf(accountsPath, id)), []byte(value)))
+			_, err := signerStorage.OpenAccount(id)
+			require.ErrorIs(t, err, ErrMalformedAccount)
+		})
+	}
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	require.NoError(t, s.db.Set(s.objPrefix(accountsPrefix), []byte(fmt.Sprintf(accountsPath, id)), []byte(account(sk.SerializeToHexStr()))))
+	acc, err := signerStorage.OpenAccount(id)
+	require.NoError(t, err)
+	require.Equal(t, sk.GetPublicKey().Serialize(), acc.ValidatorPublicKey())
+}
+
+func TestWasAttestationSigned(t *testing.T) {
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+
+	pk := _byteArray(pk1Str)
+	att := &phase0.AttestationData{
+		Slot:            100,
+		Index:           2,
+		BeaconBlockRoot: [32]byte{1},
+		Source:          &phase0.Checkpoint{Epoch: 2, Root: [32]byte{2}},
+		Target:          &phase0.Checkpoint{Epoch: 3, Root: [32]byte{3}},
+	}
+
+	signed, err := signerStorage.WasAttestationSigned(pk, att)
+	require.NoError(t, err)
+	require.False(t, signed)
+
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, att))
+	signed, err = signerStorage.WasAttestationSigned(pk, att)
+	require.NoError(t, err)
+	require.True(t, signed)
+
+	// Same epochs but different data wasn't signed.
+	other := *att
+	other.BeaconBlockRoot = [32]byte{4}
+	signed, err = signerStorage.WasAttestationSigned(pk, &other)
+	require.NoError(t, err)
+	require.False(t, signed)
+
+	_, err = signerStorage.WasAttestationSigned(pk, nil)
+	require.Error(t, err)
+}
+
+func TestRetrieveHighestAttestationAsOf(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	pk := _byteArray(pk1Str)
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+
+	_, _, err = newSignerStorage(t, db, network, logger).RetrieveHighestAttestationAsOf(pk, 10)
+	require.EqualError(t, err, "highest attestation history is not enabled")
+
+	signerStorage := newSignerStorage(t, db, network, logger, WithHighestAttestationHistory(3))
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, attestation(1, 2)))
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, attestation(2, 4)))
+	require.NoError(t, signerStorage.SaveHighest(pk, attestation(3, 6), 10))
+	// Identical attestations aren't added to the history.
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk, attestation(3, 6)))
+
+	for _, tc := range []struct {
+		epoch  phase0.Epoch
+		target phase0.Epoch
+		found  bool
+	}{
+		{epoch: 1, found: false},
+		{epoch: 2, target: 2, found: true},
+		{epoch: 5, target: 4, found: true},
+		{epoch: 100, target: 6, found: true},
+	} {
+		att, found, err := signerStorage.RetrieveHighestAttestationAsOf(pk, tc.epoch)
+		require.NoError(t, err)
+		require.Equal(t, tc.found, found, "epoch %d", tc.epoch)
+		if tc.found {
+			require.Equal(t, tc.target, att.Target.Epoch, "epoch %d", tc.epoch)
+		}
+	}
+
+	// Raising the highest attestation adds to the history, which keeps the last 3 updates.
+	require.NoError(t, signerStorage.(*storage).db.Update(func(txn basedb.Txn) error {
+		return signerStorage.(*storage).raiseHighestAttestation(txn, pk, attestation(4, 8))
+	}))
+	_, found, err := signerStorage.RetrieveHighestAttestationAsOf(pk, 3)
+	require.NoError(t, err)
+	require.False(t, found)
+	att, found, err := signerStorage.RetrieveHighestAttestationAsOf(pk, 9)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(8), att.Target.Epoch)
+
+	require.NoError(t, signerStorage.RemoveHighestAttestation(pk))
+	_, found, err = signerStorage.RetrieveHighestAttestationAsOf(pk, 9)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRebuildHighestFromDecided(t *testing.T) {
+	logger := logging.TestLogger(t)
+	signerStorage, done := newStorageForTest(t)
+	defer done()
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+	instances := ibftstorage.New(db, "rebuild")
+
+	pk1 := _byteArray(pk1Str)
+	pk2 := _byteArray(pk2Str)
+	domain := networkconfig.TestNetwork.DomainType()
+	saveDecided := func(id spectypes.MessageID, height specqbft.Height, value []byte) {
+		require.NoError(t, instances.SaveHighestInstance(&qbftstorage.StoredInstance{
+			State: &specqbft.State{
+				ID:           id[:],
+				Height:       height,
+				Decided:      true,
+				DecidedValue: value,
+			},
+		}))
+	}
+
+	committeeID := spectypes.NewMsgID(domain, bytes.Repeat([]byte{1}, 32), spectypes.RoleCommittee)
+	require.ErrorContains(t, signerStorage.RebuildHighestFromDecided(instances, committeeID, [][]byte{pk1}), "no decided instance")
+
+	vote, err := (&spectypes.BeaconVote{
+		BlockRoot: phase0.Root{1},
+		Source:    &phase0.Checkpoint{Epoch: 9},
+		Target:    &phase0.Checkpoint{Epoch: 10},
+	}).Encode()
+	require.NoError(t, err)
+	saveDecided(committeeID, 320, vote)
+
+	// pk2's target is already higher, and must not be lowered.
+	require.NoError(t, signerStorage.SaveHighestAttestation(pk2, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 8},
+		Target: &phase0.Checkpoint{Epoch: 11},
+	}))
+	require.NoError(t, signerStorage.RebuildHighestFromDecided(instances, committeeID, [][]byte{pk1, pk2}))
+
+	att, found, err := signerStorage.RetrieveHighestAttestation(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Epoch(9), att.Source.Epoch)
+	require.Equal(t, phase0.Epoch(10), att.Target.Epoch)
+	att, _, err = signerStorage.RetrieveHighestAttestation(pk2)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(9), att.Source.Epoch)
+	require.Equal(t, phase0.Epoch(11), att.Target.Epoch)
+
+	proposerID := spectypes.NewMsgID(domain, pk1, spectypes.RoleProposer)
+	data, err := (&spectypes.ValidatorConsensusData{
+		Duty:    spectypes.ValidatorDuty{Type: spectypes.BNRoleProposer, Slot: 500},
+		Version: spec.DataVersionDeneb,
+	}).Encode()
+	require.NoError(t, err)
+	saveDecided(proposerID, 500, data)
+	require.NoError(t, signerStorage.SaveHighestProposal(pk1, 400))
+	require.NoError(t, signerStorage.RebuildHighestFromDecided(instances, proposerID, nil))
+
+	slot, found, err := signerStorage.RetrieveHighestProposal(pk1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, phase0.Slot(500), slot)
+
+	exitID := spectypes.NewMsgID(domain, pk1, spectypes.RoleVoluntaryExit)
+	saveDecided(exitID, 1, []byte{1})
+	require.ErrorContains(t, signerStorage.RebuildHighestFromDecided(instances, exitID, nil), "no slashing protection data")
+}
+
+func TestVerifyPassphrase(t *testing.T) {
+	const (
+		key   = "0123456789abcdef0123456789abcdef"
+		wrong = "fedcba9876543210fedcba9876543210"
+	)
+	tests := map[string][]StorageOption{
+		"direct":   nil,
+		"salted":   {WithSaltedKeyDerivation()},
+		"envelope": {WithEnvelopeEncryption(), WithSaltedKeyDerivation()},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			logger := logging.TestLogger(t)
+			db, err := getBaseStorage(logger)
+			require.NoError(t, err)
+			defer db.Close()
+			signerStorage := newSignerStorage(t, db, networkconfig.TestNetwork.Beacon.GetNetwork(), logger, opts...)
+
+			_, err = signerStorage.VerifyPassphrase(key)
+			require.ErrorIs(t, err, ErrNothingToVerify)
+
+			require.NoError(t, signerStorage.SetEncryptionKey(key))
+			wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+			require.NoError(t, signerStorage.SaveWallet(wallet))
+			threshold.Init()
+			sk := &bls.SecretKey{}
+			sk.SetByCSPRNG()
+			index := 0
+			acc, err := wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+			require.NoError(t, err)
+
+			ok, err := signerStorage.VerifyPassphrase(key)
+			require.NoError(t, err)
+			require.True(t, ok)
+			ok, err = signerStorage.VerifyPassphrase(wrong)
+			require.NoError(t, err)
+			require.False(t, ok)
+			_, err = signerStorage.VerifyPassphrase("not hex")
+			require.Error(t, err)
+
+			// The storage's key is not changed by verification.
+			_, err = signerStorage.OpenAccount(acc.ID())
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEncryptionKeyCanary(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := getBaseStorage(logger)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const (
+		key1 = "0123456789abcdef0123456789abcdef"
+		key2 = "fedcba9876543210fedcba9876543210"
+	)
+	network := networkconfig.TestNetwork.Beacon.GetNetwork()
+	withKey := func(key string, opts ...StorageOption) Storage {
+		s := newSignerStorage(t, db, network, logger, opts...)
+		require.NoError(t, s.SetEncryptionKey(key))
+		return s
+	}
+
+	signerStorage := withKey(key1)
+	require.NoError(t, signerStorage.CheckEncryptionKey())
+	wallet := hd.NewWallet(&core.WalletContext{Storage: signerStorage})
+	require.NoError(t, signerStorage.SaveWallet(wallet))
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	index := 0
+	_, err = wallet.CreateValidatorAccountFromPrivateKey(sk.Serialize(), &index)
+	require.NoError(t, err)
+
+	// A wrong key doesn't overwrite the canary.
+	require.ErrorIs(t, withKey(key2).CheckEncryptionKey(), ErrWrongEncryptionKey)
+	require.NoError(t, withKey(key1).CheckEncryptionKey())
+
+	// Stores without a canary are checked against their accounts, and only get a canary with the right key.
+	s := signerStorage.(*storage)
+	require.NoError(t, s.db.Delete(s.objPrefix(canaryPrefix), []byte(canaryPath)))
+	require.ErrorIs(t, withKey(key2).CheckEncryptionKey(), ErrWrongEncryptionKey)
+	_, found, err := s.db.Get(s.objPrefix(canaryPrefix), []byte(canaryPath))
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, withKey(key1).CheckEncryptionKey())
+	_, found, err = s.db.Get(s.objPrefix(canaryPrefix), []byte(canaryPath))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// A canary decrypted by a secondary key is re-encrypted with the new key.
+	require.NoError(t, withKey(key2, WithSecondaryDecryptionKeys(key1)).CheckEncryptionKey())
+	require.NoError(t, withKey(key2).CheckEncryptionKey())
+	require.ErrorIs(t, withKey(key1).CheckEncryptionKey(), ErrWrongEncryptionKey)
+}
@@ -0,0 +1,129 @@
+package ekm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/google/uuid"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+)
+
+// SoftwareKeyType is the key type of the software reference SigningBackend.
+const SoftwareKeyType = "software"
+
+// SigningBackend performs BLS signatures with key material which is held outside the signer storage,
+// such as an HSM or a cloud KMS. Slashing protection is enforced by the signer before delegating to it.
+type SigningBackend interface {
+	// Sign signs the given signing root with the key of the given public key.
+	Sign(pubKey []byte, root []byte) ([]byte, error)
+}
+
+var (
+	signingBackends     = make(map[string]SigningBackend)
+	signingBackendsLock sync.RWMutex
+)
+
+// RegisterSigningBackend registers the SigningBackend which signs for accounts of the given key type.
+func RegisterSigningBackend(keyType string, backend SigningBackend) {
+	signingBackendsLock.Lock()
+	defer signingBackendsLock.Unlock()
+
+	signingBackends[keyType] = backend
+}
+
+func signingBackend(keyType string) (SigningBackend, error) {
+	signingBackendsLock.RLock()
+	defer signingBackendsLock.RUnlock()
+
+	backend, ok := signingBackends[keyType]
+	if !ok {
+		return nil, errors.Errorf("no signing backend registered for key type %q", keyType)
+	}
+	return backend, nil
+}
+
+// delegatedAccount is a core.ValidatorAccount which holds only metadata,
+// and delegates signing to the SigningBackend registered for its key type.
+type delegatedAccount struct {
+	AccountID uuid.UUID `json:"id"`
+	PubKey    string    `json:"validatorPubKey"`
+	KeyType   string    `json:"keyType"`
+}
+
+func newDelegatedAccount(pubKey []byte, keyType string) *delegatedAccount {
+	return &delegatedAccount{
+		AccountID: uuid.New(),
+		PubKey:    hex.EncodeToString(pubKey),
+		KeyType:   keyType,
+	}
+}
+
+// isDelegatedAccount returns true if the given encoded account is a delegated account.
+func isDelegatedAccount(byts []byte) bool {
+	var header struct {
+		KeyType string `json:"keyType"`
+	}
+	return json.Unmarshal(byts, &header) == nil && header.KeyType != ""
+}
+
+func (a *delegatedAccount) ID() uuid.UUID    { return a.AccountID }
+func (a *delegatedAccount) Name() string     { return "" }
+func (a *delegatedAccount) BasePath() string { return "" }
+
+func (a *delegatedAccount) ValidatorPublicKey() []byte {
+	pubKey, _ := hex.DecodeString(a.PubKey)
+	return pubKey
+}
+
+func (a *delegatedAccount) WithdrawalPublicKey() []byte {
+	return nil
+}
+
+func (a *delegatedAccount) ValidationKeySign(data []byte) ([]byte, error) {
+	backend, err := signingBackend(a.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Sign(a.ValidatorPublicKey(), data)
+}
+
+func (a *delegatedAccount) GetDepositData() (map[string]interface{}, error) {
+	return nil, errors.New("deposit data is not supported for delegated accounts")
+}
+
+func (a *delegatedAccount) SetContext(ctx *core.WalletContext) {}
+
+// SoftwareSigningBackend is a reference SigningBackend which keeps the keys in memory.
+type SoftwareSigningBackend struct {
+	keys map[string]*bls.SecretKey
+	lock sync.RWMutex
+}
+
+// NewSoftwareSigningBackend returns a new instance of SoftwareSigningBackend
+func NewSoftwareSigningBackend() *SoftwareSigningBackend {
+	return &SoftwareSigningBackend{
+		keys: make(map[string]*bls.SecretKey),
+	}
+}
+
+// AddKey adds the given key to the backend.
+func (b *SoftwareSigningBackend) AddKey(sk *bls.SecretKey) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.keys[sk.GetPublicKey().SerializeToHexStr()] = sk
+}
+
+func (b *SoftwareSigningBackend) Sign(pubKey []byte, root []byte) ([]byte, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	sk, ok := b.keys[hex.EncodeToString(pubKey)]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return sk.SignByte(root).Serialize(), nil
+}
@@ -0,0 +1,160 @@
+package ekm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// protectionSnapshot holds the highest attestation and proposal values of all public keys,
+// keyed by hex-encoded public key.
+type protectionSnapshot struct {
+	Network      string                             `json:"network"`
+	Attestations map[string]*phase0.AttestationData `json:"attestations"`
+	Proposals    map[string]phase0.Slot             `json:"proposals"`
+}
+
+// Snapshot writes the highest attestation and proposal values of all public keys to the given writer.
+// Unlike a full backup, it holds only slashing protection data, and is meant to be taken right
+// before a planned restart and restored with RestoreSnapshot if the database is lost.
+func (s *storage) Snapshot(w io.Writer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return errors.Wrap(err, "could not flush highest values")
+	}
+
+	snapshot := protectionSnapshot{
+		Network:      string(s.network.GetBeaconNetwork()),
+		Attestations: make(map[string]*phase0.AttestationData),
+		Proposals:    make(map[string]phase0.Slot),
+	}
+	err := s.db.GetAll(s.objPrefix(highestAttPrefix), func(i int, obj basedb.Obj) error {
+		att, err := decodeHighestAttestation(obj.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal highest attestation of %x", obj.Key)
+		}
+		snapshot.Attestations[hex.EncodeToString(obj.Key)] = att
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not read highest attestations")
+	}
+	err = s.db.GetAll(s.objPrefix(highestProposalPrefix), func(i int, obj basedb.Obj) error {
+		slot, err := decodeHighestProposal(obj.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal highest proposal of %x", obj.Key)
+		}
+		snapshot.Proposals[hex.EncodeToString(obj.Key)] = slot
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not read highest proposals")
+	}
+
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// RestoreSnapshot restores the highest values written by Snapshot. Values are only ever raised:
+// a value which is lower than the stored one is ignored.
+func (s *storage) RestoreSnapshot(r io.Reader) error {
+	var snapshot protectionSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return errors.Wrap(err, "could not decode snapshot")
+	}
+	if snapshot.Network != string(s.network.GetBeaconNetwork()) {
+		return errors.Errorf("snapshot network %q does not match storage network %q",
+			snapshot.Network, s.network.GetBeaconNetwork())
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.flush(); err != nil {
+		return errors.Wrap(err, "could not flush highest values")
+	}
+
+	return s.db.Update(func(txn basedb.Txn) error {
+		for pubKeyHex, att := range snapshot.Attestations {
+			pubKey, err := hex.DecodeString(pubKeyHex)
+			if err != nil {
+				return errors.Wrapf(err, "invalid public key %q", pubKeyHex)
+			}
+			if err := s.raiseHighestAttestation(txn, pubKey, att); err != nil {
+				return err
+			}
+		}
+		for pubKeyHex, slot := range snapshot.Proposals {
+			pubKey, err := hex.DecodeString(pubKeyHex)
+			if err != nil {
+				return errors.Wrapf(err, "invalid public key %q", pubKeyHex)
+			}
+			if err := s.raiseHighestProposal(txn, pubKey, slot); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// raiseHighestAttestation raises the stored source and target epochs to the given attestation's, if higher.
+func (s *storage) raiseHighestAttestation(txn basedb.Txn, pubKey []byte, att *phase0.AttestationData) error {
+	if att == nil || att.Source == nil || att.Target == nil {
+		return errors.Errorf("invalid attestation of %x", pubKey)
+	}
+
+	obj, found, err := txn.Get(s.objPrefix(highestAttPrefix), pubKey)
+	if err != nil {
+		return errors.Wrapf(err, "could not get highest attestation of %x", pubKey)
+	}
+	if found {
+		stored, err := decodeHighestAttestation(obj.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal highest attestation of %x", pubKey)
+		}
+		if att.Source.Epoch <= stored.Source.Epoch && att.Target.Epoch <= stored.Target.Epoch {
+			return nil
+		}
+		merged := stored
+		if att.Source.Epoch > stored.Source.Epoch {
+			merged.Source = att.Source
+		}
+		if att.Target.Epoch > stored.Target.Epoch {
+			merged.Target = att.Target
+		}
+		att = merged
+	}
+
+	data, err := encodeHighestAttestation(att)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal attestation")
+	}
+	if err := txn.Set(s.objPrefix(highestAttPrefix), pubKey, data); err != nil {
+		return err
+	}
+	return s.appendAttestationHistoryTxn(txn, pubKey, att)
+}
+
+// raiseHighestProposal raises the stored proposal slot to the given slot, if higher.
+func (s *storage) raiseHighestProposal(txn basedb.Txn, pubKey []byte, slot phase0.Slot) error {
+	obj, found, err := txn.Get(s.objPrefix(highestProposalPrefix), pubKey)
+	if err != nil {
+		return errors.Wrapf(err, "could not get highest proposal of %x", pubKey)
+	}
+	if found {
+		stored, err := decodeHighestProposal(obj.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal highest proposal of %x", pubKey)
+		}
+		if slot <= stored {
+			return nil
+		}
+	}
+	return txn.Set(s.objPrefix(highestProposalPrefix), pubKey, encodeHighestProposal(slot))
+}
@@ -0,0 +1,149 @@
+package ekm
+
+import (
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+// StorageOption defines signer storage configuration option.
+type StorageOption func(*storage)
+
+// WithNonceReuseCheck enables tracking of the most recent GCM nonces generated per encryption key,
+// so that a duplicate nonce for the same encryption key is detected instead of silently used.
+func WithNonceReuseCheck() StorageOption {
+	return func(s *storage) {
+		s.nonces = newNonceTracker()
+	}
+}
+
+// WithHighestWriteBatching coalesces highest attestation and proposal writes made within the given window
+// and commits them together. Saves still block until their write is committed. A non-positive window disables batching.
+func WithHighestWriteBatching(window time.Duration) StorageOption {
+	return func(s *storage) {
+		s.batchWindow = window
+	}
+}
+
+// WithEnvelopeEncryption encrypts accounts with a random data key, which is itself encrypted (wrapped)
+// with the key given to SetEncryptionKey. This allows RotateEncryptionKey to only re-wrap the data key.
+func WithEnvelopeEncryption() StorageOption {
+	return func(s *storage) {
+		s.envelope = true
+	}
+}
+
+// WithSaltedKeyDerivation mixes a random per-store salt into the key given to SetEncryptionKey.
+// Stores created without a salt keep using the key as is.
+func WithSaltedKeyDerivation() StorageOption {
+	return func(s *storage) {
+		s.saltedKeys = true
+	}
+}
+
+// WithMaxConcurrentDecryptions bounds the number of accounts decrypted concurrently,
+// to keep CPU usage predictable when many accounts are opened at once. Zero means unbounded.
+func WithMaxConcurrentDecryptions(n int) StorageOption {
+	return func(s *storage) {
+		if n > 0 {
+			s.decryptSem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithParallelListAccounts decrypts and decodes accounts across the given number of goroutines when listing
+// them, after reading them all, which speeds up listing large stores at the cost of holding all of their
+// encrypted values at once. Concurrent decryptions are still bounded by WithMaxConcurrentDecryptions.
+// Accounts are listed serially by default.
+func WithParallelListAccounts(workers int) StorageOption {
+	return func(s *storage) {
+		s.listWorkers = workers
+	}
+}
+
+// WithLockedMemory decrypts accounts into memory which is locked into RAM and zeroed once the account is decoded,
+// so the decrypted plaintext is never swapped to disk. Memory is only locked on Linux, where it requires
+// CAP_IPC_LOCK or a sufficient RLIMIT_MEMLOCK; elsewhere the plaintext is only zeroed.
+// The decoded account itself is held in ordinary memory.
+func WithLockedMemory() StorageOption {
+	return func(s *storage) {
+		s.lockedMemory = true
+	}
+}
+
+// WithLogLevel filters the storage's logs below the given level, so that the signer storage can be quieted
+// independently of the rest of the node. It can only make the storage less verbose than the given logger.
+// By default, the storage inherits the given logger's level.
+func WithLogLevel(level zapcore.Level) StorageOption {
+	return func(s *storage) {
+		s.logger = s.logger.WithOptions(zap.IncreaseLevel(level))
+	}
+}
+
+// WithHardenedMode suppresses all logs about encryption state, such as nonce retries and secondary key use,
+// and strips the cause from decryption errors so that they are always a bare ErrCantDecrypt.
+// It is meant for deployments where logs and errors may be shipped to less trusted systems.
+func WithHardenedMode() StorageOption {
+	return func(s *storage) {
+		s.hardened = true
+	}
+}
+
+// WithDefaultFeeRecipient sets the fee recipient returned by GetFeeRecipient for validators without one.
+func WithDefaultFeeRecipient(address bellatrix.ExecutionAddress) StorageOption {
+	return func(s *storage) {
+		s.defaultFeeRecipient = &address
+	}
+}
+
+// WithAccountFormat sets the serialization format of saved accounts. Accounts in any format can be read
+// regardless of this option, so it can be changed without migrating existing accounts. Defaults to JSON.
+func WithAccountFormat(format AccountFormat) StorageOption {
+	return func(s *storage) {
+		s.accountFormat = format
+	}
+}
+
+// WithSecondaryDecryptionKeys accepts the given hex encoded keys, such as the previous encryption key, for decrypting
+// accounts which fail to decrypt with the key given to SetEncryptionKey. Accounts are always encrypted with
+// that key, so a store can be gradually re-encrypted while holding accounts encrypted with either key.
+// Has no effect with envelope encryption, where RotateEncryptionKey only re-wraps the data key.
+func WithSecondaryDecryptionKeys(keys ...string) StorageOption {
+	return func(s *storage) {
+		s.secondaryHex = keys
+	}
+}
+
+// WithReadReplica serves ListAccounts, ListAccountsModifiedSince and StorageSize from the given database,
+// such as a read-only replica of the primary. All writes, and all reads which decide what is written or signed
+// (including every highest value read, OpenAccount and the reconciliations) use the primary database.
+// Since a replica lags behind the primary, these queries may not reflect the latest writes.
+func WithReadReplica(db basedb.Database) StorageOption {
+	return func(s *storage) {
+		s.readDB = db
+	}
+}
+
+// WithDBRetry retries reads and single-key writes which fail with a transient database error,
+// up to the given number of attempts, doubling the given backoff between attempts.
+// Transactions, including all slashing protection writes, are never retried.
+func WithDBRetry(attempts int, backoff time.Duration) StorageOption {
+	return func(s *storage) {
+		if attempts > 1 {
+			s.db = newRetryDB(s.db, attempts, backoff)
+		}
+	}
+}
+
+// WithHighestAttestationHistory keeps the last n highest attestations of each validator, so that
+// RetrieveHighestAttestationAsOf can return the one in effect at a past epoch. Zero disables the history.
+func WithHighestAttestationHistory(n int) StorageOption {
+	return func(s *storage) {
+		s.attHistorySize = max(n, 0)
+	}
+}
@@ -148,6 +148,11 @@ func (eh *EventHandler) handleValidatorAdded(txn basedb.Txn, event *contract.Con
 		return nil, err
 	}
 
+	if err := validateValidatorPublicKey(event.PublicKey); err != nil {
+		logger.Warn("malformed event: invalid validator public key", zap.Error(err))
+		return nil, &MalformedEventError{Err: err}
+	}
+
 	if err := eh.validateOperators(txn, event.OperatorIds); err != nil {
 		return nil, &MalformedEventError{Err: err}
 	}
@@ -3,6 +3,7 @@ package eventhandler
 import (
 	"fmt"
 
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/herumi/bls-eth-go-binary/bls"
@@ -49,6 +50,18 @@ func (eh *EventHandler) validateOperators(txn basedb.Txn, operators []uint64) er
 	return nil
 }
 
+// validateValidatorPublicKey checks that the validator public key of a ValidatorAdded event
+// has the length of a BLS public key, so that an empty key never reaches the shares storage.
+func validateValidatorPublicKey(pubKey []byte) error {
+	if len(pubKey) == 0 {
+		return fmt.Errorf("empty validator public key")
+	}
+	if len(pubKey) != phase0.PublicKeyLength {
+		return fmt.Errorf("validator public key has length %d, expected %d", len(pubKey), phase0.PublicKeyLength)
+	}
+	return nil
+}
+
 // verify signature of the ValidatorAddedEvent shares data
 // todo(align-contract-v0.3.1-rc.0): move to crypto package in ssv protocol?
 func verifySignature(sig []byte, owner ethcommon.Address, pubKey []byte, nonce registrystorage.Nonce) error {
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	"github.com/ssvlabs/ssv/eth/contract"
 	operatorstorage "github.com/ssvlabs/ssv/operator/storage"
 	"github.com/ssvlabs/ssv/registry/storage"
 	"github.com/ssvlabs/ssv/storage/basedb"
@@ -144,3 +145,45 @@ func Test_validateValidatorAddedEvent(t *testing.T) {
 		})
 	}
 }
+
+func Test_validateValidatorPublicKey(t *testing.T) {
+	require.ErrorContains(t, validateValidatorPublicKey(nil), "empty validator public key")
+	require.ErrorContains(t, validateValidatorPublicKey([]byte{}), "empty validator public key")
+	require.ErrorContains(t, validateValidatorPublicKey(make([]byte, 47)), "validator public key has length 47, expected 48")
+	require.ErrorContains(t, validateValidatorPublicKey(make([]byte, 49)), "validator public key has length 49, expected 48")
+	require.NoError(t, validateValidatorPublicKey(make([]byte, 48)))
+}
+
+func TestHandleValidatorAddedEmptyPublicKey(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	db, err := kv.NewInMemory(logger, basedb.Options{})
+	require.NoError(t, err)
+
+	nodeStorage, err := operatorstorage.NewNodeStorage(logger, db)
+	require.NoError(t, err)
+
+	eh := &EventHandler{
+		nodeStorage: nodeStorage,
+		logger:      logger,
+	}
+
+	owner := common.HexToAddress("0x1")
+	txn := nodeStorage.Begin()
+	defer txn.Discard()
+
+	share, err := eh.handleValidatorAdded(txn, &contract.ContractValidatorAdded{
+		Owner:       owner,
+		OperatorIds: []uint64{1, 2, 3, 4},
+	})
+	require.Nil(t, share)
+	var malformedEventError *MalformedEventError
+	require.ErrorAs(t, err, &malformedEventError)
+	require.ErrorContains(t, err, "empty validator public key")
+
+	// The nonce is bumped even though the event is malformed.
+	nonce, err := nodeStorage.GetNextNonce(txn, owner)
+	require.NoError(t, err)
+	require.Equal(t, storage.Nonce(1), nonce)
+	require.Empty(t, nodeStorage.Shares().List(txn))
+}
@@ -10,4 +10,5 @@ import (
 type ExecutionOptions struct {
 	Addr              string        `yaml:"ETH1Addr" env:"ETH_1_ADDR" env-required:"true" env-description:"Execution client WebSocket address"`
 	ConnectionTimeout time.Duration `yaml:"ETH1ConnectionTimeout" env:"ETH_1_CONNECTION_TIMEOUT" env-default:"10s" env-description:"Execution client connection timeout"`
+	FollowDistance    uint64        `yaml:"ETH1FollowDistance" env:"ETH_1_FOLLOW_DISTANCE" env-default:"8" env-description:"Number of blocks a block must be deep before its events are processed, 0 to process events at the head"`
 }
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+
+	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
+)
+
+const (
+	// maxDecidedMessageSize bounds the size of a single message read by ImportDecidedStream.
+	maxDecidedMessageSize = 1 << 20
+	// decidedImportBatch is the number of messages ImportDecidedStream validates before saving them.
+	decidedImportBatch = 100
+)
+
+// ImportDecidedStream imports decided messages of the given identifier from the given stream,
+// in which each message is an SSZ encoded SignedSSVMessage prefixed by its length as a big-endian uint32.
+// Messages are read, validated by the given validate func and saved in batches of decidedImportBatch,
+// so memory use is bounded regardless of the stream's length. A batch is saved only once all its messages
+// are valid, in height order, skipping heights which already have a decided message. Batches preceding
+// an invalid message remain saved, and importing the stream again skips them.
+// Returns the number of imported messages.
+func (i *ibftStorage) ImportDecidedStream(
+	identifier []byte,
+	committeeMember *spectypes.CommitteeMember,
+	r io.Reader,
+	validate func(*spectypes.SignedSSVMessage) error,
+) (int, error) {
+	highest, err := i.GetHighestInstance(identifier)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get highest instance")
+	}
+
+	imported := 0
+	batch := make([]*specqbft.ProcessingMessage, 0, decidedImportBatch)
+	// saveBatch saves the batch in height order, skipping heights which already have a decided message.
+	saveBatch := func() error {
+		sort.SliceStable(batch, func(a, b int) bool {
+			return batch[a].QBFTMessage.Height < batch[b].QBFTMessage.Height
+		})
+		for n, msg := range batch {
+			height := msg.QBFTMessage.Height
+			if n > 0 && batch[n-1].QBFTMessage.Height == height {
+				continue
+			}
+			existing, err := i.GetInstance(identifier, height)
+			if err != nil {
+				return errors.Wrap(err, "could not get instance")
+			}
+			if existing != nil && existing.DecidedMessage != nil {
+				continue
+			}
+
+			inst := decidedInstance(committeeMember, msg)
+			asHighest := highest == nil || height > highest.State.Height
+			if err := i.saveInstance(inst, true, asHighest); err != nil {
+				return errors.Wrapf(err, "could not save decided message at height %d", height)
+			}
+			if asHighest {
+				highest = inst
+			}
+			imported++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for n := 0; ; n++ {
+		signedMsg, err := readDecidedMessage(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, errors.Wrapf(err, "could not read message %d", n)
+		}
+		msg, err := specqbft.NewProcessingMessage(signedMsg)
+		if err != nil {
+			return imported, errors.Wrapf(err, "could not decode message %d", n)
+		}
+		if !bytes.Equal(msg.QBFTMessage.Identifier, identifier) {
+			return imported, errors.Errorf("message %d has a different identifier", n)
+		}
+		if err := validate(signedMsg); err != nil {
+			return imported, errors.Wrapf(err, "invalid message %d", n)
+		}
+		batch = append(batch, msg)
+		if len(batch) == decidedImportBatch {
+			if err := saveBatch(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := saveBatch(); err != nil {
+		return imported, err
+	}
+	return imported, nil
+}
+
+// readDecidedMessage reads a length-prefixed message. Returns io.EOF only if the stream ended before the message.
+func readDecidedMessage(r io.Reader) (*spectypes.SignedSSVMessage, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "could not read message size")
+	}
+	if size > maxDecidedMessageSize {
+		return nil, errors.Errorf("message size %d exceeds the maximum of %d", size, maxDecidedMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, errors.Wrap(err, "could not read message")
+	}
+	msg := &spectypes.SignedSSVMessage{}
+	if err := msg.Decode(data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// decidedInstance returns a stored instance holding only the given decided message.
+func decidedInstance(committeeMember *spectypes.CommitteeMember, msg *specqbft.ProcessingMessage) *qbftstorage.StoredInstance {
+	return &qbftstorage.StoredInstance{
+		State: &specqbft.State{
+			CommitteeMember:      committeeMember,
+			ID:                   msg.QBFTMessage.Identifier,
+			Round:                msg.QBFTMessage.Round,
+			Height:               msg.QBFTMessage.Height,
+			Decided:              true,
+			DecidedValue:         msg.SignedMessage.FullData,
+			ProposeContainer:     specqbft.NewMsgContainer(),
+			PrepareContainer:     specqbft.NewMsgContainer(),
+			CommitContainer:      specqbft.NewMsgContainer(),
+			RoundChangeContainer: specqbft.NewMsgContainer(),
+		},
+		DecidedMessage: msg.SignedMessage,
+	}
+}
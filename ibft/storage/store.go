@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/binary"
 	"fmt"
+
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -138,6 +139,44 @@ func (i *ibftStorage) GetInstancesInRange(identifier []byte, from specqbft.Heigh
 	return instances, nil
 }
 
+// GetDecidedInRange returns a page of the decided messages in the given height range.
+// Heights are stored little-endian, so instance keys aren't ordered by height and can't be range scanned:
+// each height in the range is read in turn until the page is full, which costs up to to-from+1 reads.
+// The range is therefore limited to qbftstorage.MaxDecidedRange heights.
+func (i *ibftStorage) GetDecidedInRange(identifier []byte, from, to specqbft.Height, limit, offset int) ([]*spectypes.SignedSSVMessage, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", offset)
+	}
+	decided := make([]*spectypes.SignedSSVMessage, 0)
+	if to < from {
+		return decided, nil
+	}
+	if to-from >= qbftstorage.MaxDecidedRange {
+		return nil, fmt.Errorf("height range of %d exceeds the maximum of %d", uint64(to-from)+1, qbftstorage.MaxDecidedRange)
+	}
+
+	skipped := 0
+	for height := from; height <= to; height++ {
+		if limit > 0 && len(decided) >= limit {
+			break
+		}
+		instance, err := i.GetInstance(identifier, height)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get instance")
+		}
+		if instance == nil || instance.DecidedMessage == nil {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		decided = append(decided, instance.DecidedMessage)
+	}
+
+	return decided, nil
+}
+
 // CleanAllInstances removes all StoredInstance's & highest StoredInstance's for msgID.
 func (i *ibftStorage) CleanAllInstances(logger *zap.Logger, msgID []byte) error {
 	prefix := i.prefix
@@ -154,6 +193,52 @@ func (i *ibftStorage) CleanAllInstances(logger *zap.Logger, msgID []byte) error
 	return nil
 }
 
+// PruneDecided removes the historical instances of identifier below the given height, except for the highest
+// historical instance which is always kept as an anchor. The highest instance is not affected.
+// Returns the number of removed instances.
+func (i *ibftStorage) PruneDecided(identifier []byte, olderThan specqbft.Height) (int, error) {
+	prefix := append(append([]byte{}, i.prefix...), identifier...)
+	prefix = append(prefix, []byte(instanceKey)...)
+	// Deleted keys are built by appending to prefix and are held by the transaction until it commits,
+	// so prefix must have no spare capacity for them to not share memory.
+	prefix = prefix[:len(prefix):len(prefix)]
+
+	var (
+		heights []specqbft.Height
+		highest specqbft.Height
+	)
+	err := i.db.GetAll(prefix, func(_ int, obj basedb.Obj) error {
+		if len(obj.Key) != 8 {
+			return fmt.Errorf("invalid instance key length: %d", len(obj.Key))
+		}
+		height := specqbft.Height(binary.LittleEndian.Uint64(obj.Key))
+		heights = append(heights, height)
+		highest = max(highest, height)
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list instances")
+	}
+
+	removed := 0
+	err = i.db.Update(func(txn basedb.Txn) error {
+		for _, height := range heights {
+			if height >= olderThan || height == highest {
+				continue
+			}
+			if err := txn.Delete(prefix, uInt64ToByteSlice(uint64(height))); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to remove instances")
+	}
+	return removed, nil
+}
+
 func (i *ibftStorage) SaveParticipants(identifier convert.MessageID, slot phase0.Slot, operators []spectypes.OperatorID) error {
 	bytes, err := encodeOperators(operators)
 	if err != nil {
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"crypto/rsa"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -14,6 +18,7 @@ import (
 
 	"github.com/ssvlabs/ssv/logging"
 	"github.com/ssvlabs/ssv/networkconfig"
+	qbftctrl "github.com/ssvlabs/ssv/protocol/v2/qbft/controller"
 	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
 	"github.com/ssvlabs/ssv/storage/basedb"
 	"github.com/ssvlabs/ssv/storage/kv"
@@ -208,3 +213,215 @@ func TestEncodeDecodeOperators(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDecidedInRange(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	logger := logging.TestLogger(t)
+	msgID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), []byte("pk"), spectypes.RoleCommittee)
+	storage, err := newTestIbftStorage(logger, "test")
+	require.NoError(t, err)
+
+	for h := specqbft.Height(1); h <= 10; h++ {
+		require.NoError(t, storage.SaveInstance(&qbftstorage.StoredInstance{
+			State: &specqbft.State{
+				ID:               msgID[:],
+				Height:           h,
+				Decided:          true,
+				DecidedValue:     []byte("value"),
+				CommitContainer:  specqbft.NewMsgContainer(),
+				ProposeContainer: specqbft.NewMsgContainer(),
+			},
+			DecidedMessage: testingutils.TestingCommitMultiSignerMessageWithHeightAndIdentifier(
+				[]*rsa.PrivateKey{ks.OperatorKeys[1], ks.OperatorKeys[2], ks.OperatorKeys[3]},
+				[]spectypes.OperatorID{1, 2, 3},
+				h,
+				msgID[:],
+			),
+		}))
+	}
+
+	heights := func(msgs []*spectypes.SignedSSVMessage) []specqbft.Height {
+		var ret []specqbft.Height
+		for _, msg := range msgs {
+			qbftMsg := &specqbft.Message{}
+			require.NoError(t, qbftMsg.Decode(msg.SSVMessage.Data))
+			ret = append(ret, qbftMsg.Height)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name          string
+		from, to      specqbft.Height
+		limit, offset int
+		expected      []specqbft.Height
+	}{
+		{name: "no limit", from: 3, to: 5, expected: []specqbft.Height{3, 4, 5}},
+		{name: "first page", from: 1, to: 10, limit: 4, expected: []specqbft.Height{1, 2, 3, 4}},
+		{name: "second page", from: 1, to: 10, limit: 4, offset: 4, expected: []specqbft.Height{5, 6, 7, 8}},
+		{name: "last partial page", from: 1, to: 10, limit: 4, offset: 8, expected: []specqbft.Height{9, 10}},
+		{name: "offset past range", from: 1, to: 10, limit: 4, offset: 10},
+		{name: "range beyond stored heights", from: 9, to: 20, limit: 5, expected: []specqbft.Height{9, 10}},
+		{name: "widest range", from: 8, to: 8 + qbftstorage.MaxDecidedRange - 1, expected: []specqbft.Height{8, 9, 10}},
+		{name: "empty range", from: 5, to: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := storage.GetDecidedInRange(msgID[:], test.from, test.to, test.limit, test.offset)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, heights(res))
+		})
+	}
+
+	_, err = storage.GetDecidedInRange(msgID[:], 1, 10, 1, -1)
+	require.Error(t, err)
+
+	// Ranges wider than MaxDecidedRange are rejected rather than read height by height.
+	_, err = storage.GetDecidedInRange(msgID[:], 8, 8+qbftstorage.MaxDecidedRange, 0, 0)
+	require.ErrorContains(t, err, "exceeds the maximum")
+	_, err = storage.GetDecidedInRange(msgID[:], 8, math.MaxUint64, 0, 0)
+	require.ErrorContains(t, err, "exceeds the maximum")
+}
+
+func TestImportDecidedStream(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	committeeMember := testingutils.TestingCommitteeMember(ks)
+	logger := logging.TestLogger(t)
+	msgID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), []byte("pk"), spectypes.RoleCommittee)
+	storage, err := newTestIbftStorage(logger, "test")
+	require.NoError(t, err)
+
+	decided := func(id spectypes.MessageID, h specqbft.Height) *spectypes.SignedSSVMessage {
+		return testingutils.TestingCommitMultiSignerMessageWithHeightAndIdentifier(
+			[]*rsa.PrivateKey{ks.OperatorKeys[1], ks.OperatorKeys[2], ks.OperatorKeys[3]},
+			[]spectypes.OperatorID{1, 2, 3},
+			h,
+			id[:],
+		)
+	}
+	// The config isn't used to validate decided messages.
+	validate := func(signedMsg *spectypes.SignedSSVMessage) error {
+		msg, err := specqbft.NewProcessingMessage(signedMsg)
+		if err != nil {
+			return err
+		}
+		return qbftctrl.ValidateDecided(nil, msg, committeeMember)
+	}
+	stream := func(msgs ...*spectypes.SignedSSVMessage) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		for _, msg := range msgs {
+			data, err := msg.Encode()
+			require.NoError(t, err)
+			require.NoError(t, binary.Write(buf, binary.BigEndian, uint32(len(data))))
+			buf.Write(data)
+		}
+		return buf
+	}
+
+	// Messages are imported in height order, and duplicates are imported once.
+	imported, err := storage.ImportDecidedStream(msgID[:], committeeMember, stream(decided(msgID, 3), decided(msgID, 1), decided(msgID, 3)), validate)
+	require.NoError(t, err)
+	require.Equal(t, 2, imported)
+
+	msgs, err := storage.GetDecidedInRange(msgID[:], 0, 10, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	highest, err := storage.GetHighestInstance(msgID[:])
+	require.NoError(t, err)
+	require.Equal(t, specqbft.Height(3), highest.State.Height)
+
+	// Heights which are already decided are skipped, and the highest instance is only raised.
+	imported, err = storage.ImportDecidedStream(msgID[:], committeeMember, stream(decided(msgID, 1), decided(msgID, 2)), validate)
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+	highest, err = storage.GetHighestInstance(msgID[:])
+	require.NoError(t, err)
+	require.Equal(t, specqbft.Height(3), highest.State.Height)
+
+	// Nothing in the batch of an invalid message is imported.
+	otherID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), []byte("other"), spectypes.RoleCommittee)
+	_, err = storage.ImportDecidedStream(msgID[:], committeeMember, stream(decided(msgID, 4), decided(otherID, 5)), validate)
+	require.ErrorContains(t, err, "different identifier")
+
+	notDecided := testingutils.TestingCommitMultiSignerMessageWithHeightAndIdentifier(
+		[]*rsa.PrivateKey{ks.OperatorKeys[1]}, []spectypes.OperatorID{1}, 4, msgID[:])
+	_, err = storage.ImportDecidedStream(msgID[:], committeeMember, stream(decided(msgID, 4), notDecided), validate)
+	require.ErrorContains(t, err, "not a decided msg")
+
+	truncated := stream(decided(msgID, 4))
+	truncated.Truncate(truncated.Len() - 1)
+	_, err = storage.ImportDecidedStream(msgID[:], committeeMember, truncated, validate)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	instance, err := storage.GetInstance(msgID[:], 4)
+	require.NoError(t, err)
+	require.Nil(t, instance)
+
+	// Batches preceding an invalid message are saved, and importing again skips them.
+	var batches []*spectypes.SignedSSVMessage
+	for h := specqbft.Height(4); h < 4+decidedImportBatch+2; h++ {
+		batches = append(batches, decided(msgID, h))
+	}
+	_, err = storage.ImportDecidedStream(msgID[:], committeeMember, stream(append(batches, decided(otherID, 1))...), validate)
+	require.ErrorContains(t, err, "different identifier")
+	highest, err = storage.GetHighestInstance(msgID[:])
+	require.NoError(t, err)
+	require.Equal(t, specqbft.Height(3+decidedImportBatch), highest.State.Height)
+
+	imported, err = storage.ImportDecidedStream(msgID[:], committeeMember, stream(batches...), validate)
+	require.NoError(t, err)
+	require.Equal(t, 2, imported)
+	highest, err = storage.GetHighestInstance(msgID[:])
+	require.NoError(t, err)
+	require.Equal(t, specqbft.Height(5+decidedImportBatch), highest.State.Height)
+}
+
+func TestPruneDecided(t *testing.T) {
+	logger := logging.TestLogger(t)
+	msgID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), []byte("pk"), spectypes.RoleCommittee)
+	otherID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), []byte("pk2"), spectypes.RoleCommittee)
+	storage, err := newTestIbftStorage(logger, "test")
+	require.NoError(t, err)
+
+	save := func(id spectypes.MessageID, h specqbft.Height) {
+		require.NoError(t, storage.SaveHighestAndHistoricalInstance(&qbftstorage.StoredInstance{
+			State: &specqbft.State{
+				ID:               id[:],
+				Height:           h,
+				Decided:          true,
+				DecidedValue:     []byte("value"),
+				CommitContainer:  specqbft.NewMsgContainer(),
+				ProposeContainer: specqbft.NewMsgContainer(),
+			},
+		}))
+	}
+	for h := specqbft.Height(1); h <= 10; h++ {
+		save(msgID, h)
+		save(otherID, h)
+	}
+	stored := func(id spectypes.MessageID) []specqbft.Height {
+		instances, err := storage.GetInstancesInRange(id[:], 0, 10)
+		require.NoError(t, err)
+		var heights []specqbft.Height
+		for _, inst := range instances {
+			heights = append(heights, inst.State.Height)
+		}
+		return heights
+	}
+
+	removed, err := storage.PruneDecided(msgID[:], 8)
+	require.NoError(t, err)
+	require.Equal(t, 7, removed)
+	require.Equal(t, []specqbft.Height{8, 9, 10}, stored(msgID))
+	require.Len(t, stored(otherID), 10)
+
+	// The highest historical instance is kept even if it's below the given height.
+	removed, err = storage.PruneDecided(msgID[:], 100)
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	require.Equal(t, []specqbft.Height{10}, stored(msgID))
+
+	highest, err := storage.GetHighestInstance(msgID[:])
+	require.NoError(t, err)
+	require.Equal(t, specqbft.Height(10), highest.State.Height)
+}
@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -28,6 +30,20 @@ func (mv *messageValidator) validateSlotTime(messageSlot phase0.Slot, role spect
 	return nil
 }
 
+// validateFork checks that the message's domain matches the fork which is active at the message's slot,
+// so messages of a previous fork can't be replayed after the fork boundary.
+func (mv *messageValidator) validateFork(msgID spectypes.MessageID, messageSlot phase0.Slot) error {
+	expected := mv.netCfg.DomainTypeAtEpoch(mv.netCfg.Beacon.EstimatedEpochAtSlot(messageSlot))
+	if domain := msgID.GetDomain(); !bytes.Equal(domain, expected[:]) {
+		e := ErrWrongFork
+		e.got = hex.EncodeToString(domain)
+		e.want = hex.EncodeToString(expected[:])
+		return e
+	}
+
+	return nil
+}
+
 // messageEarliness returns how early message is or 0 if it's not
 func (mv *messageValidator) messageEarliness(slot phase0.Slot, receivedAt time.Time) time.Duration {
 	return mv.netCfg.Beacon.GetSlotStartTime(slot).Sub(receivedAt)
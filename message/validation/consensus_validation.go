@@ -252,6 +252,12 @@ func (mv *messageValidator) validateQBFTMessageByDutyLogic(
 	}
 
 	msgSlot := phase0.Slot(consensusMessage.Height)
+
+	// Rule: Message domain must match the fork which is active at the message's slot
+	if err := mv.validateFork(signedSSVMessage.SSVMessage.GetID(), msgSlot); err != nil {
+		return err
+	}
+
 	randaoMsg := false
 	if err := mv.validateBeaconDuty(signedSSVMessage.SSVMessage.GetID().GetRoleType(), msgSlot, validatorIndices, randaoMsg); err != nil {
 		return err
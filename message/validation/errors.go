@@ -53,6 +53,7 @@ func (e Error) Text() string {
 
 var (
 	ErrWrongDomain                             = Error{text: "wrong domain"}
+	ErrWrongFork                               = Error{text: "message domain doesn't match the fork at its slot"}
 	ErrNoShareMetadata                         = Error{text: "share has no metadata"}
 	ErrUnknownValidator                        = Error{text: "unknown validator"}
 	ErrValidatorLiquidated                     = Error{text: "validator is liquidated"}
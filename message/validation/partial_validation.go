@@ -155,6 +155,11 @@ func (mv *messageValidator) validatePartialSigMessagesByDutyLogic(
 		}
 	}
 
+	// Rule: Message domain must match the fork which is active at the message's slot
+	if err := mv.validateFork(signedSSVMessage.SSVMessage.GetID(), messageSlot); err != nil {
+		return err
+	}
+
 	randaoMsg := partialSignatureMessages.Type == spectypes.RandaoPartialSig
 	if err := mv.validateBeaconDuty(signedSSVMessage.SSVMessage.GetID().GetRoleType(), messageSlot, committeeInfo.indices, randaoMsg); err != nil {
 		return err
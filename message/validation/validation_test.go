@@ -424,6 +424,27 @@ func Test_ValidateSSVMessage(t *testing.T) {
 		require.ErrorIs(t, err, expectedErr)
 	})
 
+	// Make sure messages are dropped if their domain doesn't match the fork at their slot
+	t.Run("wrong fork", func(t *testing.T) {
+		forkNetCfg := netCfg
+		forkNetCfg.AlanForkEpoch = 2
+
+		validator := New(forkNetCfg, validatorStore, dutyStore, signatureVerifier).(*messageValidator)
+
+		// The message is sent with the current (post-fork) domain, but for a pre-fork slot.
+		slot := forkNetCfg.Beacon.FirstSlotAtEpoch(1)
+		msgID := spectypes.NewMsgID(forkNetCfg.DomainType(), encodedCommitteeID, committeeRole)
+		signedSSVMessage := generateSignedMessage(ks, msgID, slot)
+
+		topicID := commons.CommitteeTopicID(committeeID)[0]
+		receivedAt := forkNetCfg.Beacon.GetSlotStartTime(slot)
+		_, err = validator.handleSignedSSVMessage(signedSSVMessage, topicID, receivedAt)
+		expectedErr := ErrWrongFork
+		expectedErr.got = hex.EncodeToString(forkNetCfg.AlanDomainType[:])
+		expectedErr.want = hex.EncodeToString(forkNetCfg.GenesisDomainType[:])
+		require.ErrorIs(t, err, expectedErr)
+	})
+
 	// Send message with a value that refers to a non-existent role
 	t.Run("invalid role", func(t *testing.T) {
 		validator := New(netCfg, validatorStore, dutyStore, signatureVerifier).(*messageValidator)
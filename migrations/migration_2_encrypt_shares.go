@@ -33,7 +33,10 @@ var migration_2_encrypt_shares = Migration{
 				return fmt.Errorf("failed to get private key: %w", err)
 			}
 
-			signerStorage := opt.signerStorage(logger)
+			signerStorage, err := opt.signerStorage(logger)
+			if err != nil {
+				return fmt.Errorf("failed to create signer storage: %w", err)
+			}
 			accounts, err := signerStorage.ListAccountsTxn(txn)
 			if err != nil {
 				return fmt.Errorf("failed to list accounts: %w", err)
@@ -64,7 +64,7 @@ func (o Options) nodeStorage(logger *zap.Logger) (operatorstorage.Storage, error
 }
 
 // nolint
-func (o Options) signerStorage(logger *zap.Logger) ekm.Storage {
+func (o Options) signerStorage(logger *zap.Logger) (ekm.Storage, error) {
 	return ekm.NewSignerStorage(o.Db, o.Network, logger)
 }
 
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -100,6 +101,8 @@ type ControllerOptions struct {
 	ValidatorsMap              *validators.ValidatorsMap
 	NetworkConfig              networkconfig.NetworkConfig
 	Graffiti                   []byte
+	ReplayBufferSize           int           `yaml:"ReplayBufferSize" env:"REPLAY_BUFFER_SIZE" env-default:"0" env-description:"Number of inbound messages kept per validator and replayed when it starts, disabled if 0"`
+	ReplayBufferMaxAge         time.Duration `yaml:"ReplayBufferMaxAge" env:"REPLAY_BUFFER_MAX_AGE" env-default:"1m" env-description:"Maximum age of inbound messages replayed when a validator starts"`
 
 	// worker flags
 	WorkersCount    int `yaml:"MsgWorkersCount" env:"MSG_WORKERS_COUNT" env-default:"256" env-description:"Number of goroutines to use for message workers"`
@@ -256,6 +259,10 @@ func NewController(logger *zap.Logger, options ControllerOptions) Controller {
 		},
 	}
 
+	if options.ReplayBufferSize > 0 {
+		validatorOptions.ReplayBuffer = validator.NewReplayBuffer(logger, options.DB, options.ReplayBufferSize, options.ReplayBufferMaxAge)
+	}
+
 	//TODO
 	genesisValidatorOptions := genesisvalidator.Options{
 		Network:       options.GenesisControllerOptions.Network,
@@ -626,6 +633,10 @@ func (c *controller) startValidators(validators []*validators.ValidatorContainer
 
 // StartNetworkHandlers init msg worker that handles network messages
 func (c *controller) StartNetworkHandlers() {
+	// The replay buffer's writer must run before messages are routed to validators.
+	if c.validatorOptions.ReplayBuffer != nil {
+		c.validatorOptions.ReplayBuffer.Start(c.ctx)
+	}
 	c.network.UseMessageRouter(c.messageRouter)
 	for i := 0; i < networkRouterConcurrency; i++ {
 		go c.handleRouterMessages()
@@ -735,6 +746,10 @@ func (c *controller) ExecuteDuty(logger *zap.Logger, duty *spectypes.ValidatorDu
 	copy(pk, duty.PubKey[:])
 
 	if v, ok := c.GetValidator(spectypes.ValidatorPK(pk)); ok {
+		if !slices.Contains(v.Validator().SupportedRoles(), duty.RunnerRole()) {
+			logger.Warn("validator does not support duty role, not executing duty", fields.Role(duty.RunnerRole()))
+			return
+		}
 		ssvMsg, err := CreateDutyExecuteMsg(duty, pk, c.networkConfig.DomainType())
 		if err != nil {
 			logger.Error("could not create duty execute msg", zap.Error(err))
@@ -0,0 +1,67 @@
+package beacon
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/pkg/errors"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+)
+
+// DomainDataProvider provides the signature domain of a domain type at a given epoch,
+// according to the fork active at that epoch.
+type DomainDataProvider interface {
+	DomainData(epoch phase0.Epoch, domain phase0.DomainType) (phase0.Domain, error)
+}
+
+// DutyDomainType returns the domain type of the signature produced for the given duty role.
+func DutyDomainType(role spectypes.BeaconRole) (phase0.DomainType, error) {
+	switch role {
+	case spectypes.BNRoleAttester:
+		return spectypes.DomainAttester, nil
+	case spectypes.BNRoleAggregator:
+		return spectypes.DomainAggregateAndProof, nil
+	case spectypes.BNRoleProposer:
+		return spectypes.DomainProposer, nil
+	case spectypes.BNRoleSyncCommittee:
+		return spectypes.DomainSyncCommittee, nil
+	case spectypes.BNRoleSyncCommitteeContribution:
+		return spectypes.DomainContributionAndProof, nil
+	case spectypes.BNRoleValidatorRegistration:
+		return spectypes.DomainApplicationBuilder, nil
+	case spectypes.BNRoleVoluntaryExit:
+		return spectypes.DomainVoluntaryExit, nil
+	default:
+		return phase0.DomainType{}, errors.Errorf("unknown duty role %v", role)
+	}
+}
+
+// ComputeSigningRoot returns the signing root and the domain of the signature the given duty would produce over
+// the given data, without signing it. The domain is derived from the fork active at the epoch of the duty's slot.
+// Pre-consensus signatures (such as selection proofs and randao reveals) have their own domains and aren't covered.
+func ComputeSigningRoot(
+	provider DomainDataProvider,
+	network BeaconNetwork,
+	duty *spectypes.ValidatorDuty,
+	data ssz.HashRoot,
+) ([32]byte, phase0.Domain, error) {
+	if duty == nil {
+		return [32]byte{}, phase0.Domain{}, errors.New("duty is nil")
+	}
+	if data == nil {
+		return [32]byte{}, phase0.Domain{}, errors.New("data is nil")
+	}
+
+	domainType, err := DutyDomainType(duty.Type)
+	if err != nil {
+		return [32]byte{}, phase0.Domain{}, err
+	}
+	domain, err := provider.DomainData(network.EstimatedEpochAtSlot(duty.Slot), domainType)
+	if err != nil {
+		return [32]byte{}, phase0.Domain{}, errors.Wrap(err, "could not get domain data")
+	}
+	root, err := spectypes.ComputeETHSigningRoot(data, domain)
+	if err != nil {
+		return [32]byte{}, phase0.Domain{}, errors.Wrap(err, "could not compute signing root")
+	}
+	return root, domain, nil
+}
@@ -0,0 +1,44 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testDomainProvider struct {
+	epochs []phase0.Epoch
+}
+
+func (p *testDomainProvider) DomainData(epoch phase0.Epoch, domainType phase0.DomainType) (phase0.Domain, error) {
+	p.epochs = append(p.epochs, epoch)
+	var domain phase0.Domain
+	copy(domain[:], domainType[:])
+	domain[4] = byte(epoch)
+	return domain, nil
+}
+
+func TestComputeSigningRoot(t *testing.T) {
+	network := NewNetwork(spectypes.HoleskyNetwork)
+	provider := &testDomainProvider{}
+	duty := &spectypes.ValidatorDuty{Type: spectypes.BNRoleAttester, Slot: 65}
+	data := &phase0.AttestationData{
+		Slot:   65,
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+
+	root, domain, err := ComputeSigningRoot(provider, network, duty, data)
+	require.NoError(t, err)
+	require.Equal(t, []phase0.Epoch{2}, provider.epochs)
+	require.Equal(t, phase0.Domain{0x01, 0, 0, 0, 2}, domain)
+
+	expectedRoot, err := spectypes.ComputeETHSigningRoot(data, domain)
+	require.NoError(t, err)
+	require.Equal(t, [32]byte(expectedRoot), root)
+
+	_, _, err = ComputeSigningRoot(provider, network, &spectypes.ValidatorDuty{Type: spectypes.BNRoleUnknown}, data)
+	require.ErrorContains(t, err, "unknown duty role")
+}
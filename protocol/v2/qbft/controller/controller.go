@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
 	ssvtypes "github.com/ssvlabs/ssv/protocol/v2/types"
@@ -23,6 +24,24 @@ import (
 // it will be called in a new goroutine to avoid concurrency issues
 type NewDecidedHandler func(msg qbftstorage.ParticipantsRangeEntry)
 
+// IController is the QBFT controller, as used by duty runners and validators.
+type IController interface {
+	// StartNewInstance starts a new QBFT instance at the given height with the given value.
+	StartNewInstance(logger *zap.Logger, height specqbft.Height, value []byte) error
+	// ProcessMsg processes the given message, and returns the decided message if it decided an instance.
+	ProcessMsg(logger *zap.Logger, signedMessage *spectypes.SignedSSVMessage) (*spectypes.SignedSSVMessage, error)
+	// OnTimeout processes the given round timeout event.
+	OnTimeout(logger *zap.Logger, msg ssvtypes.EventMsg) error
+	// DecidedChan returns a channel which is pushed every newly decided message as soon as quorum is reached.
+	DecidedChan() <-chan *spectypes.SignedSSVMessage
+}
+
+var _ IController = (*Controller)(nil)
+
+// decidedChanBufferSize is the number of decided messages buffered for a slow DecidedChan consumer,
+// beyond which newly decided messages are dropped rather than blocking consensus.
+const decidedChanBufferSize = 32
+
 // Controller is a QBFT coordinator responsible for starting and following the entire life cycle of multiple QBFT InstanceContainer
 type Controller struct {
 	Identifier []byte
@@ -34,6 +53,8 @@ type Controller struct {
 	NewDecidedHandler NewDecidedHandler       `json:"-"`
 	config            qbft.IConfig
 	fullNode          bool
+	decidedChLock     sync.Mutex
+	decidedCh         chan *spectypes.SignedSSVMessage // nil until DecidedChan is called
 }
 
 func NewController(
@@ -54,6 +75,36 @@ func NewController(
 	}
 }
 
+// DecidedChan returns a channel which is pushed every newly decided message as soon as quorum is reached.
+// The channel is created on the first call, so messages decided before it aren't pushed.
+// The channel is buffered, and decided messages are dropped if the consumer falls behind.
+func (c *Controller) DecidedChan() <-chan *spectypes.SignedSSVMessage {
+	c.decidedChLock.Lock()
+	defer c.decidedChLock.Unlock()
+
+	if c.decidedCh == nil {
+		c.decidedCh = make(chan *spectypes.SignedSSVMessage, decidedChanBufferSize)
+	}
+	return c.decidedCh
+}
+
+// notifyDecided pushes the given decided message to DecidedChan without blocking.
+// It does nothing if DecidedChan was never called, since there's no consumer.
+func (c *Controller) notifyDecided(logger *zap.Logger, decidedMsg *spectypes.SignedSSVMessage) {
+	c.decidedChLock.Lock()
+	decidedCh := c.decidedCh
+	c.decidedChLock.Unlock()
+
+	if decidedMsg == nil || decidedCh == nil {
+		return
+	}
+	select {
+	case decidedCh <- decidedMsg:
+	default:
+		logger.Debug("❗ decided channel is full, dropping decided message")
+	}
+}
+
 // StartNewInstance will start a new QBFT instance, if can't will return error
 func (c *Controller) StartNewInstance(logger *zap.Logger, height specqbft.Height, value []byte) error {
 
@@ -107,19 +158,28 @@ func (c *Controller) ProcessMsg(logger *zap.Logger, signedMessage *spectypes.Sig
 	if err != nil {
 		return nil, err
 	}
+
+	var decidedMsg *spectypes.SignedSSVMessage
 	if isDecided {
-		return c.UponDecided(logger, msg)
-	}
+		decidedMsg, err = c.UponDecided(logger, msg)
+	} else {
+		var isFuture bool
+		isFuture, err = c.isFutureMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		if isFuture {
+			return nil, fmt.Errorf("future msg from height, could not process")
+		}
 
-	isFuture, err := c.isFutureMessage(msg)
+		decidedMsg, err = c.UponExistingInstanceMsg(logger, msg)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if isFuture {
-		return nil, fmt.Errorf("future msg from height, could not process")
-	}
 
-	return c.UponExistingInstanceMsg(logger, msg)
+	c.notifyDecided(logger, decidedMsg)
+	return decidedMsg, nil
 }
 
 func (c *Controller) UponExistingInstanceMsg(logger *zap.Logger, msg *specqbft.ProcessingMessage) (*spectypes.SignedSSVMessage, error) {
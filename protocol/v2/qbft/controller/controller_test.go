@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"crypto/rsa"
 	"encoding/json"
 	"testing"
 
 	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
 	spectestingutils "github.com/ssvlabs/ssv-spec/types/testingutils"
 	"github.com/stretchr/testify/require"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/ssvlabs/ssv/protocol/v2/qbft"
 	"github.com/ssvlabs/ssv/protocol/v2/qbft/instance"
 	"github.com/ssvlabs/ssv/protocol/v2/qbft/roundtimer"
+	qbftstorage "github.com/ssvlabs/ssv/protocol/v2/qbft/storage"
 	"github.com/ssvlabs/ssv/protocol/v2/types"
 )
 
@@ -98,3 +101,59 @@ func TestController_OnTimeoutWithRoundCheck(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, specqbft.Round(2), inst.State.Round, "Round should bump")
 }
+
+// decidedTestStorage keeps nothing, since only highest instances are saved by light node controllers.
+type decidedTestStorage struct {
+	qbftstorage.QBFTStore
+}
+
+func (decidedTestStorage) SaveHighestInstance(*qbftstorage.StoredInstance) error {
+	return nil
+}
+
+func TestController_DecidedChan(t *testing.T) {
+	logger := logging.TestLogger(t)
+	keySet := spectestingutils.Testing4SharesSet()
+	identifier := spectypes.NewMsgID(spectestingutils.TestingSSVDomainType, spectestingutils.TestingValidatorPubKey[:], spectypes.RoleCommittee)
+	config := &qbft.Config{
+		BeaconSigner: spectestingutils.NewTestingKeyManager(),
+		Network:      spectestingutils.NewTestingNetwork(1, keySet.OperatorKeys[1]),
+		Timer:        roundtimer.NewTestingTimer(),
+		CutOffRound:  spectestingutils.TestingCutOffRound,
+		Storage:      decidedTestStorage{},
+	}
+	var c IController = NewController(identifier[:], spectestingutils.TestingCommitteeMember(keySet), config,
+		spectestingutils.TestingOperatorSigner(keySet), false)
+
+	decided := func(height specqbft.Height) *spectypes.SignedSSVMessage {
+		return spectestingutils.TestingCommitMultiSignerMessageWithHeightAndIdentifier(
+			[]*rsa.PrivateKey{keySet.OperatorKeys[1], keySet.OperatorKeys[2], keySet.OperatorKeys[3]},
+			[]spectypes.OperatorID{1, 2, 3},
+			height,
+			identifier[:],
+		)
+	}
+
+	// Without a consumer, decided messages aren't buffered.
+	_, err := c.ProcessMsg(logger, decided(1))
+	require.NoError(t, err)
+	require.Nil(t, c.(*Controller).decidedCh)
+
+	decidedCh := c.DecidedChan()
+	decidedMsg, err := c.ProcessMsg(logger, decided(2))
+	require.NoError(t, err)
+	require.NotNil(t, decidedMsg)
+	require.Equal(t, decidedMsg, <-decidedCh)
+
+	// An instance which already decided isn't pushed again.
+	_, err = c.ProcessMsg(logger, decided(2))
+	require.NoError(t, err)
+	require.Empty(t, c.DecidedChan())
+
+	// A slow consumer must not block consensus.
+	for height := specqbft.Height(3); height < decidedChanBufferSize+4; height++ {
+		_, err := c.ProcessMsg(logger, decided(height))
+		require.NoError(t, err)
+	}
+	require.Len(t, c.DecidedChan(), decidedChanBufferSize)
+}
@@ -2,6 +2,8 @@ package qbftstorage
 
 import (
 	"encoding/json"
+	"io"
+
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	spectypes "github.com/ssvlabs/ssv-spec/types"
 	"github.com/ssvlabs/ssv/exporter/convert"
@@ -33,6 +35,9 @@ type ParticipantsRangeEntry struct {
 }
 
 // InstanceStore manages instance data.
+// MaxDecidedRange is the largest number of heights GetDecidedInRange accepts in a single call.
+const MaxDecidedRange = 1024
+
 type InstanceStore interface {
 	// GetHighestInstance returns the highest instance for the given identifier.
 	GetHighestInstance(identifier []byte) (*StoredInstance, error)
@@ -40,6 +45,17 @@ type InstanceStore interface {
 	// GetInstancesInRange returns historical instances in the given range.
 	GetInstancesInRange(identifier []byte, from specqbft.Height, to specqbft.Height) ([]*StoredInstance, error)
 
+	// GetDecidedInRange returns a page of the decided messages in the given height range, ordered by height.
+	// The first offset decided messages in the range are skipped, and at most limit are returned (no limit if limit <= 0).
+	// If the range holds fewer decided messages than requested, only those are returned without an error.
+	// Each height in the range may be read, so ranges of more than MaxDecidedRange heights are rejected.
+	GetDecidedInRange(identifier []byte, from, to specqbft.Height, limit, offset int) ([]*spectypes.SignedSSVMessage, error)
+
+	// ImportDecidedStream imports decided messages of the given identifier from a stream of length-prefixed
+	// SignedSSVMessage's, validating them with the given validate func and skipping heights already decided.
+	// Messages are saved in batches as they're read. Returns the number of imported messages, also on error.
+	ImportDecidedStream(identifier []byte, committeeMember *spectypes.CommitteeMember, r io.Reader, validate func(*spectypes.SignedSSVMessage) error) (int, error)
+
 	// SaveInstance updates/inserts the given instance to it's identifier's history.
 	SaveInstance(instance *StoredInstance) error
 
@@ -55,6 +71,10 @@ type InstanceStore interface {
 	// CleanAllInstances removes all historical and highest instances for the given identifier.
 	CleanAllInstances(logger *zap.Logger, msgID []byte) error
 
+	// PruneDecided removes the historical instances of the given identifier below the given height,
+	// keeping the highest historical instance as an anchor. Returns the number of removed instances.
+	PruneDecided(identifier []byte, olderThan specqbft.Height) (int, error)
+
 	// SaveParticipants save participants in quorum.
 	SaveParticipants(identifier convert.MessageID, slot phase0.Slot, operators []spectypes.OperatorID) error
 
@@ -2,11 +2,13 @@ package ssv
 
 import (
 	"encoding/hex"
+	"slices"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
 	specssv "github.com/ssvlabs/ssv-spec/ssv"
 	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"golang.org/x/exp/maps"
 
 	"github.com/ssvlabs/ssv/protocol/v2/types"
 	"github.com/ssvlabs/ssv/utils/threshold"
@@ -113,6 +115,18 @@ func (ps *PartialSigContainer) ReconstructSignature(root [32]byte, validatorPubK
 	return signature.Serialize(), nil
 }
 
+// CollectedCount returns the number of signers which signed the given root for the given validator index.
+func (ps *PartialSigContainer) CollectedCount(validatorIndex phase0.ValidatorIndex, root [32]byte) int {
+	return len(ps.Signatures[validatorIndex][signingRootHex(root)])
+}
+
+// SignerIDs returns the signers which signed the given root for the given validator index, in ascending order.
+func (ps *PartialSigContainer) SignerIDs(validatorIndex phase0.ValidatorIndex, root [32]byte) []spectypes.OperatorID {
+	signers := maps.Keys(ps.Signatures[validatorIndex][signingRootHex(root)])
+	slices.Sort(signers)
+	return signers
+}
+
 func (ps *PartialSigContainer) HasQuorum(validatorIndex phase0.ValidatorIndex, root [32]byte) bool {
 	return uint64(len(ps.Signatures[validatorIndex][signingRootHex(root)])) >= ps.Quorum
 }
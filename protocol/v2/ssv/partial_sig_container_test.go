@@ -0,0 +1,38 @@
+package ssv
+
+import (
+	"testing"
+
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialSigContainerSigners(t *testing.T) {
+	container := NewPartialSigContainer(3)
+	root := [32]byte{1}
+	otherRoot := [32]byte{2}
+
+	require.Zero(t, container.CollectedCount(1, root))
+	require.Empty(t, container.SignerIDs(1, root))
+	require.False(t, container.HasQuorum(1, root))
+
+	for _, signer := range []spectypes.OperatorID{4, 2, 2, 1} {
+		container.AddSignature(&spectypes.PartialSignatureMessage{
+			PartialSignature: make([]byte, 96),
+			SigningRoot:      root,
+			Signer:           signer,
+			ValidatorIndex:   1,
+		})
+	}
+	require.Equal(t, 3, container.CollectedCount(1, root))
+	require.Equal(t, []spectypes.OperatorID{1, 2, 4}, container.SignerIDs(1, root))
+	require.True(t, container.HasQuorum(1, root))
+
+	require.Zero(t, container.CollectedCount(1, otherRoot))
+	require.Zero(t, container.CollectedCount(2, root))
+
+	container.Remove(1, 2, root)
+	require.Equal(t, 2, container.CollectedCount(1, root))
+	require.Equal(t, []spectypes.OperatorID{1, 4}, container.SignerIDs(1, root))
+	require.False(t, container.HasQuorum(1, root))
+}
@@ -1,6 +1,10 @@
 package runner
 
-import spectypes "github.com/ssvlabs/ssv-spec/types"
+import (
+	"slices"
+
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+)
 
 // ValidatorDutyRunners is a map of duty runners mapped by msg id hex.
 type ValidatorDutyRunners map[spectypes.RunnerRole]Runner
@@ -10,3 +14,15 @@ func (ci ValidatorDutyRunners) DutyRunnerForMsgID(msgID spectypes.MessageID) Run
 	role := msgID.GetRoleType()
 	return ci[role]
 }
+
+// Roles returns the roles which have a duty runner, in ascending order.
+func (ci ValidatorDutyRunners) Roles() []spectypes.RunnerRole {
+	roles := make([]spectypes.RunnerRole, 0, len(ci))
+	for role, r := range ci {
+		if r != nil {
+			roles = append(roles, role)
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
@@ -0,0 +1,24 @@
+package runner
+
+import (
+	"testing"
+
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorDutyRunnersRoles(t *testing.T) {
+	require.Empty(t, ValidatorDutyRunners{}.Roles())
+
+	runners := ValidatorDutyRunners{
+		spectypes.RoleVoluntaryExit:         &VoluntaryExitRunner{},
+		spectypes.RoleProposer:              &ProposerRunner{},
+		spectypes.RoleAggregator:            &AggregatorRunner{},
+		spectypes.RoleValidatorRegistration: nil,
+	}
+	require.Equal(t, []spectypes.RunnerRole{
+		spectypes.RoleAggregator,
+		spectypes.RoleProposer,
+		spectypes.RoleVoluntaryExit,
+	}, runners.Roles())
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	spectestingutils "github.com/ssvlabs/ssv-spec/types/testingutils"
+	"github.com/ssvlabs/ssv/protocol/v2/ssv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialSigMsgProcessingDuplicateSigner(t *testing.T) {
+	ks := spectestingutils.Testing4SharesSet()
+	const validatorIndex = phase0.ValidatorIndex(1)
+
+	var committee []*spectypes.ShareMember
+	for _, id := range []spectypes.OperatorID{1, 2, 3, 4} {
+		committee = append(committee, &spectypes.ShareMember{
+			Signer:      id,
+			SharePubKey: ks.Shares[id].GetPublicKey().Serialize(),
+		})
+	}
+	b := &BaseRunner{
+		Share: map[phase0.ValidatorIndex]*spectypes.Share{
+			validatorIndex: {ValidatorIndex: validatorIndex, Committee: committee},
+		},
+	}
+	container := ssv.NewPartialSigContainer(ks.Threshold)
+
+	root := [32]byte{1, 2, 3}
+	partialSig := func(signer spectypes.OperatorID) *spectypes.PartialSignatureMessages {
+		return &spectypes.PartialSignatureMessages{
+			Messages: []*spectypes.PartialSignatureMessage{{
+				PartialSignature: ks.Shares[signer].SignByte(root[:]).Serialize(),
+				SigningRoot:      root,
+				Signer:           signer,
+				ValidatorIndex:   validatorIndex,
+			}},
+		}
+	}
+
+	// Resending partial signatures from the same signers doesn't count towards quorum.
+	for i := 0; i < 3; i++ {
+		for _, signer := range []spectypes.OperatorID{1, 2} {
+			quorum, roots := b.basePartialSigMsgProcessing(partialSig(signer), container)
+			require.False(t, quorum)
+			require.Empty(t, roots)
+		}
+	}
+	require.Len(t, container.GetSignatures(validatorIndex, root), 2)
+
+	// A resent invalid signature doesn't replace the valid one.
+	invalid := partialSig(1)
+	invalid.Messages[0].PartialSignature = ks.Shares[2].SignByte(root[:]).Serialize()
+	quorum, _ := b.basePartialSigMsgProcessing(invalid, container)
+	require.False(t, quorum)
+	sig, err := container.GetSignature(validatorIndex, 1, root)
+	require.NoError(t, err)
+	require.EqualValues(t, partialSig(1).Messages[0].PartialSignature, sig)
+
+	quorum, roots := b.basePartialSigMsgProcessing(partialSig(3), container)
+	require.True(t, quorum)
+	require.Equal(t, [][32]byte{root}, roots)
+}
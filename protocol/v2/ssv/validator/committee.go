@@ -20,6 +20,7 @@ import (
 	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
 	"github.com/ssvlabs/ssv/protocol/v2/ssv/runner"
 	"github.com/ssvlabs/ssv/protocol/v2/types"
+	"github.com/ssvlabs/ssv/utils/hashmap"
 )
 
 var (
@@ -46,6 +47,9 @@ type Committee struct {
 
 	dutyGuard      *CommitteeDutyGuard
 	CreateRunnerFn CommitteeRunnerFunc
+
+	// currentRounds tracks the round of each slot's undecided QBFT instance, or NoRound if there's none.
+	currentRounds *hashmap.Map[phase0.Slot, qbft.Round]
 }
 
 // NewCommittee creates a new cluster
@@ -72,6 +76,7 @@ func NewCommittee(
 		CommitteeMember: committeeMember,
 		CreateRunnerFn:  createRunnerFn,
 		dutyGuard:       NewCommitteeDutyGuard(),
+		currentRounds:   hashmap.New[phase0.Slot, qbft.Round](),
 	}
 }
 
@@ -286,12 +291,35 @@ func (c *Committee) unsafePruneExpiredRunners(logger *zap.Logger, currentSlot ph
 			logger.Debug("pruning expired committee runner", zap.Uint64("slot", uint64(slot)))
 			delete(c.Runners, slot)
 			delete(c.Queues, slot)
+			c.currentRounds.Delete(slot)
 		}
 	}
 
 	return nil
 }
 
+// HasActiveInstance returns true if the committee duty of any slot has a running QBFT instance
+// which hasn't decided yet. It's the counterpart of Validator.HasActiveInstance for RoleCommittee,
+// whose instances run in the committee rather than in its validators.
+func (c *Committee) HasActiveInstance() bool {
+	_, ok := c.CurrentRound()
+	return ok
+}
+
+// CurrentRound returns the round of the latest slot's running QBFT instance which hasn't decided yet,
+// and false if there's none. It's the counterpart of Validator.CurrentRound for RoleCommittee.
+func (c *Committee) CurrentRound() (qbft.Round, bool) {
+	var latest phase0.Slot
+	current := qbft.NoRound
+	c.currentRounds.Range(func(slot phase0.Slot, round qbft.Round) bool {
+		if round != qbft.NoRound && (current == qbft.NoRound || slot > latest) {
+			latest, current = slot, round
+		}
+		return true
+	})
+	return current, current != qbft.NoRound
+}
+
 func (c *Committee) Stop() {
 	c.cancel()
 }
@@ -149,7 +149,9 @@ func (c *Committee) ConsumeQueue(
 				break
 			}
 		}
+		c.currentRounds.Set(slot, activeInstanceRound(rnr))
 	}
+	c.currentRounds.Delete(slot)
 
 	logger.Debug("📪 queue consumer is closed")
 	return nil
@@ -0,0 +1,39 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+)
+
+func TestCommitteeCurrentRound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCommittee(ctx, cancel, logging.TestLogger(t), networkconfig.TestNetwork.Beacon.GetBeaconNetwork(), nil, nil, nil)
+	require.False(t, c.HasActiveInstance())
+	_, ok := c.CurrentRound()
+	require.False(t, ok)
+
+	// The latest slot with an undecided instance is reported.
+	c.currentRounds.Set(10, 2)
+	c.currentRounds.Set(11, 4)
+	c.currentRounds.Set(12, specqbft.NoRound)
+	require.True(t, c.HasActiveInstance())
+	round, ok := c.CurrentRound()
+	require.True(t, ok)
+	require.Equal(t, specqbft.Round(4), round)
+
+	c.currentRounds.Set(11, specqbft.NoRound)
+	round, ok = c.CurrentRound()
+	require.True(t, ok)
+	require.Equal(t, specqbft.Round(2), round)
+
+	c.currentRounds.Delete(10)
+	require.False(t, c.HasActiveInstance())
+}
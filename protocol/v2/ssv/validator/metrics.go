@@ -8,6 +8,14 @@ import (
 	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
 )
 
+// Metrics records metrics about validators. Status callbacks receive the validator's public key,
+// and queue callbacks receive the message ID, which holds the validator's public key (or committee ID)
+// and the runner role, so implementations can label metrics per validator and role.
+//
+// Every validator adds a label value, so per-validator labels grow with the number of validators.
+// Implementations exporting to a time series database should keep such labels to metrics with few series
+// per validator, and may shorten public keys (e.g. to a prefix of their hex or of their hash)
+// at the cost of rare collisions.
 type Metrics interface {
 	ValidatorInactive(publicKey []byte)
 	ValidatorNoIndex(publicKey []byte)
@@ -24,6 +32,7 @@ type Metrics interface {
 	queue.Metrics
 }
 
+// NopMetrics is a Metrics which records nothing, used when no Metrics is given.
 type NopMetrics struct{}
 
 func (n NopMetrics) ValidatorInactive([]byte)                              {}
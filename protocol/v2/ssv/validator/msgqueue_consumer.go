@@ -29,9 +29,27 @@ type queueContainer struct {
 // TODO: accept DecodedSSVMessage once p2p is upgraded to decode messages during validation.
 // TODO: get rid of logger, add context
 func (v *Validator) HandleMessage(logger *zap.Logger, msg *queue.SSVMessage) {
+	// Messages are recorded regardless of the state, so that messages which arrive while
+	// the validator isn't running are replayed when it starts.
+	if v.replayBuffer != nil {
+		if err := v.replayBuffer.Record(v.Share.ValidatorPubKey, msg); err != nil {
+			logger.Warn("❗ failed to record message for replay", zap.Error(err))
+		}
+	}
+
+	v.pushMessage(logger, msg)
+}
+
+// pushMessage pushes the given message to the queue of its role.
+func (v *Validator) pushMessage(logger *zap.Logger, msg *queue.SSVMessage) {
 	v.mtx.RLock() // read v.Queues
 	defer v.mtx.RUnlock()
 
+	// Messages are only accepted while the validator is starting, when recorded messages are replayed, or running.
+	if state := v.State(); state != Starting && state != Started {
+		return
+	}
+
 	// logger.Debug("📬 handling SSV message",
 	// 	zap.Uint64("type", uint64(msg.MsgType)),
 	// 	fields.Role(msg.MsgID.GetRoleType()))
@@ -149,12 +167,19 @@ func (v *Validator) ConsumeQueue(logger *zap.Logger, msgID spectypes.MessageID,
 			lens = lens[:0]
 		}
 
+		if state := v.State(); state == Stopping || state == Stopped {
+			break
+		}
+
 		// Handle the message.
 		if err := handler(logger, msg); err != nil {
 			v.logMsg(logger, msg, "❗ could not handle message",
 				fields.MessageType(msg.SSVMessage.MsgType),
 				zap.Error(err))
 		}
+		round := activeInstanceRound(runner)
+		v.activeInstances.Set(msgID.GetRoleType(), round != specqbft.NoRound)
+		v.currentRounds.Set(msgID.GetRoleType(), round)
 	}
 
 	logger.Debug("📪 queue consumer is closed")
@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"fmt"
+
 	genesisspecqbft "github.com/ssvlabs/ssv-spec-pre-cc/qbft"
 	genesisspectypes "github.com/ssvlabs/ssv-spec-pre-cc/types"
 	specqbft "github.com/ssvlabs/ssv-spec/qbft"
@@ -43,6 +45,9 @@ type Options struct {
 	MessageValidator  validation.MessageValidator
 	Metrics           Metrics
 	Graffiti          []byte
+	OnStateChange     StateChangeHandler
+	// ReplayBuffer, if set, records inbound messages and replays them when the validator starts.
+	ReplayBuffer *ReplayBuffer
 	GenesisOptions
 }
 
@@ -66,8 +71,34 @@ func (o *Options) defaults() {
 type State uint32
 
 const (
-	// NotStarted the validator hasn't started
+	// NotStarted the validator was created but hasn't started
 	NotStarted State = iota
+	// Starting the validator is starting
+	Starting
 	// Started validator is running
 	Started
+	// Stopping the validator is stopping
+	Stopping
+	// Stopped the validator has stopped, and can't be started again
+	Stopped
 )
+
+func (s State) String() string {
+	switch s {
+	case NotStarted:
+		return "not_started"
+	case Starting:
+		return "starting"
+	case Started:
+		return "started"
+	case Stopping:
+		return "stopping"
+	case Stopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("unknown(%d)", s)
+	}
+}
+
+// StateChangeHandler is called on every transition of the validator's State.
+type StateChangeHandler func(from, to State)
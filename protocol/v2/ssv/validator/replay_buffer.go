@@ -0,0 +1,320 @@
+package validator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"go.uber.org/zap"
+
+	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
+	"github.com/ssvlabs/ssv/storage/basedb"
+)
+
+var replayBufferPrefix = []byte("validator_replay_buffer-")
+
+const (
+	// replayQueueSize is the number of recorded messages waiting to be written before Record drops messages.
+	replayQueueSize = 1024
+	// replayBatchSize is the number of recorded messages written in one transaction.
+	replayBatchSize = 256
+	// replayFlushInterval is the longest a recorded message waits to be written.
+	replayFlushInterval = 100 * time.Millisecond
+	// replayPruneInterval is the longest interval between prunes of expired and excess messages.
+	replayPruneInterval = time.Minute
+)
+
+// ReplayBuffer persists recent inbound network messages of validators, so that a restarted validator
+// can replay messages which arrived while it was down and resume in-flight consensus.
+// Messages are kept for at most maxAge, and at most maxSize messages are kept per validator.
+//
+// Recording doesn't touch the database: messages are queued and written in batches by a single writer,
+// started with Start, and expired and excess messages are pruned periodically rather than on every message.
+type ReplayBuffer struct {
+	logger  *zap.Logger
+	db      basedb.Database
+	maxSize int
+	maxAge  time.Duration
+	now     func() time.Time
+
+	records chan replayRecord
+	flushes chan chan struct{}
+	started atomic.Bool
+	stopped chan struct{}
+
+	mu     sync.Mutex                    // serializes database access
+	counts map[spectypes.ValidatorPK]int // stored messages of each validator, counted since start
+}
+
+type replayRecord struct {
+	pubKey spectypes.ValidatorPK
+	msg    *spectypes.SignedSSVMessage
+	time   time.Time
+}
+
+// NewReplayBuffer returns a ReplayBuffer which stores messages in the given database.
+func NewReplayBuffer(logger *zap.Logger, db basedb.Database, maxSize int, maxAge time.Duration) *ReplayBuffer {
+	return &ReplayBuffer{
+		logger:  logger,
+		db:      db,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		now:     time.Now,
+		records: make(chan replayRecord, replayQueueSize),
+		flushes: make(chan chan struct{}),
+		stopped: make(chan struct{}),
+		counts:  make(map[spectypes.ValidatorPK]int),
+	}
+}
+
+// Start starts the writer, which runs until the given context is done and then writes the queued messages.
+func (b *ReplayBuffer) Start(ctx context.Context) {
+	b.started.Store(true)
+	go b.run(ctx)
+}
+
+// Record queues the given message of the given validator to be stored. Event messages are not recorded
+// since they aren't received from the network, and messages which are already recorded are ignored.
+// It never blocks: if the writer is behind, the message is dropped and an error is returned.
+func (b *ReplayBuffer) Record(pubKey spectypes.ValidatorPK, msg *queue.SSVMessage) error {
+	if msg.SignedSSVMessage == nil {
+		return nil
+	}
+	select {
+	case b.records <- replayRecord{pubKey: pubKey, msg: msg.SignedSSVMessage, time: b.now()}:
+		return nil
+	default:
+		return errors.New("replay buffer queue is full")
+	}
+}
+
+// Messages writes the queued messages, removes expired messages of the given validator, and returns
+// the remaining ones in the order they were received.
+func (b *ReplayBuffer) Messages(pubKey spectypes.ValidatorPK) ([]*queue.SSVMessage, error) {
+	b.flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.prune(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]*queue.SSVMessage, 0, len(entries))
+	for _, entry := range entries {
+		signedMsg := &spectypes.SignedSSVMessage{}
+		if err := signedMsg.Decode(entry.data); err != nil {
+			return nil, errors.Wrap(err, "could not decode message")
+		}
+		msg, err := queue.DecodeSignedSSVMessage(signedMsg)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode message body")
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// flush waits for the writer to write the queued messages. It returns at once if the writer isn't running.
+func (b *ReplayBuffer) flush() {
+	if !b.started.Load() {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case b.flushes <- done:
+		<-done
+	case <-b.stopped:
+	}
+}
+
+func (b *ReplayBuffer) run(ctx context.Context) {
+	defer close(b.stopped)
+
+	flushTicker := time.NewTicker(replayFlushInterval)
+	defer flushTicker.Stop()
+	pruneInterval := replayPruneInterval
+	if b.maxAge > 0 {
+		pruneInterval = min(b.maxAge, pruneInterval)
+	}
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+
+	batch := make([]replayRecord, 0, replayBatchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.write(batch); err != nil {
+			b.logger.Warn("❗ failed to record messages for replay", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case record := <-b.records:
+				batch = append(batch, record)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-b.records:
+			batch = append(batch, record)
+			if len(batch) == replayBatchSize {
+				write()
+			}
+		case <-flushTicker.C:
+			write()
+		case done := <-b.flushes:
+			// Messages recorded before the flush are written.
+			drain()
+			write()
+			close(done)
+		case <-pruneTicker.C:
+			write()
+			b.pruneAll()
+		case <-ctx.Done():
+			drain()
+			write()
+			return
+		}
+	}
+}
+
+// write stores the given messages in one transaction, skipping those which are already stored.
+func (b *ReplayBuffer) write(batch []replayRecord) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, record := range batch {
+		if _, ok := b.counts[record.pubKey]; ok {
+			continue
+		}
+		count, err := b.db.CountPrefix(b.validatorPrefix(record.pubKey))
+		if err != nil {
+			return errors.Wrap(err, "could not count messages")
+		}
+		b.counts[record.pubKey] = int(count)
+	}
+
+	added := make(map[spectypes.ValidatorPK]int)
+	err := b.db.Update(func(txn basedb.Txn) error {
+		for _, record := range batch {
+			data, err := record.msg.Encode()
+			if err != nil {
+				return errors.Wrap(err, "could not encode message")
+			}
+			prefix := b.validatorPrefix(record.pubKey)
+			hash := sha256.Sum256(data)
+			_, found, err := txn.Get(prefix, hash[:])
+			if err != nil {
+				return errors.Wrap(err, "could not check message existence")
+			}
+			if found {
+				continue
+			}
+			value := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(data)), uint64(record.time.UnixNano()))
+			value = append(value, data...)
+			if err := txn.Set(prefix, hash[:], value); err != nil {
+				return errors.Wrap(err, "could not record message")
+			}
+			added[record.pubKey]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for pubKey, n := range added {
+		b.counts[pubKey] += n
+	}
+	return nil
+}
+
+// pruneAll prunes the messages of every validator with stored messages.
+func (b *ReplayBuffer) pruneAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for pubKey := range b.counts {
+		if _, err := b.prune(pubKey); err != nil {
+			b.logger.Warn("❗ failed to prune replay messages", zap.Error(err))
+		}
+	}
+}
+
+type replayEntry struct {
+	key  []byte
+	time time.Time
+	data []byte
+}
+
+// prune deletes the expired messages of the given validator and the oldest ones exceeding maxSize,
+// and returns the remaining ones in the order they were received.
+func (b *ReplayBuffer) prune(pubKey spectypes.ValidatorPK) ([]replayEntry, error) {
+	prefix := b.validatorPrefix(pubKey)
+
+	var entries []replayEntry
+	err := b.db.GetAll(prefix, func(i int, obj basedb.Obj) error {
+		if len(obj.Value) < 8 {
+			return errors.New("recorded message is too short")
+		}
+		entries = append(entries, replayEntry{
+			key:  obj.Key,
+			time: time.Unix(0, int64(binary.BigEndian.Uint64(obj.Value[:8]))),
+			data: obj.Value[8:],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read messages")
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+
+	oldest := b.now().Add(-b.maxAge)
+	drop := 0
+	for drop < len(entries) && entries[drop].time.Before(oldest) {
+		drop++
+	}
+	drop = max(drop, len(entries)-b.maxSize)
+
+	if drop > 0 {
+		err := b.db.Update(func(txn basedb.Txn) error {
+			for _, entry := range entries[:drop] {
+				if err := txn.Delete(prefix, entry.key); err != nil {
+					return errors.Wrap(err, "could not delete message")
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if remaining := len(entries) - drop; remaining > 0 {
+		b.counts[pubKey] = remaining
+	} else {
+		delete(b.counts, pubKey)
+	}
+	return entries[drop:], nil
+}
+
+func (b *ReplayBuffer) validatorPrefix(pubKey spectypes.ValidatorPK) []byte {
+	p := append(append([]byte{}, replayBufferPrefix...), pubKey[:]...)
+	return p[:len(p):len(p)]
+}
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
+	ssvtypes "github.com/ssvlabs/ssv/protocol/v2/types"
+	"github.com/ssvlabs/ssv/storage/basedb"
+	"github.com/ssvlabs/ssv/storage/kv"
+)
+
+func testReplayMessage(t *testing.T, pubKey spectypes.ValidatorPK, height specqbft.Height) *queue.SSVMessage {
+	msgID := spectypes.NewMsgID(networkconfig.TestNetwork.DomainType(), pubKey[:], spectypes.RoleProposer)
+	data, err := (&specqbft.Message{
+		MsgType:    specqbft.PrepareMsgType,
+		Height:     height,
+		Round:      specqbft.FirstRound,
+		Identifier: msgID[:],
+	}).Encode()
+	require.NoError(t, err)
+
+	msg, err := queue.DecodeSignedSSVMessage(&spectypes.SignedSSVMessage{
+		Signatures:  [][]byte{make([]byte, 256)},
+		OperatorIDs: []spectypes.OperatorID{1},
+		SSVMessage: &spectypes.SSVMessage{
+			MsgType: spectypes.SSVConsensusMsgType,
+			MsgID:   msgID,
+			Data:    data,
+		},
+	})
+	require.NoError(t, err)
+	return msg
+}
+
+func TestReplayBuffer(t *testing.T) {
+	db, err := kv.NewInMemory(logging.TestLogger(t), basedb.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	buffer := NewReplayBuffer(logging.TestLogger(t), db, 3, time.Minute)
+	buffer.now = func() time.Time { return now }
+	buffer.Start(ctx)
+
+	pk1 := spectypes.ValidatorPK{1}
+	pk2 := spectypes.ValidatorPK{2}
+
+	heights := func(msgs []*queue.SSVMessage) []specqbft.Height {
+		var ret []specqbft.Height
+		for _, msg := range msgs {
+			ret = append(ret, msg.Body.(*specqbft.Message).Height)
+		}
+		return ret
+	}
+
+	// Messages are replayed in the order they were received, without duplicates.
+	for _, height := range []specqbft.Height{1, 2, 1} {
+		now = now.Add(time.Second)
+		require.NoError(t, buffer.Record(pk1, testReplayMessage(t, pk1, height)))
+	}
+	require.NoError(t, buffer.Record(pk2, testReplayMessage(t, pk2, 5)))
+
+	msgs, err := buffer.Messages(pk1)
+	require.NoError(t, err)
+	require.Equal(t, []specqbft.Height{1, 2}, heights(msgs))
+	require.Equal(t, 2, buffer.counts[pk1])
+
+	msgs, err = buffer.Messages(pk2)
+	require.NoError(t, err)
+	require.Equal(t, []specqbft.Height{5}, heights(msgs))
+
+	// The oldest messages are dropped once the buffer is full.
+	for _, height := range []specqbft.Height{3, 4} {
+		now = now.Add(time.Second)
+		require.NoError(t, buffer.Record(pk1, testReplayMessage(t, pk1, height)))
+	}
+	msgs, err = buffer.Messages(pk1)
+	require.NoError(t, err)
+	require.Equal(t, []specqbft.Height{2, 3, 4}, heights(msgs))
+
+	// Expired messages are dropped.
+	now = now.Add(time.Minute - time.Second)
+	msgs, err = buffer.Messages(pk1)
+	require.NoError(t, err)
+	require.Equal(t, []specqbft.Height{3, 4}, heights(msgs))
+
+	now = now.Add(time.Hour)
+	msgs, err = buffer.Messages(pk1)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// Event messages aren't recorded.
+	require.NoError(t, buffer.Record(pk1, &queue.SSVMessage{SSVMessage: &spectypes.SSVMessage{}}))
+	msgs, err = buffer.Messages(pk1)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// Recording never blocks: messages are dropped once the queue of a stopped writer is full.
+	stopped := NewReplayBuffer(logging.TestLogger(t), db, 3, time.Minute)
+	msg := testReplayMessage(t, pk1, 1)
+	for i := 0; i < replayQueueSize; i++ {
+		require.NoError(t, stopped.Record(pk1, msg))
+	}
+	require.EqualError(t, stopped.Record(pk1, msg), "replay buffer queue is full")
+	msgs, err = stopped.Messages(pk1)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+func TestValidatorRecordsWhileNotStarted(t *testing.T) {
+	db, err := kv.NewInMemory(logging.TestLogger(t), basedb.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	buffer := NewReplayBuffer(logging.TestLogger(t), db, 3, time.Minute)
+	buffer.Start(ctx)
+
+	pk := spectypes.ValidatorPK{1}
+	share := &ssvtypes.SSVShare{}
+	share.ValidatorPubKey = pk
+	v := NewValidator(ctx, cancel, Options{SSVShare: share, ReplayBuffer: buffer})
+	v.Queues[spectypes.RoleProposer] = queueContainer{Q: queue.New(10), queueState: &queue.State{}}
+
+	// A message which arrives before the validator starts isn't queued, but is recorded for replay.
+	v.HandleMessage(logging.TestLogger(t), testReplayMessage(t, pk, 1))
+	require.Zero(t, v.Queues[spectypes.RoleProposer].Q.Len())
+
+	msgs, err := buffer.Messages(pk)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	// The recorded message is queued once the validator starts.
+	require.True(t, v.transition(NotStarted, Starting))
+	v.replay(logging.TestLogger(t))
+	require.Equal(t, 1, v.Queues[spectypes.RoleProposer].Q.Len())
+}
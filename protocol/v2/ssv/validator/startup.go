@@ -1,8 +1,6 @@
 package validator
 
 import (
-	"sync/atomic"
-
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
@@ -12,13 +10,19 @@ import (
 	"github.com/ssvlabs/ssv/logging/fields"
 )
 
-// Start starts a Validator.
+// Start starts a Validator. If Stop is called while the validator is starting, the validator is stopped
+// once it has started, and Start returns false.
 func (v *Validator) Start(logger *zap.Logger) (started bool, err error) {
 	logger = logger.Named(logging.NameValidator).With(fields.PubKey(v.Share.ValidatorPubKey[:]))
 
-	if !atomic.CompareAndSwapUint32(&v.state, uint32(NotStarted), uint32(Started)) {
+	if !v.transition(NotStarted, Starting) {
 		return false, nil
 	}
+	defer func() {
+		if !v.transition(Starting, Started) {
+			started = false
+		}
+	}()
 
 	n, ok := v.Network.(p2p.Subscriber)
 	if !ok {
@@ -66,18 +70,43 @@ func (v *Validator) Start(logger *zap.Logger) (started bool, err error) {
 		}
 		go v.StartQueueConsumer(logger, identifier, v.ProcessMessage)
 	}
+
+	if v.replayBuffer != nil {
+		v.replay(logger)
+	}
 	return true, nil
 }
 
-// Stop stops a Validator.
+// replay pushes the messages recorded by the replay buffer into the queues. Replayed messages
+// are processed like any other message, so they're verified and go through the runners'
+// slashing protection before anything is signed.
+func (v *Validator) replay(logger *zap.Logger) {
+	msgs, err := v.replayBuffer.Messages(v.Share.ValidatorPubKey)
+	if err != nil {
+		logger.Warn("❗ failed to load messages for replay", zap.Error(err))
+		return
+	}
+	// Replayed messages are already recorded, so they're pushed without being recorded again.
+	for _, msg := range msgs {
+		v.pushMessage(logger, msg)
+	}
+	if len(msgs) > 0 {
+		logger.Debug("replayed recorded messages", zap.Int("count", len(msgs)))
+	}
+}
+
+// Stop stops a Validator, including one which is still starting.
 func (v *Validator) Stop() {
-	if atomic.CompareAndSwapUint32(&v.state, uint32(Started), uint32(NotStarted)) {
-		v.cancel()
+	if !v.transition(Started, Stopping) && !v.transition(Starting, Stopping) {
+		return
+	}
+	v.cancel()
 
-		v.mtx.Lock() // write-lock for v.Queues
-		defer v.mtx.Unlock()
+	v.mtx.Lock() // write-lock for v.Queues
+	defer v.mtx.Unlock()
 
-		// clear the msg q
-		v.Queues = make(map[spectypes.RunnerRole]queueContainer)
-	}
+	// clear the msg q
+	v.Queues = make(map[spectypes.RunnerRole]queueContainer)
+
+	v.transition(Stopping, Stopped)
 }
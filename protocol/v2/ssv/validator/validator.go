@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
@@ -19,6 +20,8 @@ import (
 	"github.com/ssvlabs/ssv/message/validation"
 	"github.com/ssvlabs/ssv/networkconfig"
 	"github.com/ssvlabs/ssv/protocol/v2/message"
+	"github.com/ssvlabs/ssv/protocol/v2/qbft"
+	qbftcontroller "github.com/ssvlabs/ssv/protocol/v2/qbft/controller"
 	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
 	"github.com/ssvlabs/ssv/protocol/v2/ssv/runner"
 	ssvtypes "github.com/ssvlabs/ssv/protocol/v2/types"
@@ -46,10 +49,17 @@ type Validator struct {
 
 	// dutyIDs is a map for logging a unique ID for a given duty
 	dutyIDs *hashmap.Map[spectypes.RunnerRole, string]
+	// activeInstances tracks whether each role has an undecided QBFT instance,
+	// updated by the queue consumer so it can be read without racing message processing.
+	activeInstances *hashmap.Map[spectypes.RunnerRole, bool]
+	// currentRounds tracks the round of each role's undecided QBFT instance, or NoRound if there's none.
+	currentRounds *hashmap.Map[spectypes.RunnerRole, specqbft.Round]
 
-	state uint32
+	state         uint32
+	onStateChange StateChangeHandler
 
 	messageValidator validation.MessageValidator
+	replayBuffer     *ReplayBuffer
 }
 
 // NewValidator creates a new instance of Validator.
@@ -75,7 +85,11 @@ func NewValidator(pctx context.Context, cancel func(), options Options) *Validat
 		Queues:           make(map[spectypes.RunnerRole]queueContainer),
 		state:            uint32(NotStarted),
 		dutyIDs:          hashmap.New[spectypes.RunnerRole, string](), // TODO: use beaconrole here?
+		activeInstances:  hashmap.New[spectypes.RunnerRole, bool](),
+		currentRounds:    hashmap.New[spectypes.RunnerRole, specqbft.Round](),
 		messageValidator: options.MessageValidator,
+		onStateChange:    options.OnStateChange,
+		replayBuffer:     options.ReplayBuffer,
 	}
 
 	for _, dutyRunner := range options.DutyRunners {
@@ -99,6 +113,12 @@ func NewValidator(pctx context.Context, cancel func(), options Options) *Validat
 	return v
 }
 
+// SupportedRoles returns the roles the validator has duty runners for, in ascending order.
+// Duties of other roles can't be executed by the validator.
+func (v *Validator) SupportedRoles() []spectypes.RunnerRole {
+	return v.DutyRunners.Roles()
+}
+
 // StartDuty starts a duty for the validator
 func (v *Validator) StartDuty(logger *zap.Logger, duty spectypes.Duty) error {
 	vDuty, ok := duty.(*spectypes.ValidatorDuty)
@@ -194,6 +214,95 @@ func (v *Validator) ProcessMessage(logger *zap.Logger, msg *queue.SSVMessage) er
 	}
 }
 
+// State returns the current State of the validator.
+func (v *Validator) State() State {
+	return State(atomic.LoadUint32(&v.state))
+}
+
+// transition atomically changes the validator's State from the given state,
+// and returns false if the validator isn't in the given state.
+func (v *Validator) transition(from, to State) bool {
+	if !atomic.CompareAndSwapUint32(&v.state, uint32(from), uint32(to)) {
+		return false
+	}
+	if v.onStateChange != nil {
+		v.onStateChange(from, to)
+	}
+	return true
+}
+
+// HasActiveInstance returns true if the given role has a running QBFT instance which hasn't decided yet.
+// Only the validator's own runners are covered: RoleCommittee instances run in the validator's Committee,
+// and are reported by Committee.HasActiveInstance.
+func (v *Validator) HasActiveInstance(role spectypes.RunnerRole) bool {
+	active, _ := v.activeInstances.Get(role)
+	return active
+}
+
+// CurrentRound returns the round of the given role's running QBFT instance, and false if
+// there's no running instance or it has decided. A validator which stays in high rounds
+// keeps changing rounds, which usually means its committee has faulty or offline operators.
+// Like HasActiveInstance, it doesn't cover RoleCommittee, which is reported by Committee.CurrentRound.
+func (v *Validator) CurrentRound(role spectypes.RunnerRole) (specqbft.Round, bool) {
+	round, _ := v.currentRounds.Get(role)
+	return round, round != specqbft.NoRound
+}
+
+// DecidedChan returns the channel which is pushed the messages decided by the given role's QBFT controller,
+// or nil if the role has no controller.
+func (v *Validator) DecidedChan(role spectypes.RunnerRole) <-chan *spectypes.SignedSSVMessage {
+	ctrl := v.controller(role)
+	if ctrl == nil {
+		return nil
+	}
+	return ctrl.DecidedChan()
+}
+
+// controller returns the QBFT controller of the given role's runner, or nil if it has none.
+func (v *Validator) controller(role spectypes.RunnerRole) qbftcontroller.IController {
+	dutyRunner := v.DutyRunners[role]
+	if dutyRunner == nil || dutyRunner.GetBaseRunner().QBFTController == nil {
+		return nil
+	}
+	return dutyRunner.GetBaseRunner().QBFTController
+}
+
+// activeInstanceRound returns the round of the given runner's running QBFT instance,
+// or NoRound if there's none or it has decided.
+// It must be called from the goroutine which processes the runner's messages.
+func activeInstanceRound(r runner.Runner) specqbft.Round {
+	if !r.HasRunningDuty() {
+		return specqbft.NoRound
+	}
+	runningInstance := r.GetBaseRunner().State.RunningInstance
+	if runningInstance == nil || runningInstance.State == nil {
+		return specqbft.NoRound
+	}
+	if decided, _ := runningInstance.IsDecided(); decided {
+		return specqbft.NoRound
+	}
+	return runningInstance.State.Round
+}
+
+// Committee returns the IDs of the operators in the validator's committee.
+func (v *Validator) Committee() []spectypes.OperatorID {
+	return v.Share.OperatorIDs()
+}
+
+// Quorum returns the number of operators required for a quorum in the validator's committee.
+func (v *Validator) Quorum() uint64 {
+	return v.Share.Quorum()
+}
+
+// IsLeader returns true if this operator is the proposer of the given height and round.
+func (v *Validator) IsLeader(height specqbft.Height, round specqbft.Round) bool {
+	state := &specqbft.State{
+		CommitteeMember: v.Operator,
+		Height:          height,
+	}
+	return qbft.RoundRobinProposer(state, round) == v.Operator.OperatorID
+}
+
 func (v *Validator) loggerForDuty(logger *zap.Logger, role spectypes.BeaconRole, slot phase0.Slot) *zap.Logger {
 	logger = logger.With(fields.Slot(slot))
 	if dutyID, ok := v.dutyIDs.Get(casts.BeaconRoleToRunnerRole(role)); ok {
@@ -0,0 +1,209 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	specqbft "github.com/ssvlabs/ssv-spec/qbft"
+	spectypes "github.com/ssvlabs/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssvlabs/ssv/logging"
+	"github.com/ssvlabs/ssv/networkconfig"
+	qbftcontroller "github.com/ssvlabs/ssv/protocol/v2/qbft/controller"
+	"github.com/ssvlabs/ssv/protocol/v2/ssv/queue"
+	"github.com/ssvlabs/ssv/protocol/v2/ssv/runner"
+	ssvtypes "github.com/ssvlabs/ssv/protocol/v2/types"
+)
+
+func TestValidatorCommittee(t *testing.T) {
+	var members []*spectypes.Operator
+	var shareMembers []*spectypes.ShareMember
+	for id := spectypes.OperatorID(1); id <= 4; id++ {
+		members = append(members, &spectypes.Operator{OperatorID: id})
+		shareMembers = append(shareMembers, &spectypes.ShareMember{Signer: id})
+	}
+
+	v := &Validator{
+		Operator: &spectypes.CommitteeMember{
+			OperatorID: 2,
+			Committee:  members,
+		},
+		Share: &ssvtypes.SSVShare{
+			Share: spectypes.Share{Committee: shareMembers},
+		},
+	}
+
+	require.Equal(t, []spectypes.OperatorID{1, 2, 3, 4}, v.Committee())
+	require.Equal(t, uint64(3), v.Quorum())
+
+	require.False(t, v.IsLeader(specqbft.FirstHeight, specqbft.FirstRound))
+	require.True(t, v.IsLeader(specqbft.FirstHeight, specqbft.FirstRound+1))
+	require.True(t, v.IsLeader(1, specqbft.FirstRound))
+	require.False(t, v.IsLeader(1, specqbft.FirstRound+1))
+}
+
+type testNetwork struct{}
+
+func (testNetwork) Broadcast(spectypes.MessageID, *spectypes.SignedSSVMessage) error { return nil }
+func (testNetwork) Subscribe(spectypes.ValidatorPK) error                            { return nil }
+
+func TestValidatorLifecycle(t *testing.T) {
+	type transition struct{ from, to State }
+	var transitions []transition
+
+	ctx, cancel := context.WithCancel(context.Background())
+	v := NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners:   runner.ValidatorDutyRunners{},
+		OnStateChange: func(from, to State) {
+			transitions = append(transitions, transition{from, to})
+		},
+	})
+	require.Equal(t, NotStarted, v.State())
+
+	logger := logging.TestLogger(t)
+	started, err := v.Start(logger)
+	require.NoError(t, err)
+	require.True(t, started)
+	require.Equal(t, Started, v.State())
+
+	started, err = v.Start(logger)
+	require.NoError(t, err)
+	require.False(t, started)
+
+	v.Stop()
+	require.Equal(t, Stopped, v.State())
+	require.Error(t, ctx.Err())
+
+	// A stopped validator can't be started again.
+	started, err = v.Start(logger)
+	require.NoError(t, err)
+	require.False(t, started)
+
+	require.Equal(t, []transition{
+		{NotStarted, Starting},
+		{Starting, Started},
+		{Started, Stopping},
+		{Stopping, Stopped},
+	}, transitions)
+}
+
+func TestValidatorStopWhileStarting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var v *Validator
+	v = NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners:   runner.ValidatorDutyRunners{},
+		OnStateChange: func(from, to State) {
+			if to == Starting {
+				v.Stop()
+			}
+		},
+	})
+
+	started, err := v.Start(logging.TestLogger(t))
+	require.NoError(t, err)
+	require.False(t, started)
+	require.Equal(t, Stopped, v.State())
+	require.Error(t, ctx.Err())
+}
+
+func TestValidatorHandleMessageState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v := NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners:   runner.ValidatorDutyRunners{},
+	})
+	q := queue.New(10)
+	v.Queues[spectypes.RoleProposer] = queueContainer{Q: q, queueState: &queue.State{}}
+
+	logger := logging.TestLogger(t)
+	pk := spectypes.ValidatorPK{1}
+
+	// Messages are rejected before the validator starts.
+	v.HandleMessage(logger, testReplayMessage(t, pk, 1))
+	require.True(t, q.Empty())
+
+	started, err := v.Start(logger)
+	require.NoError(t, err)
+	require.True(t, started)
+	v.HandleMessage(logger, testReplayMessage(t, pk, 1))
+	require.Equal(t, 1, q.Len())
+
+	// Messages are rejected after the validator stops.
+	v.Stop()
+	v.HandleMessage(logger, testReplayMessage(t, pk, 2))
+	require.Equal(t, 1, q.Len())
+}
+
+func TestValidatorHasActiveInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners:   runner.ValidatorDutyRunners{},
+	})
+	require.False(t, v.HasActiveInstance(spectypes.RoleProposer))
+
+	v.activeInstances.Set(spectypes.RoleProposer, true)
+	require.True(t, v.HasActiveInstance(spectypes.RoleProposer))
+	require.False(t, v.HasActiveInstance(spectypes.RoleAggregator))
+
+	v.activeInstances.Set(spectypes.RoleProposer, false)
+	require.False(t, v.HasActiveInstance(spectypes.RoleProposer))
+}
+
+func TestValidatorCurrentRound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners:   runner.ValidatorDutyRunners{},
+	})
+	_, ok := v.CurrentRound(spectypes.RoleProposer)
+	require.False(t, ok)
+
+	v.currentRounds.Set(spectypes.RoleProposer, 3)
+	round, ok := v.CurrentRound(spectypes.RoleProposer)
+	require.True(t, ok)
+	require.Equal(t, specqbft.Round(3), round)
+	_, ok = v.CurrentRound(spectypes.RoleAggregator)
+	require.False(t, ok)
+
+	v.currentRounds.Set(spectypes.RoleProposer, specqbft.NoRound)
+	_, ok = v.CurrentRound(spectypes.RoleProposer)
+	require.False(t, ok)
+}
+
+func TestValidatorDecidedChan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := qbftcontroller.NewController(nil, nil, nil, nil, false)
+	v := NewValidator(ctx, cancel, Options{
+		NetworkConfig: networkconfig.TestNetwork,
+		Network:       testNetwork{},
+		SSVShare:      &ssvtypes.SSVShare{},
+		DutyRunners: runner.ValidatorDutyRunners{
+			spectypes.RoleProposer:   &runner.ProposerRunner{BaseRunner: &runner.BaseRunner{QBFTController: ctrl}},
+			spectypes.RoleAggregator: &runner.AggregatorRunner{BaseRunner: &runner.BaseRunner{}},
+		},
+	})
+	require.Equal(t, ctrl.DecidedChan(), v.DecidedChan(spectypes.RoleProposer))
+	require.Nil(t, v.DecidedChan(spectypes.RoleAggregator))
+	require.Nil(t, v.DecidedChan(spectypes.RoleVoluntaryExit))
+}
@@ -18,7 +18,10 @@ import (
 	"github.com/ssvlabs/ssv/storage/basedb"
 )
 
-var sharesPrefix = []byte("shares")
+var (
+	sharesPrefix            = []byte("shares")
+	quarantinedSharesPrefix = []byte("quarantined_shares")
+)
 
 // SharesFilter is a function that filters shares.
 type SharesFilter func(*types.SSVShare) bool
@@ -49,6 +52,20 @@ type Shares interface {
 
 	// UpdateValidatorsMetadata updates the metadata of the given validators
 	UpdateValidatorsMetadata(map[spectypes.ValidatorPK]*beaconprotocol.ValidatorMetadata) error
+
+	// ListQuarantined returns the raw shares which failed to decode and were quarantined, keyed by validator public key.
+	ListQuarantined() ([]basedb.Obj, error)
+}
+
+// SharesStorageOption configures the shares storage.
+type SharesStorageOption func(*sharesStorage)
+
+// WithShareQuarantine skips shares which fail to decode on load and moves them to a quarantine prefix
+// for later inspection, instead of failing the load.
+func WithShareQuarantine() SharesStorageOption {
+	return func(s *sharesStorage) {
+		s.quarantine = true
+	}
 }
 
 type sharesStorage struct {
@@ -57,6 +74,7 @@ type sharesStorage struct {
 	prefix         []byte
 	shares         map[string]*types.SSVShare
 	validatorStore *validatorStore
+	quarantine     bool
 	mu             sync.RWMutex
 }
 
@@ -109,13 +127,16 @@ func (s *storageShare) Decode(data []byte) error {
 	return nil
 }
 
-func NewSharesStorage(logger *zap.Logger, db basedb.Database, prefix []byte) (Shares, ValidatorStore, error) {
+func NewSharesStorage(logger *zap.Logger, db basedb.Database, prefix []byte, opts ...SharesStorageOption) (Shares, ValidatorStore, error) {
 	storage := &sharesStorage{
 		logger: logger,
 		shares: make(map[string]*types.SSVShare),
 		db:     db,
 		prefix: prefix,
 	}
+	for _, opt := range opts {
+		opt(storage)
+	}
 
 	if err := storage.load(); err != nil {
 		return nil, nil, err
@@ -131,24 +152,85 @@ func NewSharesStorage(logger *zap.Logger, db basedb.Database, prefix []byte) (Sh
 }
 
 // load reads all shares from db.
+// A share which fails to decode fails the load, unless quarantine is enabled,
+// in which case it's skipped so that a single corrupt share doesn't prevent loading the rest.
 func (s *sharesStorage) load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.db.GetAll(append(s.prefix, sharesPrefix...), func(i int, obj basedb.Obj) error {
-		val := &storageShare{}
-		if err := val.Decode(obj.Value); err != nil {
-			return fmt.Errorf("failed to deserialize share: %w", err)
-		}
-		val.DomainType = spectypes.DomainType(genesistypes.GetDefaultDomain())
-		share, err := s.storageShareToSpecShare(val)
+	var corrupt []basedb.Obj
+	err := s.db.GetAll(append(s.prefix, sharesPrefix...), func(i int, obj basedb.Obj) error {
+		share, err := s.decodeShare(obj.Value)
 		if err != nil {
-			return fmt.Errorf("failed to convert storage share to spec share: %w", err)
+			if !s.quarantine {
+				return err
+			}
+			s.logger.Error("skipping corrupt share",
+				zap.String("pubkey", hex.EncodeToString(bytes.TrimPrefix(obj.Key, []byte("/")))),
+				zap.Error(err))
+			corrupt = append(corrupt, obj)
+			return nil
 		}
 
-		s.shares[hex.EncodeToString(val.ValidatorPubKey[:])] = share
+		s.shares[hex.EncodeToString(share.ValidatorPubKey[:])] = share
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	if len(corrupt) > 0 {
+		if err := s.quarantineShares(corrupt); err != nil {
+			return fmt.Errorf("failed to quarantine corrupt shares: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *sharesStorage) decodeShare(data []byte) (*types.SSVShare, error) {
+	val := &storageShare{}
+	if err := val.Decode(data); err != nil {
+		return nil, fmt.Errorf("failed to deserialize share: %w", err)
+	}
+	val.DomainType = spectypes.DomainType(genesistypes.GetDefaultDomain())
+	share, err := s.storageShareToSpecShare(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert storage share to spec share: %w", err)
+	}
+	return share, nil
+}
+
+// quarantineShares moves the given shares, as returned by GetAll over the shares prefix, to the quarantine prefix.
+func (s *sharesStorage) quarantineShares(objs []basedb.Obj) error {
+	return s.db.Update(func(txn basedb.Txn) error {
+		for _, obj := range objs {
+			pk := bytes.TrimPrefix(obj.Key, []byte("/"))
+			if err := txn.Set(s.prefix, s.quarantineKey(pk), obj.Value); err != nil {
+				return err
+			}
+			if err := txn.Delete(s.prefix, s.storageKey(pk)); err != nil {
+				return err
+			}
+			s.logger.Warn("quarantined corrupt share", zap.String("pubkey", hex.EncodeToString(pk)))
+		}
+		return nil
+	})
+}
+
+// ListQuarantined returns the raw shares which failed to decode and were quarantined, keyed by validator public key.
+func (s *sharesStorage) ListQuarantined() ([]basedb.Obj, error) {
+	var objs []basedb.Obj
+	err := s.db.GetAll(append(s.prefix, quarantinedSharesPrefix...), func(i int, obj basedb.Obj) error {
+		objs = append(objs, basedb.Obj{
+			Key:   bytes.TrimPrefix(obj.Key, []byte("/")),
+			Value: obj.Value,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
 }
 
 func (s *sharesStorage) Get(_ basedb.Reader, pubKey []byte) (*types.SSVShare, bool) {
@@ -208,6 +290,9 @@ func (s *sharesStorage) Save(rw basedb.ReadWriter, shares ...*types.SSVShare) er
 		if share == nil {
 			return fmt.Errorf("nil share")
 		}
+		if share.ValidatorPubKey == (spectypes.ValidatorPK{}) {
+			return fmt.Errorf("share has an empty validator public key")
+		}
 	}
 
 	s.mu.Lock()
@@ -406,6 +491,11 @@ func (s *sharesStorage) Drop() error {
 	return nil
 }
 
+// quarantineKey builds quarantined share key using quarantinedSharesPrefix & validator public key, e.g. "quarantined_shares/0x00..01"
+func (s *sharesStorage) quarantineKey(pk []byte) []byte {
+	return bytes.Join([][]byte{quarantinedSharesPrefix, pk}, []byte("/"))
+}
+
 // storageKey builds share key using sharesPrefix & validator public key, e.g. "shares/0x00..01"
 func (s *sharesStorage) storageKey(pk []byte) []byte {
 	return bytes.Join([][]byte{sharesPrefix, pk}, []byte("/"))
@@ -330,6 +330,46 @@ func (t *testStorage) Close() error {
 	return t.db.Close()
 }
 
+func TestSharesStorageCorruptShare(t *testing.T) {
+	logger := logging.TestLogger(t)
+	storage, err := newTestStorage(logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	threshold.Init()
+	const keysCount = 4
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+
+	splitKeys, err := threshold.Create(sk.Serialize(), keysCount-1, keysCount)
+	require.NoError(t, err)
+
+	validatorShare, _ := generateRandomValidatorSpecShare(splitKeys)
+	require.NoError(t, storage.Shares.Save(nil, validatorShare))
+
+	corruptPK := bytes.Repeat([]byte{1}, 48)
+	corruptValue := []byte("corrupt")
+	require.NoError(t, storage.db.Set([]byte("test"), bytes.Join([][]byte{sharesPrefix, corruptPK}, []byte("/")), corruptValue))
+
+	// Without quarantine, the corrupt share fails the load.
+	_, _, err = NewSharesStorage(logger, storage.db, []byte("test"))
+	require.ErrorContains(t, err, "failed to deserialize share")
+
+	// The corrupt share is skipped and quarantined.
+	shares, _, err := NewSharesStorage(logger, storage.db, []byte("test"), WithShareQuarantine())
+	require.NoError(t, err)
+	require.Len(t, shares.List(nil), 1)
+	quarantined, err := shares.ListQuarantined()
+	require.NoError(t, err)
+	require.Equal(t, []basedb.Obj{{Key: corruptPK, Value: corruptValue}}, quarantined)
+
+	// Quarantined shares aren't loaded again.
+	_, found, err := storage.db.Get([]byte("test"), bytes.Join([][]byte{sharesPrefix, corruptPK}, []byte("/")))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
 func TestShareDeletionHandlesValidatorStoreCorrectly(t *testing.T) {
 	logger := logging.TestLogger(t)
 	storage, err := newTestStorage(logger)
@@ -499,3 +539,23 @@ func TestValidatorStoreThroughSharesStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestSharesStorageSaveEmptyPublicKey(t *testing.T) {
+	logger := logging.TestLogger(t)
+	storage, err := newTestStorage(logger)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	threshold.Init()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	splitKeys, err := threshold.Create(sk.Serialize(), 3, 4)
+	require.NoError(t, err)
+
+	valid, _ := generateRandomValidatorSpecShare(splitKeys)
+	empty, _ := generateRandomValidatorSpecShare(splitKeys)
+	empty.ValidatorPubKey = spectypes.ValidatorPK{}
+
+	require.EqualError(t, storage.Shares.Save(nil, valid, empty), "share has an empty validator public key")
+	require.Empty(t, storage.Shares.List(nil))
+}
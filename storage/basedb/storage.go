@@ -53,6 +53,9 @@ type Database interface {
 
 	// TODO: consider moving these functions into Reader and ReadWriter interfaces?
 	CountPrefix(prefix []byte) (int64, error)
+	// GetRange iterates in key order over the items of the given collection whose keys are in [from, to),
+	// seeking to from rather than scanning the collection. A nil to iterates to the end of the collection.
+	GetRange(prefix []byte, from, to []byte, handler func(int, Obj) error) error
 	DropPrefix(prefix []byte) error
 	Update(fn func(Txn) error) error
 	Close() error
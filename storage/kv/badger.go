@@ -167,6 +167,49 @@ func (b *BadgerDB) GetAll(prefix []byte, handler func(int, basedb.Obj) error) er
 	return err
 }
 
+// GetRange returns the items of a given collection whose keys are in [from, to), in key order.
+func (b *BadgerDB) GetRange(prefix []byte, from, to []byte, handler func(int, basedb.Obj) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		// As in GetAll, the keys are fetched first and the values afterwards.
+		start := append(append(make([]byte, 0, len(prefix)+len(from)), prefix...), from...)
+		var end []byte
+		if to != nil {
+			end = append(append(make([]byte, 0, len(prefix)+len(to)), prefix...), to...)
+		}
+
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = prefix
+		opt.PrefetchValues = false
+		it := txn.NewIterator(opt)
+		var rawKeys [][]byte
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			if end != nil && bytes.Compare(it.Item().Key(), end) >= 0 {
+				break
+			}
+			rawKeys = append(rawKeys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+
+		for i, k := range rawKeys {
+			item, err := txn.Get(k)
+			if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := handler(i, basedb.Obj{
+				Key:   bytes.TrimPrefix(k, prefix),
+				Value: val,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // CountPrefix return the object count for all keys under specified prefix(bucket)
 func (b *BadgerDB) CountPrefix(prefix []byte) (int64, error) {
 	var res int64
@@ -117,6 +117,36 @@ func TestBadgerDb_GetAll(t *testing.T) {
 	})
 }
 
+func TestBadgerDb_GetRange(t *testing.T) {
+	logger := logging.TestLogger(t)
+	db, err := NewInMemory(logger, basedb.Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	prefix := []byte("prefix")
+	for i := uint64(0); i < 10; i++ {
+		require.NoError(t, db.Set(prefix, binary.BigEndian.AppendUint64(nil, i), []byte{byte(i)}))
+	}
+	require.NoError(t, db.Set([]byte("other"), binary.BigEndian.AppendUint64(nil, 5), []byte{0}))
+
+	getRange := func(from, to []byte) []byte {
+		var values []byte
+		err := db.GetRange(prefix, from, to, func(i int, obj basedb.Obj) error {
+			require.Len(t, obj.Key, 8)
+			values = append(values, obj.Value...)
+			return nil
+		})
+		require.NoError(t, err)
+		return values
+	}
+	key := func(i uint64) []byte { return binary.BigEndian.AppendUint64(nil, i) }
+
+	require.Equal(t, []byte{3, 4, 5, 6}, getRange(key(3), key(7)))
+	require.Equal(t, []byte{8, 9}, getRange(key(8), nil))
+	require.Equal(t, []byte{0, 1}, getRange(nil, key(2)))
+	require.Empty(t, getRange(key(5), key(5)))
+}
+
 func TestBadgerDb_GetMany(t *testing.T) {
 	logger := logging.TestLogger(t)
 	db, err := NewInMemory(logger, basedb.Options{})